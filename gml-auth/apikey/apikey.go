@@ -0,0 +1,189 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gml-auth/storage"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Области доступа admin API
+const (
+	ScopeAll           = "*"
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeNewsWrite     = "news:write"
+	ScopeSecurityRead  = "security:read"
+	ScopeSecurityWrite = "security:write"
+)
+
+// Scopes — все известные области, кроме "*"
+var Scopes = []string{
+	ScopeUsersRead, ScopeUsersWrite,
+	ScopeNewsWrite,
+	ScopeSecurityRead, ScopeSecurityWrite,
+}
+
+const tokenPrefix = "gml_"
+
+// lastUsedPrecision — как часто обновлять last_used_at, чтобы не писать файл на каждый запрос
+const lastUsedPrecision = time.Minute
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrExpiredKey   = errors.New("api key expired")
+	ErrNotFound     = errors.New("api key not found")
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+// Key — API ключ. Секрет хранится только в виде SHA-256.
+type Key struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+// Allows сообщает, разрешена ли ключу область scope
+func (k Key) Allows(scope string) bool {
+	return slices.Contains(k.Scopes, ScopeAll) || slices.Contains(k.Scopes, scope)
+}
+
+// State — содержимое data/api_keys.json
+type State struct {
+	Keys []*Key `json:"keys"`
+}
+
+type Store struct {
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func New(doc *storage.Document[State]) *Store {
+	return &Store{doc: doc, now: time.Now}
+}
+
+// Create выпускает ключ. ttl = 0 — бессрочный.
+// Токен вида gml_<id>_<secret> возвращается один раз и больше нигде не хранится.
+func (s *Store) Create(name string, scopes []string, ttl time.Duration) (string, Key, error) {
+	for _, sc := range scopes {
+		if sc != ScopeAll && !slices.Contains(Scopes, sc) {
+			return "", Key{}, ErrUnknownScope
+		}
+	}
+	idBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", Key{}, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", Key{}, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	now := s.now().UTC()
+	key := Key{
+		ID:        hex.EncodeToString(idBytes),
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+	err := s.doc.Update(func(st *State) error {
+		k := key
+		st.Keys = append(st.Keys, &k)
+		return nil
+	})
+	key.Hash = ""
+	return tokenPrefix + key.ID + "_" + secret, key, err
+}
+
+// Authenticate проверяет токен и отмечает время последнего использования
+func (s *Store) Authenticate(token string) (Key, error) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+	hash := hashSecret(secret)
+	now := s.now().UTC()
+
+	var (
+		key   Key
+		found bool
+	)
+	s.doc.Read(func(st *State) {
+		for _, k := range st.Keys {
+			if k.ID == id && subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+				key, found = *k, true
+				return
+			}
+		}
+	})
+	if !found {
+		return Key{}, ErrInvalidKey
+	}
+	if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+		return Key{}, ErrExpiredKey
+	}
+	if now.Sub(key.LastUsedAt) >= lastUsedPrecision {
+		key.LastUsedAt = now
+		s.doc.Update(func(st *State) error {
+			for _, k := range st.Keys {
+				if k.ID == id {
+					k.LastUsedAt = now
+				}
+			}
+			return nil
+		})
+	}
+	key.Hash = ""
+	return key, nil
+}
+
+// List возвращает ключи без хэшей, отсортированные по дате создания
+func (s *Store) List() ([]Key, error) {
+	out := []Key{}
+	err := s.doc.Read(func(st *State) {
+		for _, k := range st.Keys {
+			c := *k
+			c.Hash = ""
+			out = append(out, c)
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, err
+}
+
+// Revoke удаляет ключ
+func (s *Store) Revoke(id string) error {
+	return s.doc.Update(func(st *State) error {
+		for i, k := range st.Keys {
+			if k.ID == id {
+				st.Keys = append(st.Keys[:i], st.Keys[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
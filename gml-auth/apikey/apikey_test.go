@@ -0,0 +1,73 @@
+package apikey
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, *time.Time) {
+	s := New(storage.NewDocument[State](filepath.Join(t.TempDir(), "api_keys.json")))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	s, now := newTestStore(t)
+	token, key, err := s.Create("panel", []string{ScopeUsersRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "gml_"+key.ID+"_") || key.Hash != "" {
+		t.Fatalf("unexpected token/key: %s %+v", token, key)
+	}
+
+	got, err := s.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Allows(ScopeUsersRead) || got.Allows(ScopeUsersWrite) {
+		t.Errorf("unexpected scopes: %v", got.Scopes)
+	}
+	list, _ := s.List()
+	if len(list) != 1 || !list[0].LastUsedAt.Equal(*now) || list[0].Hash != "" {
+		t.Errorf("expected last_used_at to be recorded without hash: %+v", list)
+	}
+
+	if _, err := s.Authenticate(token + "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected invalid key, got %v", err)
+	}
+	if _, err := s.Authenticate("Bearer nonsense"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected invalid key, got %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s, now := newTestStore(t)
+	token, _, _ := s.Create("tmp", []string{ScopeAll}, time.Hour)
+	*now = now.Add(2 * time.Hour)
+	if _, err := s.Authenticate(token); !errors.Is(err, ErrExpiredKey) {
+		t.Errorf("expected expired key, got %v", err)
+	}
+}
+
+func TestUnknownScopeAndRevoke(t *testing.T) {
+	s, _ := newTestStore(t)
+	if _, _, err := s.Create("bad", []string{"users:delete"}, 0); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("expected unknown scope, got %v", err)
+	}
+	token, key, _ := s.Create("all", []string{ScopeAll}, 0)
+	if err := s.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(token); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected revoked key to fail, got %v", err)
+	}
+	if err := s.Revoke(key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+// Package audit — журнал попыток входа: JSON Lines файл, в который только дописывают.
+// Файл переименовывается в архив при превышении размера или со сменой суток,
+// архивы старше MaxAge удаляются.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Итоги попытки входа
+const (
+	ResultSuccess     = "success"
+	ResultBadRequest  = "bad_request"
+	ResultThrottled   = "throttled"
+	ResultIPBlocked   = "ip_blocked"
+	ResultHWIDBlocked = "hwid_blocked"
+	ResultHWIDLimit   = "hwid_limit"
+	ResultDenied      = "denied_by_policy"
+	ResultMaintenance = "maintenance"
+	ResultNotFound    = "not_found"
+	ResultBlocked     = "blocked"
+	ResultBadPassword = "bad_password"
+	ResultTOTPNeeded  = "totp_required"
+	ResultBadTOTP     = "bad_totp"
+	ResultError       = "error"
+)
+
+// Entry — одна попытка входа
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Login     string    `json:"login"`
+	UserUUID  string    `json:"user_uuid,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	HWID      string    `json:"hwid,omitempty"`
+	Result    string    `json:"result"`
+	Code      int       `json:"code"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Config — файл журнала и срок хранения
+type Config struct {
+	Path    string        // data/audit.jsonl; архивы — рядом: audit-20060102T150405Z.jsonl
+	MaxSize int64         // размер файла, после которого он уходит в архив; 0 — без ограничения
+	MaxAge  time.Duration // сколько хранить архивы; 0 — всегда
+}
+
+// Filter — условия выборки; пустые поля не ограничивают.
+// From включительно, To не включительно.
+type Filter struct {
+	Login string // без учёта регистра
+	// UserUUID — попытки аккаунта под любым его прежним логином. Записи без UUID
+	// (отказ до поиска пользователя: блокировка IP, перебор) отбираются по Login.
+	UserUUID string
+	Result   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case !f.matchUser(e),
+		f.Result != "" && e.Result != f.Result,
+		!f.From.IsZero() && e.Time.Before(f.From),
+		!f.To.IsZero() && !e.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// matchUser — отбор по UserUUID и Login (см. Filter)
+func (f Filter) matchUser(e Entry) bool {
+	if f.UserUUID != "" && e.UserUUID != "" {
+		return e.UserUUID == f.UserUUID
+	}
+	if f.UserUUID != "" && f.Login == "" {
+		return false
+	}
+	return f.Login == "" || strings.EqualFold(e.Login, f.Login)
+}
+
+type Log struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	started time.Time // время первой записи в текущем файле
+
+	// rot защищает набор файлов: ротация и удаление архивов — под Lock,
+	// Query открывает файлы под RLock и читает их уже без блокировок
+	rot sync.RWMutex
+}
+
+func New(cfg Config) *Log {
+	return &Log{cfg: cfg, now: time.Now}
+}
+
+// Record дописывает попытку в журнал. Пустое время заполняется текущим.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = l.now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.size > 0 && l.rotationDue(e.Time, int64(len(line))) {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	// одна запись O_APPEND — строка не перемешается с другими даже при сбое
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if l.started.IsZero() {
+		l.started = e.Time
+	}
+	return err
+}
+
+// open открывает текущий файл и запоминает его размер и время первой записи
+func (l *Log) open() error {
+	if l.f != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.cfg.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size, l.started = f, info.Size(), time.Time{}
+	if l.size > 0 {
+		l.started = firstEntryTime(l.cfg.Path)
+	}
+	l.prune()
+	return nil
+}
+
+// rotationDue — текущий файл пора отправить в архив перед записью add байт
+func (l *Log) rotationDue(now time.Time, add int64) bool {
+	if l.cfg.MaxSize > 0 && l.size+add > l.cfg.MaxSize {
+		return true
+	}
+	y1, m1, d1 := l.started.UTC().Date()
+	y2, m2, d2 := now.UTC().Date()
+	return !l.started.IsZero() && (y1 != y2 || m1 != m2 || d1 != d2)
+}
+
+// rotate переименовывает текущий файл в архив и удаляет устаревшие архивы
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	l.rot.Lock()
+	err := os.Rename(l.cfg.Path, l.archiveName(l.now().UTC()))
+	l.rot.Unlock()
+	if err != nil {
+		return err
+	}
+	l.prune()
+	return nil
+}
+
+func (l *Log) archiveName(t time.Time) string {
+	ext := filepath.Ext(l.cfg.Path)
+	base := strings.TrimSuffix(l.cfg.Path, ext)
+	name := base + "-" + t.Format("20060102T150405Z") + ext
+	// две ротации в одну секунду (маленький MaxSize) не должны затереть архив
+	for i := 1; fileExists(name); i++ {
+		name = base + "-" + t.Format("20060102T150405Z") + "." + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+// prune удаляет архивы, последняя запись в которых старше MaxAge
+func (l *Log) prune() {
+	if l.cfg.MaxAge <= 0 {
+		return
+	}
+	cutoff := l.now().Add(-l.cfg.MaxAge)
+	l.rot.Lock()
+	defer l.rot.Unlock()
+	for _, path := range l.archives() {
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(path)
+		}
+	}
+}
+
+// archives — архивные файлы от старых к новым
+func (l *Log) archives() []string {
+	ext := filepath.Ext(l.cfg.Path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(l.cfg.Path, ext) + "-*" + ext)
+	sort.Strings(matches)
+	return matches
+}
+
+// Query возвращает записи по фильтру, новые первыми. Запись в журнал не ждёт чтения:
+// файлы читаются от новых к старым без блокировки Record, и чтение останавливается,
+// когда набрано f.Limit записей.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	files, err := l.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	var out []Entry
+	for _, file := range files {
+		entries, err := readEntries(file, f)
+		if err != nil {
+			return nil, err
+		}
+		// внутри файла записи идут от старых к новым
+		slices.Reverse(entries)
+		out = append(out, entries...)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			return out[:f.Limit], nil
+		}
+	}
+	return out, nil
+}
+
+// openFiles открывает текущий файл и архивы, от новых к старым. Открытый файл
+// можно дочитать, даже если после этого его переименует ротация.
+func (l *Log) openFiles() ([]*os.File, error) {
+	l.rot.RLock()
+	defer l.rot.RUnlock()
+	paths := append(l.archives(), l.cfg.Path)
+	slices.Reverse(paths)
+	var files []*os.File
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// readEntries возвращает подходящие записи файла в порядке записи.
+// Нечитаемые строки (оборванная запись при сбое или дописываемая сейчас) пропускаются.
+func readEntries(file *os.File, f Filter) ([]Entry, error) {
+	var out []Entry
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out, sc.Err()
+}
+
+// firstEntryTime — время первой записи файла (для суточной ротации после перезапуска)
+func firstEntryTime(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadBytes('\n')
+	var e Entry
+	if json.Unmarshal(line, &e) != nil {
+		return time.Time{}
+	}
+	return e.Time
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndQuery(t *testing.T) {
+	l := New(Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer l.Close()
+	base := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, Login: "Steve", Result: ResultBadPassword, Code: 401},
+		{Time: base.Add(time.Minute), Login: "steve", Result: ResultSuccess, Code: 200},
+		{Time: base.Add(2 * time.Minute), Login: "Alex", Result: ResultNotFound, Code: 404},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := l.Query(Filter{Login: "STEVE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Result != ResultSuccess {
+		t.Fatalf("expected 2 entries for steve, newest first: %+v", got)
+	}
+	got, _ = l.Query(Filter{From: base.Add(time.Minute), To: base.Add(2 * time.Minute)})
+	if len(got) != 1 || got[0].Login != "steve" {
+		t.Errorf("expected to to be exclusive: %+v", got)
+	}
+	got, _ = l.Query(Filter{Result: ResultNotFound})
+	if len(got) != 1 || got[0].Login != "Alex" {
+		t.Errorf("unexpected result filter: %+v", got)
+	}
+	got, _ = l.Query(Filter{Limit: 1})
+	if len(got) != 1 || got[0].Login != "Alex" {
+		t.Errorf("expected only the newest entry: %+v", got)
+	}
+}
+
+func TestRotationAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	l := New(Config{Path: path, MaxSize: 300, MaxAge: 24 * time.Hour})
+	l.now = func() time.Time { return now }
+	defer l.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := l.Record(Entry{Time: now, Login: "Steve", Result: ResultSuccess, Code: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archives := l.archives()
+	if len(archives) == 0 {
+		t.Fatal("expected the file to be archived by size")
+	}
+	if got, _ := l.Query(Filter{}); len(got) != 5 {
+		t.Errorf("archives must be queried too, got %d entries", len(got))
+	}
+
+	// на следующие сутки текущий файл уходит в архив, старые архивы удаляются
+	old := now.Add(-48 * time.Hour)
+	for _, a := range archives {
+		os.Chtimes(a, old, old)
+	}
+	now = now.Add(24 * time.Hour)
+	l.Record(Entry{Time: now, Login: "Alex", Result: ResultSuccess, Code: 200})
+	for _, a := range archives {
+		if _, err := os.Stat(a); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be pruned", a)
+		}
+	}
+	got, _ := l.Query(Filter{})
+	if len(got) == 0 || got[0].Login != "Alex" {
+		t.Errorf("unexpected entries after rotation: %+v", got)
+	}
+}
+
+func TestQueryByUserUUID(t *testing.T) {
+	l := New(Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer l.Close()
+	for _, e := range []Entry{
+		{Login: "Steve", UserUUID: "uuid-1", Result: ResultSuccess},      // до переименования
+		{Login: "Steve", UserUUID: "uuid-2", Result: ResultSuccess},      // новый аккаунт со старым логином
+		{Login: "Steve2", Result: ResultIPBlocked},                       // отказ до поиска пользователя
+		{Login: "Steve2", UserUUID: "uuid-1", Result: ResultBadPassword}, // после переименования
+		{Login: "Alex", Result: ResultIPBlocked},
+	} {
+		l.Record(e)
+	}
+	got, _ := l.Query(Filter{Login: "steve2", UserUUID: "uuid-1"})
+	if len(got) != 3 || got[0].Result != ResultBadPassword || got[2].Login != "Steve" {
+		t.Errorf("expected history across rename: %+v", got)
+	}
+	if got, _ := l.Query(Filter{UserUUID: "uuid-1"}); len(got) != 2 {
+		t.Errorf("expected only entries with UUID: %+v", got)
+	}
+}
+
+func TestQueryDoesNotBlockRecord(t *testing.T) {
+	l := New(Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer l.Close()
+	if err := l.Record(Entry{Login: "Steve", Result: ResultSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	// запись, которая сейчас идёт, не мешает чтению
+	l.mu.Lock()
+	done := make(chan []Entry)
+	go func() {
+		got, _ := l.Query(Filter{})
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		if len(got) != 1 {
+			t.Errorf("expected 1 entry, got %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("query waits for the write lock")
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gml-auth/apikey"
+	"gml-auth/config"
+	"gml-auth/models"
+	"gml-auth/recovery"
+	"gml-auth/session"
+	"gml-auth/storage"
+	"gml-auth/yggdrasil"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const cliUsage = `Использование:
+  gml-auth                                     запустить сервер
+  gml-auth apikey create -name NAME -scopes S  выпустить API ключ для /admin/
+  gml-auth apikey list                         список ключей
+  gml-auth apikey revoke ID                    отозвать ключ
+  gml-auth storage migrate -from json -to sqlite
+                                               скопировать пользователей между хранилищами
+                                               (пути: -from-path, -to-path)
+  gml-auth backup list                         резервные копии users.json
+  gml-auth backup create                       сделать копию сейчас
+  gml-auth backup restore NAME                 восстановить users.json из копии
+  gml-auth uuid offline NAME                   UUID игрока в offline-mode сервере
+  gml-auth uuid migrate [-map FILE] [-out FILE] [-apply]
+                                               сравнить UUID с offline (или с файлом
+                                               соответствий login,uuid) и заменить их.
+                                               Запускайте при остановленном сервере: сессии,
+                                               токены Yggdrasil и коды сброса пароля прежних
+                                               UUID отзываются, журнал входов остаётся как есть
+`
+
+// runCLI выполняет служебную команду вместо запуска сервера и возвращает код выхода
+func runCLI(args []string) int {
+	switch args[0] {
+	case "apikey":
+		return runAPIKey(args[1:])
+	case "storage":
+		return runStorage(args[1:])
+	case "backup":
+		return runBackup(args[1:])
+	case "uuid":
+		return runUUID(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runAPIKey(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	keys := apikey.New(storage.NewDocument[apikey.State](apiKeysPath))
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := fs.String("name", "", "название ключа (например, web-panel)")
+		scopes := fs.String("scopes", "", "области через запятую: * или "+strings.Join(apikey.Scopes, ","))
+		ttl := fs.Duration("ttl", 0, "срок действия (например, 720h); 0 — бессрочно")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *name == "" || *scopes == "" {
+			fmt.Fprintln(os.Stderr, "нужны -name и -scopes")
+			return 2
+		}
+		token, key, err := keys.Create(*name, splitList(*scopes), *ttl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Ключ %s (%s) создан. Сохраните его — повторно он не показывается:\n\n  %s\n\n", key.ID, key.Name, token)
+		fmt.Println("Передавайте его в заголовке: Authorization: Bearer <ключ>")
+		return 0
+
+	case "list":
+		list, err := keys.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tEXPIRES\tLAST USED")
+		for _, k := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Name, strings.Join(k.Scopes, ","), formatTime(k.ExpiresAt), formatTime(k.LastUsedAt))
+		}
+		tw.Flush()
+		return 0
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "нужен ID ключа")
+			return 2
+		}
+		if err := keys.Revoke(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Ключ %s отозван\n", args[1])
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда apikey %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runStorage(args []string) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("storage migrate", flag.ContinueOnError)
+	from := fs.String("from", storage.BackendJSON, "откуда: json или sqlite")
+	to := fs.String("to", storage.BackendSQLite, "куда: json или sqlite")
+	fromPath := fs.String("from-path", "", "файл источника (по умолчанию data/users.json или data/users.db)")
+	toPath := fs.String("to-path", "", "файл назначения")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *fromPath == "" {
+		*fromPath = storage.DefaultPath(*from)
+	}
+	if *toPath == "" {
+		*toPath = storage.DefaultPath(*to)
+	}
+	if *fromPath == *toPath {
+		fmt.Fprintln(os.Stderr, "источник и назначение совпадают")
+		return 2
+	}
+
+	src, err := storage.Open(*from, *fromPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	defer closeStore(src)
+	dst, err := storage.Open(*to, *toPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	defer closeStore(dst)
+
+	copied, skipped, err := storage.Copy(dst, src)
+	fmt.Printf("%s → %s: скопировано %d, пропущено (логин уже есть) %d\n", *fromPath, *toPath, copied, skipped)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	if *to != storage.BackendJSON {
+		fmt.Printf("Чтобы сервер использовал новое хранилище, укажите в config.json: \"storage\": {\"backend\": %q}\n", *to)
+	}
+	return 0
+}
+
+func runBackup(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	cfg, err := config.Load("config.json")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "ошибка: config.json: %v\n", err)
+		return 1
+	}
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	defer closeStore(store)
+	bs, ok := store.(storage.BackupStore)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "резервные копии есть только у JSON хранилища")
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		list, err := bs.Backups()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSIZE\tCREATED")
+		for _, b := range list {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", b.Name, b.Size, formatTime(b.CreatedAt))
+		}
+		tw.Flush()
+		return 0
+
+	case "create":
+		b, err := bs.CreateBackup()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Копия %s создана\n", b.Name)
+		return 0
+
+	case "restore":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "нужно имя копии (gml-auth backup list)")
+			return 2
+		}
+		if err := bs.RestoreBackup(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		fmt.Printf("users.json восстановлен из %s. Прежняя версия тоже сохранена в копию.\n", args[1])
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда backup %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runUUID(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	switch args[0] {
+	case "offline":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "нужно имя игрока")
+			return 2
+		}
+		fmt.Println(storage.OfflineUUID(args[1]))
+		return 0
+	case "migrate":
+		return runUUIDMigrate(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда uuid %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// runUUIDMigrate — без -apply только показывает, какие UUID изменятся
+func runUUIDMigrate(args []string) int {
+	flags := flag.NewFlagSet("uuid migrate", flag.ContinueOnError)
+	mapPath := flags.String("map", "", "CSV login,uuid с нужными UUID; пусто — offline UUID из логина")
+	outPath := flags.String("out", "", "записать CSV login,old_uuid,new_uuid для переноса данных на сервере")
+	apply := flags.Bool("apply", false, "заменить UUID в хранилище и отозвать сессии и токены прежних UUID")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	target := func(u models.User) (string, bool) { return storage.OfflineUUID(u.Login), true }
+	if *mapPath != "" {
+		f, err := os.Open(*mapPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+			return 1
+		}
+		mapping, err := storage.ReadUUIDMap(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %s: %v\n", *mapPath, err)
+			return 1
+		}
+		target = func(u models.User) (string, bool) {
+			id, ok := mapping[storage.NormalizeLogin(u.Login)]
+			return id, ok
+		}
+	}
+
+	cfg, err := config.Load("config.json")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "ошибка: config.json: %v\n", err)
+		return 1
+	}
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	defer closeStore(store)
+
+	changes, err := storage.PlanUUIDs(store, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOGIN\tCURRENT\tNEW")
+	for _, c := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Login, c.Old, c.New)
+	}
+	tw.Flush()
+	fmt.Printf("Отличается UUID у %d пользователей\n", len(changes))
+
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err == nil {
+			err = storage.WriteUUIDChanges(f, changes)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ошибка: %s: %v\n", *outPath, err)
+			return 1
+		}
+		fmt.Printf("Соответствия записаны в %s\n", *outPath)
+	}
+	if !*apply {
+		if len(changes) > 0 {
+			fmt.Println("Это проверка. Чтобы заменить UUID, добавьте -apply (остановите сервер и сделайте копию данных):")
+			fmt.Println("сессии, токены Yggdrasil и коды сброса пароля прежних UUID будут отозваны, игрокам придётся войти заново.")
+		}
+		return 0
+	}
+	applied, failed := storage.ApplyUUIDs(store, changes)
+	for login, err := range failed {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", login, err)
+	}
+	fmt.Printf("Заменено %d, с ошибкой %d\n", applied, len(failed))
+	var old []string
+	for _, c := range changes {
+		if _, ok := failed[c.Login]; !ok {
+			old = append(old, c.Old)
+		}
+	}
+	if err := revokeByUUID(old); err != nil {
+		fmt.Fprintf(os.Stderr, "ошибка: %v\n", err)
+		return 1
+	}
+	if len(old) > 0 {
+		fmt.Println("Сессии, токены Yggdrasil и коды сброса пароля прежних UUID отозваны: игрокам нужно войти заново.")
+	}
+	if len(failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// revokeByUUID удаляет из файлов состояния сервера всё, что привязано к прежним UUID
+// и после смены осталось бы ничьим: сессии, токены Yggdrasil, коды сброса пароля
+// и подтверждения привязки. Отсутствующие файлы не создаются.
+func revokeByUUID(uuids []string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	sessions := session.New(session.Config{}, storage.NewDocument[session.State](sessionsPath))
+	tokens := storage.NewDocument[yggdrasil.TokenState](yggTokensPath)
+	resets := recovery.New(recovery.Config{}, storage.NewDocument[recovery.State](resetsPath))
+	stores := []struct {
+		path   string
+		revoke func(userUUID string) error
+	}{
+		{sessionsPath, func(id string) error { _, err := sessions.RevokeUser(id); return err }},
+		{yggTokensPath, func(id string) error { return yggdrasil.RevokeUserTokens(tokens, id) }},
+		{resetsPath, resets.CancelUser},
+	}
+	for _, st := range stores {
+		if _, err := os.Stat(st.path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		for _, id := range uuids {
+			if err := st.revoke(id); err != nil {
+				return fmt.Errorf("%s: %w", st.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// closeStore закрывает хранилище, если ему это нужно (SQLite)
+func closeStore(s storage.UserStore) {
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"time"
 )
 
 type TelegramConfig struct {
@@ -15,24 +16,262 @@ type DiscordConfig struct {
 	Channel string `json:"channel"`
 }
 
+// NewsSourceConfig — источник новостей: /api/news/{name}
+type NewsSourceConfig struct {
+	Type           string `json:"type"` // telegram, discord
+	Name           string `json:"name"`
+	Token          string `json:"token"`
+	Channel        string `json:"channel"`
+	RefreshSeconds int    `json:"refresh_seconds"`   // 0 — news.refresh_seconds
+	Enabled        *bool  `json:"enabled,omitempty"` // nil — включён
+}
+
+// IsEnabled — источник включён: "enabled" не задан или true
+func (s NewsSourceConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// NewsFeedConfig — объединённая лента /api/news/{name} из перечисленных источников
+type NewsFeedConfig struct {
+	Name    string   `json:"name"`
+	Sources []string `json:"sources"`
+}
+
 type NewsConfig struct {
-	RefreshSeconds int            `json:"refresh_seconds"`
-	Telegram       TelegramConfig `json:"telegram"`
-	Discord        DiscordConfig  `json:"discord"`
+	RefreshSeconds int `json:"refresh_seconds"`
+	// Telegram и Discord — прежний формат с одним источником каждого типа;
+	// используются, только если sources пуст
+	Telegram TelegramConfig     `json:"telegram"`
+	Discord  DiscordConfig      `json:"discord"`
+	Sources  []NewsSourceConfig `json:"sources"`
+	Feeds    []NewsFeedConfig   `json:"feeds"`
+}
+
+// SourceList — источники из sources или, если их нет, из telegram и discord
+// (с именами telegram и discord, включены при заданном токене)
+func (c NewsConfig) SourceList() []NewsSourceConfig {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	var out []NewsSourceConfig
+	if c.Telegram.Token != "" {
+		out = append(out, NewsSourceConfig{Type: "telegram", Name: "telegram", Token: c.Telegram.Token, Channel: c.Telegram.Channel})
+	}
+	if c.Discord.Token != "" {
+		out = append(out, NewsSourceConfig{Type: "discord", Name: "discord", Token: c.Discord.Token, Channel: c.Discord.Channel})
+	}
+	return out
+}
+
+// BotToken — токен первого источника типа typ (для ботов, которые пишут игрокам)
+func (c NewsConfig) BotToken(typ string) string {
+	for _, s := range c.SourceList() {
+		if s.Type == typ && s.Token != "" {
+			return s.Token
+		}
+	}
+	return ""
+}
+
+// BruteForceConfig — защита signin от перебора паролей
+type BruteForceConfig struct {
+	WindowSeconds    int `json:"window_seconds"`
+	MaxLoginFailures int `json:"max_login_failures"`
+	MaxIPFailures    int `json:"max_ip_failures"`
+	LockoutSeconds   int `json:"lockout_seconds"`
+	DelaySeconds     int `json:"delay_seconds"`
+	MaxDelaySeconds  int `json:"max_delay_seconds"`
+}
+
+// AuditConfig — журнал попыток входа (JSON Lines, только дописывается)
+type AuditConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"max_size_mb"`  // размер файла до переноса в архив; 0 — без ограничения
+	MaxAgeDays int    `json:"max_age_days"` // сколько хранить архивы; 0 — всегда
+}
+
+// PolicyConfig — кому разрешено действие: участникам групп или перечисленным логинам.
+// Пустые списки — разрешено всем.
+type PolicyConfig struct {
+	Groups []string `json:"groups"`
+	Logins []string `json:"logins"`
+}
+
+// HWIDConfig — привязка аккаунтов к устройствам (HWID передаёт лаунчер)
+type HWIDConfig struct {
+	MaxAccounts int `json:"max_accounts"` // аккаунтов на одно устройство; 0 — без ограничения
+}
+
+// PasswordConfig — требования к новым паролям (создание, смена, регистрация, сброс)
+type PasswordConfig struct {
+	MinLength     int  `json:"min_length"`
+	RequireLower  bool `json:"require_lower"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// DenyListPath — файл с распространёнными паролями, по одному в строке; пусто — без списка
+	DenyListPath  string `json:"deny_list_path"`
+	DisallowLogin bool   `json:"disallow_login"`
+}
+
+type SecurityConfig struct {
+	BruteForce BruteForceConfig `json:"brute_force"`
+	Audit      AuditConfig      `json:"audit"`
+	HWID       HWIDConfig       `json:"hwid"`
+	Password   PasswordConfig   `json:"password"`
+	// SignIn — кто может входить (например, только группа tester на закрытом тесте)
+	SignIn PolicyConfig `json:"signin"`
+	// TrustedProxies — адреса и подсети обратных прокси (GML backend, nginx),
+	// от которых принимаются X-Forwarded-For и X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// SessionsConfig — выдача access/refresh токенов при входе
+type SessionsConfig struct {
+	Enabled           bool `json:"enabled"`
+	AccessTTLSeconds  int  `json:"access_ttl_seconds"`
+	RefreshTTLSeconds int  `json:"refresh_ttl_seconds"`
+}
+
+// YggdrasilConfig — Yggdrasil API для authlib-injector (/yggdrasil/)
+type YggdrasilConfig struct {
+	Enabled     bool     `json:"enabled"`
+	ServerName  string   `json:"server_name"`
+	Homepage    string   `json:"homepage"`
+	SkinDomains []string `json:"skin_domains"`
+}
+
+// TexturesConfig — хранение скинов и плащей
+type TexturesConfig struct {
+	// PublicURL — внешний адрес сервера для ссылок на текстуры
+	// (например, http://mc.example.com:5003). Пусто — http://127.0.0.1:<порт>.
+	PublicURL string `json:"public_url"`
+}
+
+// StorageConfig — где хранятся пользователи
+type StorageConfig struct {
+	Backend string        `json:"backend"` // json или sqlite
+	Path    string        `json:"path"`    // пусто — data/users.json или data/users.db
+	Backups BackupsConfig `json:"backups"`
+	// UUIDStrategy — UUID новых аккаунтов: random (v4), offline (как у сервера
+	// в offline-mode) или imported (UUID передаётся при создании)
+	UUIDStrategy string `json:"uuid_strategy"`
+}
+
+// BackupsConfig — резервные копии users.json перед записью (только для json)
+type BackupsConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Dir             string `json:"dir"`
+	IntervalSeconds int    `json:"interval_seconds"` // не чаще одной копии за интервал
+	Keep            int    `json:"keep"`             // 0 — без ограничения
+	MaxAgeDays      int    `json:"max_age_days"`     // 0 — без ограничения
+}
+
+// MaintenanceConfig — технические работы: вход только для allow_groups и allow_logins.
+// Настройки из admin API (/admin/maintenance) действуют поверх этих.
+type MaintenanceConfig struct {
+	Enabled     bool      `json:"enabled"`
+	Message     string    `json:"message"`
+	StartsAt    time.Time `json:"starts_at,omitzero"` // RFC 3339; пусто — сразу
+	EndsAt      time.Time `json:"ends_at,omitzero"`
+	AllowGroups []string  `json:"allow_groups"`
+	AllowLogins []string  `json:"allow_logins"`
+}
+
+// RegistrationConfig — самостоятельная регистрация игроков по кодам приглашений
+// (POST /api/v1/register). Коды выпускаются через /admin/invites.
+type RegistrationConfig struct {
+	Enabled       bool `json:"enabled"`
+	MaxPerIP      int  `json:"max_per_ip"`     // регистраций с одного адреса за окно; 0 — без ограничения
+	WindowSeconds int  `json:"window_seconds"` // окно для max_per_ip
+}
+
+// RecoveryConfig — сброс пароля одноразовым кодом через ботов Telegram и Discord из news
+type RecoveryConfig struct {
+	Enabled        bool `json:"enabled"`
+	CodeTTLSeconds int  `json:"code_ttl_seconds"`
+	ResendSeconds  int  `json:"resend_seconds"` // не чаще одного кода за интервал
+	MaxAttempts    int  `json:"max_attempts"`   // неверных вводов до сгорания кода
 }
 
 type Config struct {
-	News NewsConfig `json:"news"`
+	News         NewsConfig         `json:"news"`
+	Security     SecurityConfig     `json:"security"`
+	Sessions     SessionsConfig     `json:"sessions"`
+	Yggdrasil    YggdrasilConfig    `json:"yggdrasil"`
+	Textures     TexturesConfig     `json:"textures"`
+	Storage      StorageConfig      `json:"storage"`
+	Maintenance  MaintenanceConfig  `json:"maintenance"`
+	Registration RegistrationConfig `json:"registration"`
+	Recovery     RecoveryConfig     `json:"recovery"`
+}
+
+// Default — настройки, которые действуют для полей, отсутствующих в config.json
+func Default() Config {
+	return Config{
+		News: NewsConfig{RefreshSeconds: 60},
+		Security: SecurityConfig{
+			BruteForce: BruteForceConfig{
+				WindowSeconds:    900,
+				MaxLoginFailures: 5,
+				MaxIPFailures:    30,
+				LockoutSeconds:   900,
+				DelaySeconds:     1,
+				MaxDelaySeconds:  30,
+			},
+			Audit: AuditConfig{
+				Enabled:    true,
+				Path:       "data/audit.jsonl",
+				MaxSizeMB:  10,
+				MaxAgeDays: 90,
+			},
+			Password: PasswordConfig{
+				MinLength:     8,
+				DenyListPath:  "data/common_passwords.txt",
+				DisallowLogin: true,
+			},
+		},
+		Sessions: SessionsConfig{
+			AccessTTLSeconds:  15 * 60,
+			RefreshTTLSeconds: 30 * 24 * 60 * 60,
+		},
+		Yggdrasil: YggdrasilConfig{
+			ServerName: "GML Auth",
+		},
+		Registration: RegistrationConfig{
+			MaxPerIP:      3,
+			WindowSeconds: 24 * 60 * 60,
+		},
+		Recovery: RecoveryConfig{
+			CodeTTLSeconds: 15 * 60,
+			ResendSeconds:  60,
+			MaxAttempts:    5,
+		},
+		Storage: StorageConfig{
+			Backend:      "json",
+			UUIDStrategy: "random",
+			Backups: BackupsConfig{
+				Enabled:         true,
+				Dir:             "data/backups",
+				IntervalSeconds: 60 * 60,
+				Keep:            48,
+				MaxAgeDays:      30,
+			},
+		},
+	}
 }
 
+// Load читает config.json поверх значений по умолчанию.
+// При ошибке возвращаются значения по умолчанию, чтобы сервер мог работать без файла.
 func Load(path string) (Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return Config{}, err
+		return Default(), err
 	}
-	var cfg Config
+	cfg := Default()
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return Config{}, err
+		return Default(), err
 	}
 	if cfg.News.RefreshSeconds == 0 {
 		cfg.News.RefreshSeconds = 60
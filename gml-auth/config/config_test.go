@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
 )
@@ -64,3 +65,67 @@ func TestLoadConfigMissing(t *testing.T) {
 	}
 	_ = cfg
 }
+
+func TestLoadConfigSecurityDefaults(t *testing.T) {
+	f, err := os.CreateTemp("", "cfg-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"security":{"brute_force":{"max_login_failures":3}}}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cfg, err := Load(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf := cfg.Security.BruteForce
+	if bf.MaxLoginFailures != 3 {
+		t.Errorf("max_login_failures: got %d", bf.MaxLoginFailures)
+	}
+	if bf.LockoutSeconds != 900 {
+		t.Errorf("expected default lockout 900, got %d", bf.LockoutSeconds)
+	}
+
+	missing, _ := Load("/nonexistent/config.json")
+	if missing.News.RefreshSeconds != 60 {
+		t.Errorf("expected defaults for missing file, got %d", missing.News.RefreshSeconds)
+	}
+}
+
+func TestNewsSourceList(t *testing.T) {
+	legacy := NewsConfig{
+		Telegram: TelegramConfig{Token: "tg-tok", Channel: "@test"},
+	}
+	list := legacy.SourceList()
+	if len(list) != 1 || list[0].Name != "telegram" || list[0].Type != "telegram" || !list[0].IsEnabled() {
+		t.Fatalf("unexpected legacy sources: %+v", list)
+	}
+	if legacy.BotToken("telegram") != "tg-tok" || legacy.BotToken("discord") != "" {
+		t.Error("unexpected bot tokens for legacy config")
+	}
+
+	cfg := NewsConfig{
+		Telegram: TelegramConfig{Token: "ignored"},
+		Sources: []NewsSourceConfig{
+			{Type: "discord", Name: "dc-main", Token: "dc-tok", Channel: "1"},
+			{Type: "telegram", Name: "tg-events", Token: "tg-2", Channel: "@events"},
+		},
+	}
+	if list := cfg.SourceList(); len(list) != 2 || list[0].Name != "dc-main" {
+		t.Fatalf("sources must replace legacy fields: %+v", list)
+	}
+	if cfg.BotToken("telegram") != "tg-2" {
+		t.Errorf("bot token must come from sources, got %q", cfg.BotToken("telegram"))
+	}
+
+	var sources []NewsSourceConfig
+	if err := json.Unmarshal([]byte(`[{"name":"on"},{"name":"off","enabled":false},{"name":"explicit","enabled":true}]`), &sources); err != nil {
+		t.Fatal(err)
+	}
+	if !sources[0].IsEnabled() || sources[1].IsEnabled() || !sources[2].IsEnabled() {
+		t.Errorf("source without enabled must be on, enabled:false off: %+v", sources)
+	}
+}
@@ -0,0 +1,180 @@
+// Package groups — справочник групп пользователей (admin, moderator, vip, tester ...)
+// и правила доступа, которые на них ссылаются. Членство хранится у самого
+// пользователя (models.User.Groups), здесь — только описания групп.
+package groups
+
+import (
+	"errors"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("group not found")
+	ErrExists      = errors.New("group already exists")
+	ErrInvalidName = errors.New("invalid group name")
+)
+
+var nameRe = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ValidName — имя группы: 1–32 символа, строчные латинские буквы, цифры, _ и -
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+// Group — описание группы
+type Group struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// State — содержимое data/groups.json
+type State struct {
+	Groups []*Group `json:"groups"`
+}
+
+type Store struct {
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func New(doc *storage.Document[State]) *Store {
+	return &Store{doc: doc, now: time.Now}
+}
+
+// List — все группы по имени
+func (s *Store) List() ([]Group, error) {
+	var out []Group
+	err := s.doc.Read(func(st *State) {
+		for _, g := range st.Groups {
+			out = append(out, *g)
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, err
+}
+
+func (s *Store) Get(name string) (Group, error) {
+	var (
+		group Group
+		found bool
+	)
+	err := s.doc.Read(func(st *State) {
+		if i := index(st, name); i >= 0 {
+			group, found = *st.Groups[i], true
+		}
+	})
+	if err != nil {
+		return Group{}, err
+	}
+	if !found {
+		return Group{}, ErrNotFound
+	}
+	return group, nil
+}
+
+func (s *Store) Create(name, description string) (Group, error) {
+	if !ValidName(name) {
+		return Group{}, ErrInvalidName
+	}
+	group := Group{Name: name, Description: description, CreatedAt: s.now().UTC()}
+	err := s.doc.Update(func(st *State) error {
+		if index(st, name) >= 0 {
+			return ErrExists
+		}
+		g := group
+		st.Groups = append(st.Groups, &g)
+		return nil
+	})
+	return group, err
+}
+
+// Describe меняет описание группы
+func (s *Store) Describe(name, description string) (Group, error) {
+	var group Group
+	err := s.doc.Update(func(st *State) error {
+		i := index(st, name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		st.Groups[i].Description = description
+		group = *st.Groups[i]
+		return nil
+	})
+	return group, err
+}
+
+// Delete удаляет описание группы. Членство у пользователей снимает вызывающий (RemoveMembers).
+func (s *Store) Delete(name string) error {
+	return s.doc.Update(func(st *State) error {
+		i := index(st, name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		st.Groups = slices.Delete(st.Groups, i, i+1)
+		return nil
+	})
+}
+
+func index(st *State, name string) int {
+	return slices.IndexFunc(st.Groups, func(g *Group) bool { return g.Name == name })
+}
+
+// RemoveMembers исключает из группы name всех пользователей и возвращает их число
+func RemoveMembers(users storage.UserStore, name string) (int, error) {
+	list, err := users.ListUsers()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range list {
+		if !slices.ContainsFunc(u.Groups, func(g models.UserGroup) bool { return g.Name == name }) {
+			continue
+		}
+		err := users.UpdateUser(u.Login, func(u *models.User) error {
+			u.Groups = slices.DeleteFunc(u.Groups, func(g models.UserGroup) bool { return g.Name == name })
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Policy — правило доступа: разрешено участникам групп Groups и логинам Logins.
+// Пустое правило никого не ограничивает.
+type Policy struct {
+	Groups []string
+	Logins []string
+}
+
+// Empty — правило ничего не ограничивает
+func (p Policy) Empty() bool {
+	return len(p.Groups) == 0 && len(p.Logins) == 0
+}
+
+// Allows — пользователь входит в список логинов или в одну из групп (с учётом срока членства)
+func (p Policy) Allows(u models.User, now time.Time) bool {
+	if p.Empty() {
+		return true
+	}
+	for _, login := range p.Logins {
+		if strings.EqualFold(login, u.Login) {
+			return true
+		}
+	}
+	for _, g := range p.Groups {
+		if u.InGroup(g, now) {
+			return true
+		}
+	}
+	return false
+}
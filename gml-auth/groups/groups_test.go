@@ -0,0 +1,67 @@
+package groups
+
+import (
+	"errors"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s := New(storage.NewDocument[State](filepath.Join(t.TempDir(), "groups.json")))
+	if _, err := s.Create("VIP", ""); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := s.Create("vip", "платный доступ"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("vip", ""); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+	s.Create("admin", "")
+	if g, _ := s.Describe("vip", "VIP на 30 дней"); g.Description != "VIP на 30 дней" {
+		t.Errorf("description not updated: %+v", g)
+	}
+	list, _ := s.List()
+	if len(list) != 2 || list[0].Name != "admin" {
+		t.Errorf("expected groups sorted by name: %+v", list)
+	}
+	if err := s.Delete("vip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("vip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRemoveMembers(t *testing.T) {
+	users := storage.New(filepath.Join(t.TempDir(), "users.json"))
+	users.AddUser(models.User{UUID: "u1", Login: "Steve", Groups: []models.UserGroup{{Name: "vip"}, {Name: "tester"}}})
+	users.AddUser(models.User{UUID: "u2", Login: "Alex"})
+	n, err := RemoveMembers(users, "vip")
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 member removed, got %d %v", n, err)
+	}
+	if u, _ := users.FindByLogin("Steve"); len(u.Groups) != 1 || u.Groups[0].Name != "tester" {
+		t.Errorf("unexpected groups: %+v", u.Groups)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	vip := models.User{Login: "Steve", Groups: []models.UserGroup{{Name: "vip", ExpiresAt: now.Add(time.Hour)}}}
+	staff := models.User{Login: "Notch"}
+
+	if !(Policy{}).Allows(staff, now) {
+		t.Error("empty policy must allow everyone")
+	}
+	p := Policy{Groups: []string{"vip"}, Logins: []string{"notch"}}
+	if !p.Allows(vip, now) || !p.Allows(staff, now) {
+		t.Error("expected group member and listed login to be allowed")
+	}
+	if p.Allows(vip, now.Add(2*time.Hour)) {
+		t.Error("expired membership must not be allowed")
+	}
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/audit"
+	"gml-auth/ipfilter"
+	"gml-auth/models"
+	"gml-auth/notify"
+	"gml-auth/password"
+	"gml-auth/recovery"
+	"gml-auth/session"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AccountHandler — API игрока для управления своим аккаунтом (/api/v1/account/...).
+// Запросы подписываются access токеном сессии (Authorization: Bearer <token>)
+// или логином и паролем (Authorization: Basic ...), если у аккаунта нет 2FA.
+//
+//	GET    /api/v1/account                 — свой профиль
+//	PATCH  /api/v1/account                 — {"is_slim": true}
+//	PUT    /api/v1/account/password        — {"current_password": "...", "new_password": "..."}
+//	PUT    /api/v1/account/skin|cape       — загрузить текстуру
+//	DELETE /api/v1/account/skin|cape       — удалить текстуру
+//	GET    /api/v1/account/logins          — своя история входов
+//	GET    /api/v1/account/sessions        — свои сессии
+//	DELETE /api/v1/account/sessions        — завершить все сессии, кроме текущей
+//	DELETE /api/v1/account/sessions/{id}   — завершить одну сессию
+//	PUT    /api/v1/account/links/{channel} — привязать Telegram или Discord:
+//	                                         {"id": "123456789", "current_password": "...", "totp": "..."},
+//	                                         затем {"id": "123456789", "code": "..."} с кодом из чата
+//	DELETE /api/v1/account/links/{channel} — отвязать: {"current_password": "...", "totp": "..."}
+type AccountHandler struct {
+	store storage.UserStore
+	deps
+}
+
+func NewAccountHandler(store storage.UserStore, opts ...Option) *AccountHandler {
+	return &AccountHandler{store: store, deps: applyOptions(opts)}
+}
+
+func (h *AccountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, sessionID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	switch path := strings.TrimSuffix(r.URL.Path, "/"); {
+	case path == "/api/v1/account" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, user.Info())
+	case path == "/api/v1/account" && r.Method == http.MethodPatch:
+		h.updateAccount(w, r, user)
+	case path == "/api/v1/account/password" && r.Method == http.MethodPut:
+		h.changePassword(w, r, user, sessionID)
+	case path == "/api/v1/account/skin" && r.Method == http.MethodPut:
+		h.putTexture(w, r, h.store, user.Login, kindSkin)
+	case path == "/api/v1/account/skin" && r.Method == http.MethodDelete:
+		h.deleteTexture(w, h.store, user.Login, kindSkin)
+	case path == "/api/v1/account/cape" && r.Method == http.MethodPut:
+		h.putTexture(w, r, h.store, user.Login, kindCape)
+	case path == "/api/v1/account/cape" && r.Method == http.MethodDelete:
+		h.deleteTexture(w, h.store, user.Login, kindCape)
+	case path == "/api/v1/account/logins" && r.Method == http.MethodGet:
+		h.loginHistory(w, r, user)
+	case path == "/api/v1/account/sessions" && r.Method == http.MethodGet:
+		if h.sessions == nil {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сессии выключены"})
+			return
+		}
+		writeJSON(w, http.StatusOK, h.sessions.List(user.UUID))
+	case path == "/api/v1/account/sessions" && r.Method == http.MethodDelete:
+		h.revokeOtherSessions(user.UUID, sessionID)
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/api/v1/account/sessions/") && r.Method == http.MethodDelete:
+		h.revokeSession(w, user, strings.TrimPrefix(path, "/api/v1/account/sessions/"))
+	case strings.HasPrefix(path, "/api/v1/account/links/") && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		h.linkContact(w, r, user, strings.TrimPrefix(path, "/api/v1/account/links/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// authenticate находит владельца запроса по access токену или по логину и паролю.
+// Вход по паролю проходит те же проверки, что /signin (см. signin.Gate); в журнал пишутся только отказы;
+// для входа по токену повторно проверяются блокировки, технические работы и политика входа.
+// Для входа по токену возвращает ID текущей сессии. При отказе сам пишет ответ.
+func (h *AccountHandler) authenticate(w http.ResponseWriter, r *http.Request) (models.User, string, bool) {
+	unauthorized := func(msg, errCode string) (models.User, string, bool) {
+		challenge := `Bearer realm="gml-auth"`
+		if errCode != "" {
+			challenge += `, error="` + errCode + `"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		w.Header().Add("WWW-Authenticate", `Basic realm="gml-auth", charset="UTF-8"`)
+		writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: msg})
+		return models.User{}, "", false
+	}
+	gate := h.gate(h.store)
+
+	if login, pass, ok := r.BasicAuth(); ok {
+		ip := ipfilter.ClientIP(r)
+		user, _, denial := gate.Check(signin.Attempt{Login: login, Password: pass, IP: ip})
+		if denial != nil {
+			gate.Record(r, denial.Entry)
+		}
+		switch {
+		case denial == nil:
+			// это не вход, а подпись отдельного запроса: время входа и история не меняются
+			return user, "", true
+		case denial.Entry.Result == audit.ResultTOTPNeeded:
+			// код 2FA в Basic не передать
+			writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "У аккаунта включена 2FA: выполните вход с кодом и используйте токен сессии"})
+		case denial.Code == http.StatusUnauthorized, denial.Code == http.StatusNotFound:
+			return unauthorized("Неверный логин или пароль", "")
+		default:
+			if denial.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(denial.RetryAfter.Seconds()))))
+			}
+			writeJSON(w, denial.Code, models.ErrorResponse{Message: denial.Message})
+		}
+		return models.User{}, "", false
+	}
+
+	token := bearerToken(r)
+	if h.sessions == nil || token == "" {
+		return unauthorized("Выполните вход", "")
+	}
+	s, err := h.sessions.Authenticate(token)
+	if err != nil {
+		msg := "Сессия не найдена. Выполните вход."
+		if errors.Is(err, session.ErrExpiredToken) {
+			msg = "Срок действия токена истёк"
+		}
+		return unauthorized(msg, "invalid_token")
+	}
+	user, err := h.store.FindByUUID(s.UserUUID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return unauthorized("Сессия не найдена. Выполните вход.", "invalid_token")
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+		return models.User{}, "", false
+	}
+	if denial := gate.Admit(user); denial != nil {
+		writeJSON(w, denial.Code, models.ErrorResponse{Message: denial.Message})
+		return models.User{}, "", false
+	}
+	return user, s.ID, true
+}
+
+// updateAccount — PATCH /api/v1/account
+func (h *AccountHandler) updateAccount(w http.ResponseWriter, r *http.Request, user models.User) {
+	var req models.AccountUpdateRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса: " + err.Error()})
+		return
+	}
+	var updated models.User
+	err := h.store.UpdateUser(user.Login, func(u *models.User) error {
+		if req.IsSlim != nil {
+			u.IsSlim = *req.IsSlim
+		}
+		updated = *u
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, updated.Info())
+}
+
+// changePassword — PUT /api/v1/account/password. Нужен текущий пароль даже при входе по токену.
+// Остальные сессии завершаются, текущая остаётся.
+func (h *AccountHandler) changePassword(w http.ResponseWriter, r *http.Request, user models.User, sessionID string) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		return
+	}
+	if h.currentPasswordRejected(w, r, user, req.CurrentPassword) {
+		return
+	}
+	h.succeed(user.Login)
+	if h.passwordRejected(w, "new_password", user.Login, req.NewPassword) {
+		return
+	}
+	hashed, err := password.Hash(req.NewPassword)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	err = h.store.UpdateUser(user.Login, func(u *models.User) error {
+		u.Password = hashed
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	h.revokeOtherSessions(user.UUID, sessionID)
+	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Пароль изменён"})
+}
+
+// currentPasswordRejected проверяет текущий пароль владельца (смена пароля, привязка мессенджера)
+// и при отказе отвечает сам. Как и на /signin, попытки ограничены защитой от перебора,
+// а неверный пароль пишется в журнал входов — иначе украденный токен позволил бы подбирать
+// пароль без ограничений. Счётчик неудач сбрасывает вызывающий после всех проверок (succeed).
+func (h *AccountHandler) currentPasswordRejected(w http.ResponseWriter, r *http.Request, user models.User, plain string) bool {
+	ip := ipfilter.ClientIP(r)
+	if h.throttled(w, user.Login, ip) {
+		return true
+	}
+	if ok, _ := password.Verify(user.Password, plain); !ok {
+		h.fail(user.Login, ip)
+		h.gate(h.store).Record(r, audit.Entry{
+			Login: user.Login, UserUUID: user.UUID, Result: audit.ResultBadPassword,
+			Code: http.StatusForbidden, Reason: "Неверный текущий пароль",
+		})
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "Неверный текущий пароль"})
+		return true
+	}
+	return false
+}
+
+// loginHistory — GET /api/v1/account/logins: параметры как у /admin/audit
+func (h *AccountHandler) loginHistory(w http.ResponseWriter, r *http.Request, user models.User) {
+	if h.audit == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Журнал входов выключен"})
+		return
+	}
+	f, msg := parseAuditFilter(r.URL.Query())
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+		return
+	}
+	f.Login, f.UserUUID = user.Login, user.UUID
+	entries, err := h.audit.Query(f)
+	if err != nil {
+		log.Printf("[account] audit: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// revokeSession — DELETE /api/v1/account/sessions/{id}
+func (h *AccountHandler) revokeSession(w http.ResponseWriter, user models.User, id string) {
+	if h.sessions == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сессии выключены"})
+		return
+	}
+	err := h.sessions.Revoke(user.UUID, id)
+	if errors.Is(err, session.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сессия не найдена"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linkContact — PUT/DELETE /api/v1/account/links/{channel}.
+// Нужен текущий пароль (и код 2FA, если она включена): по одному токену сессии
+// нельзя перевести сброс пароля на чужой чат. Привязка идёт в два шага: бот отправляет
+// в указанный чат одноразовый код, и только повторный PUT с этим кодом сохраняет ID —
+// так проверяется, что чат принадлежит игроку и бот сможет доставить код сброса пароля.
+func (h *AccountHandler) linkContact(w http.ResponseWriter, r *http.Request, user models.User, channel string) {
+	sender := h.senders[channel]
+	if h.recovery == nil || sender == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Привязка этого мессенджера выключена"})
+		return
+	}
+	var req models.LinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		return
+	}
+	if r.Method == http.MethodPut && !contactPattern.MatchString(req.ID) {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен числовой id пользователя"})
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut && req.Code != "":
+		if !h.confirmLink(w, user, channel, req) {
+			return
+		}
+	case !h.confirmIdentity(w, r, user, req.CurrentPassword, req.TOTP):
+		return
+	case r.Method == http.MethodPut:
+		h.sendLinkCode(w, user, channel, sender, req.ID)
+		return
+	default:
+		req.ID = "" // DELETE
+	}
+
+	var updated models.User
+	err := h.store.UpdateUser(user.Login, func(u *models.User) error {
+		setContactID(u, channel, req.ID)
+		updated = *u
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, updated.Info())
+}
+
+// linkKey — ключ кода подтверждения привязки в recovery.Store
+func linkKey(userUUID, channel, id string) string {
+	return "link:" + channel + ":" + id + ":" + userUUID
+}
+
+// confirmIdentity проверяет текущий пароль и код 2FA; при неудаче отвечает сам.
+// Неверный код, как и пароль, учитывается защитой от перебора и пишется в журнал входов.
+func (h *AccountHandler) confirmIdentity(w http.ResponseWriter, r *http.Request, user models.User, plain, code string) bool {
+	if h.currentPasswordRejected(w, r, user, plain) {
+		return false
+	}
+	if user.TOTPSecret != "" {
+		if code == "" {
+			writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "Введите код 2FA"})
+			return false
+		}
+		ok, err := h.gate(h.store).VerifySecondFactor(user.Login, code)
+		if err != nil {
+			log.Printf("[account] 2fa %s: %v", user.Login, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+			return false
+		}
+		if !ok {
+			h.fail(user.Login, ipfilter.ClientIP(r))
+			h.gate(h.store).Record(r, audit.Entry{
+				Login: user.Login, UserUUID: user.UUID, Result: audit.ResultBadTOTP,
+				Code: http.StatusForbidden, Reason: "Неверный код 2FA",
+			})
+			writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "Неверный код 2FA"})
+			return false
+		}
+	}
+	h.succeed(user.Login)
+	return true
+}
+
+// sendLinkCode выпускает код подтверждения привязки и отправляет его в чат id
+func (h *AccountHandler) sendLinkCode(w http.ResponseWriter, user models.User, channel string, sender notify.Sender, id string) {
+	key := linkKey(user.UUID, channel, id)
+	code, err := h.recovery.Issue(key, channel)
+	if errors.Is(err, recovery.ErrTooSoon) {
+		writeJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Message: "Код уже отправлен. Повторный запрос возможен через минуту."})
+		return
+	}
+	if err != nil {
+		log.Printf("[account] link code %s: %v", user.Login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+		return
+	}
+	text := "Код для привязки аккаунта " + user.Login + " к этому чату: " + code +
+		"\nЕсли вы его не запрашивали, просто проигнорируйте это сообщение."
+	if err := sender.Send(id, text); err != nil {
+		log.Printf("[account] link %s to %s: %v", user.Login, channel, err)
+		if err := h.recovery.Cancel(key); err != nil {
+			log.Printf("[account] cancel link code %s: %v", user.Login, err)
+		}
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{
+			Message: "Не удалось отправить сообщение. В Telegram начните диалог с ботом (/start), в Discord разрешите личные сообщения от участников сервера",
+		})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, models.ErrorResponse{Message: "Код отправлен в чат. Повторите запрос с этим id и полученным code"})
+}
+
+// confirmLink гасит код подтверждения привязки; при неудаче отвечает сам
+func (h *AccountHandler) confirmLink(w http.ResponseWriter, user models.User, channel string, req models.LinkRequest) bool {
+	err := h.recovery.Verify(linkKey(user.UUID, channel, req.ID), req.Code)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, recovery.ErrTooManyAttempts):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Слишком много неверных попыток. Запросите новый код"})
+	case errors.Is(err, recovery.ErrInvalidCode), errors.Is(err, recovery.ErrExpired):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный или устаревший код"})
+	default:
+		log.Printf("[account] verify link code %s: %v", user.Login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gml-auth/audit"
+	"gml-auth/ipfilter"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func accountRequest(h *AccountHandler, method, path, body string, auth func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if auth != nil {
+		auth(req)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestAccountChangePassword(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	current, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	other, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "other")
+	h := NewAccountHandler(s, WithSessions(sessions))
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+current.AccessToken) }
+
+	w := accountRequest(h, http.MethodPut, "/api/v1/account/password",
+		`{"current_password":"wrong","new_password":"secret1"}`, bearer)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for wrong current password, got %d", w.Code)
+	}
+	w = accountRequest(h, http.MethodPut, "/api/v1/account/password",
+		`{"current_password":"pass123","new_password":""}`, bearer)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty password, got %d", w.Code)
+	}
+	w = accountRequest(h, http.MethodPut, "/api/v1/account/password",
+		`{"current_password":"pass123","new_password":"secret1"}`, bearer)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	user, _ := s.FindByLogin("GamerVII")
+	if ok, _ := password.Verify(user.Password, "secret1"); !ok || user.Password == "secret1" {
+		t.Fatalf("expected new hashed password, got %q", user.Password)
+	}
+	if _, err := sessions.Authenticate(other.AccessToken); err == nil {
+		t.Error("other sessions must be revoked")
+	}
+	if _, err := sessions.Authenticate(current.AccessToken); err != nil {
+		t.Errorf("current session must stay: %v", err)
+	}
+
+	// вход по логину и паролю
+	w = accountRequest(h, http.MethodGet, "/api/v1/account", "", func(r *http.Request) { r.SetBasicAuth("GamerVII", "pass123") })
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for old password, got %d", w.Code)
+	}
+	w = accountRequest(h, http.MethodGet, "/api/v1/account", "", func(r *http.Request) { r.SetBasicAuth("gamervii", "secret1") })
+	var info models.UserInfo
+	json.NewDecoder(w.Body).Decode(&info)
+	if w.Code != http.StatusOK || info.UUID != "uuid-1" {
+		t.Errorf("expected own profile, got %d %+v", w.Code, info)
+	}
+	w = accountRequest(h, http.MethodGet, "/api/v1/account", "", func(r *http.Request) { r.SetBasicAuth("banned", "pass") })
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for blocked user, got %d", w.Code)
+	}
+}
+
+func TestAccountSlimAndSessions(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	current, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "second")
+	sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "third")
+	h := NewAccountHandler(s, WithSessions(sessions))
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+current.AccessToken) }
+
+	w := accountRequest(h, http.MethodPatch, "/api/v1/account", `{"is_slim":true}`, bearer)
+	if user, _ := s.FindByLogin("GamerVII"); w.Code != http.StatusOK || !user.IsSlim {
+		t.Fatalf("expected slim model, got %d %+v", w.Code, user)
+	}
+	w = accountRequest(h, http.MethodPatch, "/api/v1/account", `{"login":"other"}`, bearer)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown field, got %d", w.Code)
+	}
+
+	list := sessions.List("uuid-1")
+	if len(list) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(list))
+	}
+	var victim string
+	for _, info := range list {
+		if info.UserAgent == "second" {
+			victim = info.ID
+		}
+	}
+	w = accountRequest(h, http.MethodDelete, "/api/v1/account/sessions/"+victim, "", bearer)
+	if w.Code != http.StatusNoContent || len(sessions.List("uuid-1")) != 2 {
+		t.Fatalf("expected one session revoked, got %d", w.Code)
+	}
+	w = accountRequest(h, http.MethodDelete, "/api/v1/account/sessions/"+victim, "", bearer)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for revoked session, got %d", w.Code)
+	}
+	w = accountRequest(h, http.MethodDelete, "/api/v1/account/sessions", "", bearer)
+	if list := sessions.List("uuid-1"); w.Code != http.StatusNoContent || len(list) != 1 || list[0].UserAgent != "launcher" {
+		t.Errorf("expected only current session left, got %d %+v", w.Code, list)
+	}
+}
+
+func TestAccountLoginHistory(t *testing.T) {
+	s := setupStorage(t)
+	attempts := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer attempts.Close()
+	auth := NewAuthHandler(s, WithAudit(attempts))
+	signIn(auth, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	signIn(auth, `{"Login":"GamerVII","Password":"bad","Totp":""}`)
+	signIn(auth, `{"Login":"banned","Password":"pass","Totp":""}`)
+
+	h := NewAccountHandler(s, WithAudit(attempts))
+	basic := func(r *http.Request) { r.SetBasicAuth("GamerVII", "pass123") }
+	before, _ := s.FindByLogin("GamerVII")
+	w := accountRequest(h, http.MethodGet, "/api/v1/account/logins", "", basic)
+	if after, _ := s.FindByLogin("GamerVII"); !after.LastLoginAt.Equal(before.LastLoginAt) {
+		t.Errorf("Basic request must not count as a sign-in: %v -> %v", before.LastLoginAt, after.LastLoginAt)
+	}
+	var entries []audit.Entry
+	json.NewDecoder(w.Body).Decode(&entries)
+	// два входа через /signin; запросы по Basic в историю не попадают
+	if w.Code != http.StatusOK || len(entries) != 2 {
+		t.Fatalf("expected 2 own entries, got %d %+v", w.Code, entries)
+	}
+	for _, e := range entries {
+		if e.Login != "GamerVII" {
+			t.Errorf("foreign entry in history: %+v", e)
+		}
+	}
+	w = accountRequest(h, http.MethodGet, "/api/v1/account/logins?result=bad_password", "", basic)
+	entries = nil
+	json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Result != audit.ResultBadPassword {
+		t.Errorf("expected filtered entry, got %+v", entries)
+	}
+}
+
+func TestAccountSignInGate(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	attempts := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer attempts.Close()
+	bans := ipfilter.NewBans(storage.NewDocument[ipfilter.State](filepath.Join(t.TempDir(), "ip_bans.json")))
+	works := maintenance.New(storage.NewDocument[maintenance.State](filepath.Join(t.TempDir(), "maintenance.json")), maintenance.Settings{})
+	h := NewAccountHandler(s, WithSessions(sessions), WithAudit(attempts), WithIPBans(bans), WithMaintenance(works))
+	basic := func(r *http.Request) { r.SetBasicAuth("GamerVII", "pass123") }
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }
+
+	ban, err := bans.Add("192.0.2.0/24", "ботнет", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := accountRequest(h, http.MethodGet, "/api/v1/account", "", basic)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ботнет") {
+		t.Fatalf("expected 403 for banned address, got %d %s", w.Code, w.Body.String())
+	}
+	entries, _ := attempts.Query(audit.Filter{Login: "GamerVII"})
+	if len(entries) != 1 || entries[0].Result != audit.ResultIPBlocked {
+		t.Fatalf("expected ip_blocked audit entry, got %+v", entries)
+	}
+	bans.Remove(ban.ID)
+
+	if _, err := works.Set(maintenance.Settings{Enabled: true, Message: "Вайп до 18:00"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	for _, auth := range []func(*http.Request){basic, bearer} {
+		w = accountRequest(h, http.MethodGet, "/api/v1/account", "", auth)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Вайп до 18:00") {
+			t.Errorf("expected 403 during maintenance, got %d %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAccountPasswordGuessesLimited(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	attempts := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer attempts.Close()
+	lim := limiter.New(limiter.Config{Window: time.Minute, MaxLoginFailures: 2, Lockout: time.Minute},
+		storage.NewDocument[limiter.State](filepath.Join(t.TempDir(), "lockouts.json")))
+	h := NewAccountHandler(s, WithSessions(sessions), WithAudit(attempts), WithLimiter(lim))
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }
+
+	for range 2 {
+		w := accountRequest(h, http.MethodPut, "/api/v1/account/password", `{"current_password":"guess","new_password":"secret1"}`, bearer)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for wrong current password, got %d", w.Code)
+		}
+	}
+	w := accountRequest(h, http.MethodPut, "/api/v1/account/password", `{"current_password":"pass123","new_password":"secret1"}`, bearer)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after failed guesses, got %d", w.Code)
+	}
+	entries, _ := attempts.Query(audit.Filter{UserUUID: "uuid-1", Result: audit.ResultBadPassword})
+	if len(entries) != 2 {
+		t.Errorf("expected 2 bad_password entries, got %+v", entries)
+	}
+}
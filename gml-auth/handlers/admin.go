@@ -1,63 +1,229 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"gml-auth/apikey"
 	"gml-auth/models"
+	"gml-auth/password"
 	"gml-auth/storage"
+	"gml-auth/totp"
 	"net/http"
 	"strings"
-
-	"github.com/google/uuid"
+	"time"
 )
 
+// totpIssuer — название сервиса в приложении-аутентификаторе
+const totpIssuer = "GML"
+
+// recoveryCodeCount — сколько кодов восстановления выдаётся при включении 2FA
+const recoveryCodeCount = 10
+
 type AdminHandler struct {
-	store *storage.Storage
+	store storage.UserStore
+	deps
+}
+
+func NewAdminHandler(store storage.UserStore, opts ...Option) *AdminHandler {
+	return &AdminHandler{store: store, deps: applyOptions(opts)}
+}
+
+// hasPrefixPath — path равен prefix или лежит под ним (/admin/users, /admin/users/...)
+func hasPrefixPath(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// adminResource — раздел admin API и области, нужные для чтения и изменения
+type adminResource struct {
+	prefix string
+	read   string
+	write  string
 }
 
-func NewAdminHandler(store *storage.Storage) *AdminHandler {
-	return &AdminHandler{store: store}
+var adminResources = []adminResource{
+	{"/admin/users", apikey.ScopeUsersRead, apikey.ScopeUsersWrite},
+	{"/admin/lockouts", apikey.ScopeSecurityRead, apikey.ScopeSecurityWrite},
+	{"/admin/backups", apikey.ScopeUsersRead, apikey.ScopeUsersWrite},
+	{"/admin/audit", apikey.ScopeSecurityRead, apikey.ScopeSecurityWrite},
+	{"/admin/ip-bans", apikey.ScopeSecurityRead, apikey.ScopeSecurityWrite},
+	{"/admin/hwid-bans", apikey.ScopeSecurityRead, apikey.ScopeSecurityWrite},
+	{"/admin/groups", apikey.ScopeUsersRead, apikey.ScopeUsersWrite},
+	{"/admin/maintenance", apikey.ScopeSecurityRead, apikey.ScopeSecurityWrite},
+	{"/admin/invites", apikey.ScopeUsersRead, apikey.ScopeUsersWrite},
 }
 
-// loginFromPath извлекает {login} из пути вида /admin/users/{login}[/action]
-func loginFromPath(path string) string {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	// /admin/users/{login} → ["admin","users","{login}"]
-	if len(parts) >= 3 {
-		return parts[2]
+// requiredScope — область для запроса; GET/HEAD требуют read, остальное write
+func requiredScope(r *http.Request) (string, bool) {
+	for _, res := range adminResources {
+		if !hasPrefixPath(r.URL.Path, res.prefix) {
+			continue
+		}
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return res.read, true
+		}
+		return res.write, true
 	}
-	return ""
+	return "", false
+}
+
+// adminKey — ключ контекста запроса с именем API ключа администратора
+type adminKey struct{}
+
+// adminName — кто выполняет запрос (для истории блокировок и журналов)
+func adminName(r *http.Request) string {
+	if name, ok := r.Context().Value(adminKey{}).(string); ok && name != "" {
+		return name
+	}
+	return "admin"
+}
+
+// authorize проверяет Bearer API ключ и его область. При отказе сам пишет 401/403.
+// Имя ключа сохраняется в контексте запроса (см. adminName).
+func (h *AdminHandler) authorize(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if h.apiKeys == nil {
+		return r, true
+	}
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gml-auth admin"`)
+		writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Нужен API ключ (Authorization: Bearer ...)"})
+		return r, false
+	}
+	key, err := h.apiKeys.Authenticate(token)
+	if err != nil {
+		msg := "Неверный API ключ"
+		if errors.Is(err, apikey.ErrExpiredKey) {
+			msg = "Срок действия API ключа истёк"
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gml-auth admin", error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: msg})
+		return r, false
+	}
+	r = r.WithContext(context.WithValue(r.Context(), adminKey{}, key.Name))
+	scope, known := requiredScope(r)
+	if !known {
+		// неизвестный путь — 404 только для авторизованных
+		return r, true
+	}
+	if !key.Allows(scope) {
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "Недостаточно прав: нужна область " + scope})
+		return r, false
+	}
+	return r, true
 }
 
 func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path // /admin/users, /admin/users/foo, /admin/users/foo/block
-
-	switch {
-	case path == "/admin/users" && r.Method == http.MethodGet:
-		h.listUsers(w, r)
-	case path == "/admin/users" && r.Method == http.MethodPost:
-		h.createUser(w, r)
-	case strings.HasSuffix(path, "/block") && r.Method == http.MethodPatch:
-		login := loginFromPath(strings.TrimSuffix(path, "/block"))
-		h.blockUser(w, r, login)
-	case strings.HasSuffix(path, "/unblock") && r.Method == http.MethodPatch:
-		login := loginFromPath(strings.TrimSuffix(path, "/unblock"))
-		h.unblockUser(w, r, login)
-	case r.Method == http.MethodDelete:
-		login := loginFromPath(path)
-		h.deleteUser(w, r, login)
+	r, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	switch path := r.URL.Path; {
+	case hasPrefixPath(path, "/admin/users"):
+		h.serveUsers(w, r)
+	case hasPrefixPath(path, "/admin/lockouts"):
+		h.serveLockouts(w, r)
+	case hasPrefixPath(path, "/admin/backups"):
+		h.serveBackups(w, r)
+	case path == "/admin/audit" && r.Method == http.MethodGet:
+		h.listAudit(w, r, "")
+	case hasPrefixPath(path, "/admin/ip-bans"):
+		h.serveIPBans(w, r)
+	case hasPrefixPath(path, "/admin/hwid-bans"):
+		h.serveHWIDBans(w, r)
+	case hasPrefixPath(path, "/admin/groups"):
+		h.serveGroups(w, r)
+	case path == "/admin/maintenance":
+		h.serveMaintenance(w, r)
+	case hasPrefixPath(path, "/admin/invites"):
+		h.serveInvites(w, r)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
-func (h *AdminHandler) listUsers(w http.ResponseWriter, _ *http.Request) {
-	users, err := h.store.ListUsers()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+// serveUsers разбирает путь на сегменты и сначала смотрит на их число:
+// логин может совпадать с названием действия (sessions, skin, bans...),
+// поэтому /admin/users/sessions — это пользователь, а не действие.
+func (h *AdminHandler) serveUsers(w http.ResponseWriter, r *http.Request) {
+	// /admin/users, /admin/users/{login}, /admin/users/{login}/{action}[/{id}]
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/users"), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listUsers(w, r)
+		case http.MethodPost:
+			h.createUser(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
 		return
 	}
-	writeJSON(w, http.StatusOK, users)
+	parts := strings.Split(rest, "/")
+	login := parts[0]
+
+	switch len(parts) {
+	case 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.getUser(w, r, login)
+		case http.MethodPatch:
+			h.patchUser(w, r, login)
+		case http.MethodDelete:
+			h.deleteUser(w, r, login)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case 2:
+		h.serveUserAction(w, r, login, parts[1])
+	case 3:
+		switch action, id := parts[1], parts[2]; {
+		case action == "groups" && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+			h.setMembership(w, r, login, id)
+		case action == "sessions" && r.Method == http.MethodDelete:
+			h.revokeSessionsHandler(w, r, login, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveUserAction — /admin/users/{login}/{action}
+func (h *AdminHandler) serveUserAction(w http.ResponseWriter, r *http.Request, login, action string) {
+	switch m := r.Method; {
+	case action == "block" && m == http.MethodPatch:
+		h.blockUser(w, r, login)
+	case action == "unblock" && m == http.MethodPatch:
+		h.unblockUser(w, r, login)
+	case action == "logins" && m == http.MethodGet:
+		h.listAudit(w, r, login)
+	case action == "groups" && m == http.MethodGet:
+		h.userGroups(w, r, login)
+	case action == "linked" && m == http.MethodGet:
+		h.linkedAccounts(w, r, login)
+	case action == "bans" && m == http.MethodGet:
+		h.listBans(w, r, login)
+	case action == "totp" && m == http.MethodPost:
+		h.enrollTOTP(w, r, login)
+	case action == "totp" && m == http.MethodDelete:
+		h.disableTOTP(w, r, login)
+	case action == "skin" && m == http.MethodPut:
+		h.putTexture(w, r, h.store, login, kindSkin)
+	case action == "skin" && m == http.MethodDelete:
+		h.deleteTexture(w, h.store, login, kindSkin)
+	case action == "cape" && m == http.MethodPut:
+		h.putTexture(w, r, h.store, login, kindCape)
+	case action == "cape" && m == http.MethodDelete:
+		h.deleteTexture(w, h.store, login, kindCape)
+	case action == "sessions" && m == http.MethodGet:
+		h.listSessions(w, r, login)
+	case action == "sessions" && m == http.MethodDelete:
+		h.revokeSessionsHandler(w, r, login, "")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
 }
 
 func (h *AdminHandler) createUser(w http.ResponseWriter, r *http.Request) {
@@ -66,17 +232,40 @@ func (h *AdminHandler) createUser(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужны login и password"})
 		return
 	}
+	if err := storage.ValidateLogin(req.Login); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Логин должен быть от 3 до 16 символов: латинские буквы, цифры и _"})
+		return
+	}
+	if h.passwordRejected(w, "password", req.Login, req.Password) {
+		return
+	}
+	id, err := storage.NewUUID(h.uuidStrategy, req.Login, req.UUID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	hashed, err := password.Hash(req.Password)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка создания"})
+		return
+	}
 	user := models.User{
-		UUID:     uuid.New().String(),
-		Login:    req.Login,
-		Password: req.Password,
-		IsSlim:   req.IsSlim,
+		UUID:      id,
+		Login:     req.Login,
+		Password:  hashed,
+		IsSlim:    req.IsSlim,
+		CreatedAt: time.Now().UTC(),
 	}
-	if err := h.store.AddUser(user); err != nil {
+	err = h.store.AddUser(user)
+	if errors.Is(err, storage.ErrConflict) {
+		writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Логин или UUID уже занят"})
+		return
+	}
+	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка создания"})
 		return
 	}
-	writeJSON(w, http.StatusCreated, user)
+	writeJSON(w, http.StatusCreated, user.Info())
 }
 
 func (h *AdminHandler) deleteUser(w http.ResponseWriter, _ *http.Request, login string) {
@@ -84,45 +273,81 @@ func (h *AdminHandler) deleteUser(w http.ResponseWriter, _ *http.Request, login
 		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
 		return
 	}
-	err := h.store.DeleteUser(login)
+	user, err := h.store.FindByLogin(login)
+	if err == nil {
+		err = h.store.DeleteUser(login)
+	}
 	if errors.Is(err, storage.ErrNotFound) {
 		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
 		return
 	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка удаления"})
+		return
+	}
+	h.revokeSessions(user.UUID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *AdminHandler) blockUser(w http.ResponseWriter, r *http.Request, login string) {
+// enrollTOTP — POST /admin/users/{login}/totp
+// Генерирует новый секрет и коды восстановления (повторный вызов перевыпускает их).
+func (h *AdminHandler) enrollTOTP(w http.ResponseWriter, _ *http.Request, login string) {
 	if login == "" {
 		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
 		return
 	}
-	var req models.BlockRequest
-	json.NewDecoder(r.Body).Decode(&req)
-
-	err := h.store.UpdateUser(login, func(u *models.User) {
-		u.Blocked = true
-		u.BlockReason = req.Reason
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка генерации секрета"})
+		return
+	}
+	codes, hashes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка генерации секрета"})
+		return
+	}
+	var account string
+	err = h.store.UpdateUser(login, func(u *models.User) error {
+		u.TOTPSecret = secret
+		u.TOTPLastStep = 0
+		u.RecoveryCodes = hashes
+		account = u.Login
+		return nil
 	})
 	if errors.Is(err, storage.ErrNotFound) {
 		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
 		return
 	}
-	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Заблокирован"})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.TOTPEnrollResponse{
+		Secret:        secret,
+		URI:           totp.URI(totpIssuer, account, secret),
+		RecoveryCodes: codes,
+	})
 }
 
-func (h *AdminHandler) unblockUser(w http.ResponseWriter, _ *http.Request, login string) {
+// disableTOTP — DELETE /admin/users/{login}/totp
+func (h *AdminHandler) disableTOTP(w http.ResponseWriter, _ *http.Request, login string) {
 	if login == "" {
 		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
 		return
 	}
-	err := h.store.UpdateUser(login, func(u *models.User) {
-		u.Blocked = false
-		u.BlockReason = ""
+	err := h.store.UpdateUser(login, func(u *models.User) error {
+		u.TOTPSecret = ""
+		u.TOTPLastStep = 0
+		u.RecoveryCodes = nil
+		return nil
 	})
 	if errors.Is(err, storage.ErrNotFound) {
 		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
 		return
 	}
-	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Разблокирован"})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "2FA отключена"})
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"gml-auth/audit"
+	"gml-auth/models"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Размер выборки журнала входов
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// parseAuditFilter разбирает параметры from, to, result, limit; при ошибке возвращает текст для 400
+func parseAuditFilter(q url.Values) (audit.Filter, string) {
+	f := audit.Filter{Result: q.Get("result"), Limit: defaultAuditLimit}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := parseQueryTime(v)
+			if err != nil {
+				return f, "Параметр " + name + ": нужна дата 2006-01-02 или время RFC 3339"
+			}
+			*dst = t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return f, "Параметр limit: от 1 до " + strconv.Itoa(maxAuditLimit)
+		}
+		f.Limit = n
+	}
+	return f, ""
+}
+
+// listAudit — журнал попыток входа, новые первыми
+//
+//	GET /admin/audit                 — все попытки (login= — отбор по логину)
+//	GET /admin/users/{login}/logins  — попытки одного пользователя (по UUID, переживает переименование)
+//
+// Параметры: from, to (2006-01-02 или RFC 3339), result (success, bad_password, ...), limit.
+func (h *AdminHandler) listAudit(w http.ResponseWriter, r *http.Request, login string) {
+	if h.audit == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Журнал входов выключен"})
+		return
+	}
+	f, msg := parseAuditFilter(r.URL.Query())
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+		return
+	}
+	f.Login = login
+	if login == "" {
+		f.Login = r.URL.Query().Get("login")
+	} else if user, err := h.store.FindByLogin(login); err == nil {
+		// история аккаунта, в том числе под прежними логинами
+		f.Login, f.UserUUID = user.Login, user.UUID
+	}
+	entries, err := h.audit.Query(f)
+	if err != nil {
+		log.Printf("[admin] audit: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// serveBackups — /admin/backups (только для JSON хранилища)
+//
+//	GET  /admin/backups                — список копий users.json, новые первыми
+//	POST /admin/backups                — сделать копию сейчас
+//	POST /admin/backups/{name}/restore — восстановить users.json из копии
+func (h *AdminHandler) serveBackups(w http.ResponseWriter, r *http.Request) {
+	bs, ok := h.store.(storage.BackupStore)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Резервные копии есть только у JSON хранилища"})
+		return
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/backups"), "/")
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		list, err := bs.Backups()
+		if backupError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case rest == "" && r.Method == http.MethodPost:
+		b, err := bs.CreateBackup()
+		if backupError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, b)
+	case strings.HasSuffix(rest, "/restore") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(rest, "/restore")
+		if backupError(w, bs.RestoreBackup(name)) {
+			return
+		}
+		log.Printf("[admin] users.json восстановлен из %s", name)
+		writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Восстановлено из " + name})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// backupError пишет ответ для ошибки операции с копиями; false — ошибки нет
+func backupError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, storage.ErrBackupsDisabled):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Резервные копии выключены"})
+	case errors.Is(err, storage.ErrBackupNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Копия не найдена"})
+	default:
+		log.Printf("[admin] backups: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка резервного копирования"})
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errBadTerm — срок (блокировки, членства в группе) не разобран или уже в прошлом
+var errBadTerm = errors.New("bad term")
+
+// parseTermDuration разбирает срок: кроме единиц time.ParseDuration
+// понимает дни и недели (7d, 2w)
+func parseTermDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, errBadTerm
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, errBadTerm
+	}
+	return d, nil
+}
+
+// expiryFrom — момент окончания по duration или until; нулевое время — бессрочно
+func expiryFrom(duration string, until, now time.Time) (time.Time, error) {
+	switch {
+	case duration != "" && !until.IsZero():
+		return time.Time{}, errBadTerm
+	case duration != "":
+		d, err := parseTermDuration(duration)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	case !until.IsZero():
+		if !until.After(now) {
+			return time.Time{}, errBadTerm
+		}
+		return until.UTC(), nil
+	}
+	return time.Time{}, nil
+}
+
+// decodeOptional читает JSON тело, которое можно не передавать: пустое тело
+// (в том числе chunked без данных, где ContentLength = -1) оставляет v как есть
+func decodeOptional(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// blockUser — PATCH /admin/users/{login}/block
+// Тело: {"reason": "...", "duration": "7d"} или {"reason": "...", "until": "2025-01-01T00:00:00Z"}.
+func (h *AdminHandler) blockUser(w http.ResponseWriter, r *http.Request, login string) {
+	if login == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
+		return
+	}
+	var req models.BlockRequest
+	if err := decodeOptional(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		return
+	}
+	now := time.Now().UTC()
+	until, err := expiryFrom(req.Duration, req.Until, now)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Срок блокировки: duration (30m, 12h, 7d, 2w) или until в будущем, но не оба сразу"})
+		return
+	}
+
+	var updated models.User
+	err = h.store.UpdateUser(login, func(u *models.User) error {
+		u.Ban(req.Reason, adminName(r), now, until)
+		updated = *u
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	h.revokeSessions(updated.UUID)
+	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Заблокирован"})
+}
+
+// unblockUser — PATCH /admin/users/{login}/unblock
+func (h *AdminHandler) unblockUser(w http.ResponseWriter, r *http.Request, login string) {
+	if login == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
+		return
+	}
+	err := h.store.UpdateUser(login, func(u *models.User) error {
+		u.Unban(adminName(r), time.Now().UTC())
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Разблокирован"})
+}
+
+// listBans — GET /admin/users/{login}/bans: история блокировок, последняя — в конце
+func (h *AdminHandler) listBans(w http.ResponseWriter, _ *http.Request, login string) {
+	user, err := h.store.FindByLogin(login)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+		return
+	}
+	history := user.BanHistory
+	if history == nil {
+		history = []models.BanRecord{}
+	}
+	writeJSON(w, http.StatusOK, history)
+}
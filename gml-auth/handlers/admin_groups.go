@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/groups"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// serveGroups — /admin/groups
+//
+//	GET    /admin/groups                 — все группы
+//	POST   /admin/groups                 — {"name": "vip", "description": "..."}
+//	GET    /admin/groups/{name}          — группа
+//	PATCH  /admin/groups/{name}          — {"description": "..."}
+//	DELETE /admin/groups/{name}          — удалить группу и исключить из неё всех
+//	GET    /admin/groups/{name}/members  — участники с действующим членством
+func (h *AdminHandler) serveGroups(w http.ResponseWriter, r *http.Request) {
+	if h.groups == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Группы выключены"})
+		return
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/groups"), "/")
+	name, sub, _ := strings.Cut(rest, "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		list, err := h.groups.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+			return
+		}
+		if list == nil {
+			list = []groups.Group{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case name == "" && r.Method == http.MethodPost:
+		h.createGroup(w, r)
+	case sub == "" && r.Method == http.MethodGet:
+		group, err := h.groups.Get(name)
+		if h.groupError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusOK, group)
+	case sub == "" && r.Method == http.MethodPatch:
+		var req models.GroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+			return
+		}
+		group, err := h.groups.Describe(name, req.Description)
+		if h.groupError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusOK, group)
+	case sub == "" && r.Method == http.MethodDelete:
+		if h.groupError(w, h.groups.Delete(name)) {
+			return
+		}
+		if _, err := groups.RemoveMembers(h.store, name); err != nil {
+			log.Printf("[admin] remove members of %s: %v", name, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Группа удалена, но не у всех пользователей"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case sub == "members" && r.Method == http.MethodGet:
+		h.groupMembers(w, name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// groupError отвечает на ошибку справочника групп; false — ошибки нет
+func (h *AdminHandler) groupError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, groups.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Группа не найдена"})
+	case errors.Is(err, groups.ErrExists):
+		writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Группа уже есть"})
+	case errors.Is(err, groups.ErrInvalidName):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Имя группы: до 32 символов, строчные латинские буквы, цифры, _ и -"})
+	default:
+		log.Printf("[admin] groups: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+	}
+	return true
+}
+
+func (h *AdminHandler) createGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужно name"})
+		return
+	}
+	group, err := h.groups.Create(req.Name, req.Description)
+	if h.groupError(w, err) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+func (h *AdminHandler) groupMembers(w http.ResponseWriter, name string) {
+	if _, err := h.groups.Get(name); h.groupError(w, err) {
+		return
+	}
+	users, err := h.store.ListUsers()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	now := time.Now()
+	members := []models.GroupMember{}
+	for _, u := range users {
+		for _, g := range u.Groups {
+			if g.Name == name && g.Active(now) {
+				members = append(members, models.GroupMember{UUID: u.UUID, Login: u.Login, ExpiresAt: g.ExpiresAt})
+				break
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+// userGroups — GET /admin/users/{login}/groups: членство пользователя, включая истёкшее
+func (h *AdminHandler) userGroups(w http.ResponseWriter, _ *http.Request, login string) {
+	user, err := h.store.FindByLogin(login)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user.Info().Groups)
+}
+
+// setMembership — членство пользователя login в группе name
+//
+//	PUT    /admin/users/{login}/groups/{name}  — {"duration": "30d"} или {"expires_at": "..."}; повтор продлевает
+//	DELETE /admin/users/{login}/groups/{name}  — исключить из группы
+func (h *AdminHandler) setMembership(w http.ResponseWriter, r *http.Request, login, name string) {
+	if h.groups == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Группы выключены"})
+		return
+	}
+	if _, err := h.groups.Get(name); h.groupError(w, err) {
+		return
+	}
+	var expires time.Time
+	if r.Method == http.MethodPut {
+		var req models.MembershipRequest
+		if err := decodeOptional(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+			return
+		}
+		var err error
+		if expires, err = expiryFrom(req.Duration, req.ExpiresAt, time.Now().UTC()); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Срок членства: duration (30d, 2w) или expires_at в будущем, но не оба сразу"})
+			return
+		}
+	}
+
+	isMember := func(g models.UserGroup) bool { return g.Name == name }
+	var updated models.User
+	err := h.store.UpdateUser(login, func(u *models.User) error {
+		i := slices.IndexFunc(u.Groups, isMember)
+		switch {
+		case r.Method == http.MethodDelete && i < 0:
+			return groups.ErrNotFound
+		case r.Method == http.MethodDelete:
+			u.Groups = slices.Delete(u.Groups, i, i+1)
+		case i < 0:
+			u.Groups = append(u.Groups, models.UserGroup{Name: name, ExpiresAt: expires})
+		default:
+			u.Groups[i].ExpiresAt = expires
+		}
+		updated = *u
+		return nil
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+	case errors.Is(err, groups.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не состоит в группе"})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusOK, updated.Info().Groups)
+	}
+}
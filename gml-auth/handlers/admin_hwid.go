@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/hwid"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// serveHWIDBans — /admin/hwid-bans
+//
+//	GET    /admin/hwid-bans       — действующие блокировки устройств
+//	POST   /admin/hwid-bans       — {"hwid": "...", "reason": "...", "duration": "30d"}
+//	DELETE /admin/hwid-bans/{id}  — снять блокировку
+func (h *AdminHandler) serveHWIDBans(w http.ResponseWriter, r *http.Request) {
+	if h.hwidBans == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Блокировка устройств выключена"})
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/hwid-bans"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list, err := h.hwidBans.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+			return
+		}
+		if list == nil {
+			list = []hwid.Ban{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case id == "" && r.Method == http.MethodPost:
+		h.addHWIDBan(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		err := h.hwidBans.Remove(id)
+		if errors.Is(err, hwid.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Блокировка не найдена"})
+			return
+		}
+		if err != nil {
+			log.Printf("[admin] remove hwid ban %s: %v", id, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *AdminHandler) addHWIDBan(w http.ResponseWriter, r *http.Request) {
+	var req models.HWIDBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		return
+	}
+	if _, err := hwid.Normalize(req.HWID); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен hwid (до 128 печатных символов)"})
+		return
+	}
+	until, err := expiryFrom(req.Duration, req.Until, time.Now().UTC())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Срок блокировки: duration (30m, 12h, 7d, 2w) или until в будущем, но не оба сразу"})
+		return
+	}
+	ban, err := h.hwidBans.Add(req.HWID, req.Reason, adminName(r), until)
+	if err != nil {
+		log.Printf("[admin] add hwid ban: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	// сессии всех аккаунтов с этого устройства больше не действуют
+	if users, err := h.store.ListUsers(); err == nil {
+		for _, u := range users {
+			if u.HasDevice(ban.HWID) {
+				h.revokeSessions(u.UUID)
+			}
+		}
+	}
+	writeJSON(w, http.StatusCreated, ban)
+}
+
+// linkedAccounts — GET /admin/users/{login}/linked: аккаунты с общими устройствами
+func (h *AdminHandler) linkedAccounts(w http.ResponseWriter, _ *http.Request, login string) {
+	user, err := h.store.FindByLogin(login)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	users, err := h.store.ListUsers()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	now := time.Now()
+	linked := []models.LinkedAccount{}
+	for _, u := range users {
+		if u.UUID == user.UUID {
+			continue
+		}
+		var shared []string
+		for _, id := range u.HWIDs() {
+			if user.HasDevice(id) && !slices.Contains(shared, id) {
+				shared = append(shared, id)
+			}
+		}
+		if len(shared) > 0 {
+			linked = append(linked, models.LinkedAccount{UUID: u.UUID, Login: u.Login, Blocked: u.IsBlocked(now), HWIDs: shared})
+		}
+	}
+	writeJSON(w, http.StatusOK, linked)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"gml-auth/invites"
+	"gml-auth/models"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveInvites — /admin/invites
+//
+//	GET    /admin/invites       — все коды с числом использований
+//	POST   /admin/invites       — {"max_uses": 5, "note": "стримеры", "duration": "7d"}
+//	DELETE /admin/invites/{id}  — отозвать код
+func (h *AdminHandler) serveInvites(w http.ResponseWriter, r *http.Request) {
+	if h.invites == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Регистрация выключена"})
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/invites"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list, err := h.invites.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case id == "" && r.Method == http.MethodPost:
+		h.createInvite(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		err := h.invites.Revoke(id)
+		if errors.Is(err, invites.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Приглашение не найдено"})
+			return
+		}
+		if err != nil {
+			log.Printf("[admin] revoke invite %s: %v", id, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *AdminHandler) createInvite(w http.ResponseWriter, r *http.Request) {
+	var req models.InviteRequest
+	if err := decodeOptional(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		return
+	}
+	if req.MaxUses < 0 {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "max_uses не может быть отрицательным"})
+		return
+	}
+	until, err := expiryFrom(req.Duration, req.Until, time.Now().UTC())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Срок действия: duration (30m, 12h, 7d, 2w) или until в будущем, но не оба сразу"})
+		return
+	}
+	code, inv, err := h.invites.Create(req.MaxUses, req.Note, adminName(r), until)
+	if err != nil {
+		log.Printf("[admin] create invite: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	// код показывается только в этом ответе
+	writeJSON(w, http.StatusCreated, struct {
+		Code string `json:"code"`
+		invites.Invite
+	}{code, inv})
+}
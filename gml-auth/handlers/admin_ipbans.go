@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/ipfilter"
+	"gml-auth/models"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ipBanned — адрес ip попадает под блокировку адресов и подсетей
+func (d *deps) ipBanned(ip string) (ipfilter.Ban, bool) {
+	if d.ipBans == nil {
+		return ipfilter.Ban{}, false
+	}
+	return d.ipBans.Match(ip)
+}
+
+// serveIPBans — /admin/ip-bans
+//
+//	GET    /admin/ip-bans       — действующие блокировки адресов и подсетей
+//	POST   /admin/ip-bans       — {"cidr": "203.0.113.0/24", "reason": "...", "duration": "7d"}
+//	DELETE /admin/ip-bans/{id}  — снять блокировку
+func (h *AdminHandler) serveIPBans(w http.ResponseWriter, r *http.Request) {
+	if h.ipBans == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Блокировка адресов выключена"})
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/ip-bans"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list, err := h.ipBans.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+			return
+		}
+		if list == nil {
+			list = []ipfilter.Ban{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case id == "" && r.Method == http.MethodPost:
+		h.addIPBan(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		err := h.ipBans.Remove(id)
+		if errors.Is(err, ipfilter.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Блокировка не найдена"})
+			return
+		}
+		if err != nil {
+			log.Printf("[admin] remove ip ban %s: %v", id, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *AdminHandler) addIPBan(w http.ResponseWriter, r *http.Request) {
+	var req models.IPBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CIDR == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен cidr (адрес или подсеть)"})
+		return
+	}
+	if _, err := ipfilter.ParsePrefix(req.CIDR); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	until, err := expiryFrom(req.Duration, req.Until, time.Now().UTC())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Срок блокировки: duration (30m, 12h, 7d, 2w) или until в будущем, но не оба сразу"})
+		return
+	}
+	ban, err := h.ipBans.Add(req.CIDR, req.Reason, adminName(r), until)
+	if err != nil {
+		log.Printf("[admin] add ip ban %s: %v", req.CIDR, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, ban)
+}
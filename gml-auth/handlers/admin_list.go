@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Размер страницы GET /admin/users
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// userCursor — позиция последней записи страницы. Передаётся клиенту
+// непрозрачной строкой, поэтому добавление записей не сдвигает страницы.
+type userCursor struct {
+	Sort  string `json:"s"`
+	Login string `json:"l"`
+	Time  int64  `json:"t,omitempty"` // created_at/last_login_at в наносекундах
+}
+
+func (c userCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (userCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return userCursor{}, false
+	}
+	var c userCursor
+	return c, json.Unmarshal(data, &c) == nil
+}
+
+// userQuery — разобранные параметры GET /admin/users
+type userQuery struct {
+	search, prefix string
+	blocked        *bool
+	isSlim         *bool
+	group          string
+	createdFrom    time.Time
+	createdTo      time.Time
+	lastLoginFrom  time.Time
+	lastLoginTo    time.Time
+	sortKey        string
+	desc           bool
+	limit          int
+	cursor         *userCursor
+}
+
+// sortKeys — допустимые значения sort (с минусом — по убыванию)
+var sortKeys = map[string]bool{"login": true, "created_at": true, "last_login_at": true}
+
+// parseUserQuery разбирает параметры; при ошибке возвращает текст для 400
+func parseUserQuery(q url.Values) (userQuery, string) {
+	uq := userQuery{
+		search: strings.ToLower(q.Get("search")),
+		prefix: strings.ToLower(q.Get("prefix")),
+		group:  q.Get("group"),
+		limit:  defaultPageSize,
+	}
+	for name, dst := range map[string]**bool{"blocked": &uq.blocked, "is_slim": &uq.isSlim} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return uq, "Параметр " + name + " должен быть true или false"
+			}
+			*dst = &b
+		}
+	}
+	for name, dst := range map[string]*time.Time{
+		"created_from": &uq.createdFrom, "created_to": &uq.createdTo,
+		"last_login_from": &uq.lastLoginFrom, "last_login_to": &uq.lastLoginTo,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := parseQueryTime(v)
+			if err != nil {
+				return uq, "Параметр " + name + ": нужна дата 2006-01-02 или время RFC 3339"
+			}
+			*dst = t
+		}
+	}
+
+	uq.sortKey = q.Get("sort")
+	uq.sortKey, uq.desc = strings.CutPrefix(uq.sortKey, "-")
+	if uq.sortKey == "" {
+		uq.sortKey = "login"
+	}
+	if !sortKeys[uq.sortKey] {
+		return uq, "Параметр sort: login, created_at или last_login_at (с - для убывания)"
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return uq, "Параметр limit: от 1 до " + strconv.Itoa(maxPageSize)
+		}
+		uq.limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, ok := decodeCursor(v)
+		if !ok || c.Sort != uq.sortName() {
+			return uq, "Неверный cursor (он действует только с тем же sort)"
+		}
+		uq.cursor = &c
+	}
+	return uq, ""
+}
+
+// parseQueryTime принимает дату (2006-01-02, начало дня UTC) или время RFC 3339
+func parseQueryTime(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// inRange — from включительно, to не включительно; нулевые границы не ограничивают
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+func (uq userQuery) match(u models.User, now time.Time) bool {
+	login := storage.NormalizeLogin(u.Login)
+	switch {
+	case uq.search != "" && !strings.Contains(login, uq.search),
+		uq.prefix != "" && !strings.HasPrefix(login, uq.prefix),
+		uq.blocked != nil && u.IsBlocked(now) != *uq.blocked,
+		uq.isSlim != nil && u.IsSlim != *uq.isSlim,
+		uq.group != "" && !u.InGroup(uq.group, now):
+		return false
+	}
+	if (!uq.createdFrom.IsZero() || !uq.createdTo.IsZero()) && !inRange(u.CreatedAt, uq.createdFrom, uq.createdTo) {
+		return false
+	}
+	if (!uq.lastLoginFrom.IsZero() || !uq.lastLoginTo.IsZero()) && !inRange(u.LastLoginAt, uq.lastLoginFrom, uq.lastLoginTo) {
+		return false
+	}
+	return true
+}
+
+// sortTime — значение ключа сортировки по времени
+func (uq userQuery) sortTime(u models.User) time.Time {
+	if uq.sortKey == "created_at" {
+		return u.CreatedAt
+	}
+	return u.LastLoginAt
+}
+
+// less — порядок выдачи; при равных значениях — по логину, чтобы курсор был однозначным
+func (uq userQuery) less(a, b models.User) bool {
+	la, lb := storage.NormalizeLogin(a.Login), storage.NormalizeLogin(b.Login)
+	if uq.sortKey != "login" {
+		ta, tb := uq.sortTime(a), uq.sortTime(b)
+		if !ta.Equal(tb) {
+			return ta.Before(tb) != uq.desc
+		}
+	}
+	if la == lb {
+		return false
+	}
+	return (la < lb) != uq.desc
+}
+
+// sortName — sort в каноническом виде: login, -created_at и т.п.
+func (uq userQuery) sortName() string {
+	if uq.desc {
+		return "-" + uq.sortKey
+	}
+	return uq.sortKey
+}
+
+func (uq userQuery) cursorFor(u models.User) userCursor {
+	c := userCursor{Sort: uq.sortName(), Login: storage.NormalizeLogin(u.Login)}
+	if t := uq.sortTime(u); uq.sortKey != "login" && !t.IsZero() {
+		c.Time = t.UnixNano()
+	}
+	return c
+}
+
+// afterCursor — запись идёт после последней записи предыдущей страницы
+func (uq userQuery) afterCursor(u models.User) bool {
+	if uq.cursor == nil {
+		return true
+	}
+	last := models.User{Login: uq.cursor.Login}
+	if uq.cursor.Time != 0 {
+		t := time.Unix(0, uq.cursor.Time)
+		last.CreatedAt, last.LastLoginAt = t, t
+	}
+	return uq.less(last, u)
+}
+
+// listUsers — GET /admin/users
+//
+//	search, prefix          — подстрока или начало логина (без учёта регистра)
+//	blocked, is_slim        — true/false
+//	group                   — состоит в группе
+//	created_from/_to        — дата регистрации (2006-01-02 или RFC 3339)
+//	last_login_from/_to     — дата последнего входа
+//	sort                    — login, created_at, last_login_at; -login — по убыванию
+//	limit, cursor           — размер страницы и X-Next-Cursor предыдущего ответа
+//
+// Всего найдено — в X-Total-Count, следующая страница — в X-Next-Cursor и Link.
+func (h *AdminHandler) listUsers(w http.ResponseWriter, r *http.Request) {
+	uq, msg := parseUserQuery(r.URL.Query())
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+		return
+	}
+	users, err := h.store.ListUsers()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+
+	now := time.Now()
+	matched := users[:0]
+	for _, u := range users {
+		if uq.match(u, now) {
+			matched = append(matched, u)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return uq.less(matched[i], matched[j]) })
+
+	start := sort.Search(len(matched), func(i int) bool { return uq.afterCursor(matched[i]) })
+	page := matched[start:]
+	if len(page) > uq.limit {
+		page = page[:uq.limit]
+		next := uq.cursorFor(page[len(page)-1]).encode()
+		w.Header().Set("X-Next-Cursor", next)
+		q := r.URL.Query()
+		q.Set("cursor", next)
+		w.Header().Set("Link", `<`+r.URL.Path+"?"+q.Encode()+`>; rel="next"`)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(matched)))
+
+	out := make([]models.UserInfo, 0, len(page))
+	for _, u := range page {
+		out = append(out, u.Info())
+	}
+	writeJSON(w, http.StatusOK, out)
+}
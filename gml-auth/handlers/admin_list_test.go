@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gml-auth/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listUsers(t *testing.T, h *AdminHandler, query string) ([]models.UserInfo, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+	}
+	var out []models.UserInfo
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return out, w
+}
+
+func logins(users []models.UserInfo) string {
+	var out []string
+	for _, u := range users {
+		out = append(out, u.Login)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestAdminListUsersFilters(t *testing.T) {
+	s := setupStorage(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.AddUser(models.User{UUID: "uuid-3", Login: "Alex", IsSlim: true, CreatedAt: base.AddDate(0, 1, 0),
+		Groups: []models.UserGroup{{Name: "vip"}}})
+	s.AddUser(models.User{UUID: "uuid-4", Login: "Gamer2", CreatedAt: base.AddDate(0, 2, 0),
+		Groups: []models.UserGroup{{Name: "vip", ExpiresAt: base}}})
+	h := NewAdminHandler(s)
+
+	cases := []struct{ query, want string }{
+		{"", "Alex,banned,Gamer2,GamerVII"},
+		{"search=AMER", "Gamer2,GamerVII"},
+		{"prefix=b", "banned"},
+		{"blocked=true", "banned"},
+		{"is_slim=true", "Alex"},
+		{"group=vip", "Alex"}, // у Gamer2 членство истекло
+		{"created_from=2024-01-15&created_to=2024-03-01", "Alex"},
+		{"sort=-login", "GamerVII,Gamer2,banned,Alex"},
+	}
+	for _, c := range cases {
+		users, _ := listUsers(t, h, c.query)
+		if got := logins(users); got != c.want {
+			t.Errorf("%q: expected %s, got %s", c.query, c.want, got)
+		}
+	}
+
+	_, w := listUsers(t, h, "")
+	if strings.Contains(w.Body.String(), "password") || strings.Contains(w.Body.String(), "pass123") {
+		t.Errorf("password must not be serialized: %s", w.Body.String())
+	}
+
+	for _, q := range []string{"sort=password", "blocked=maybe", "limit=0", "created_from=yesterday", "cursor=!!"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestAdminListUsersCursorPagination(t *testing.T) {
+	s := setupStorage(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.AddUser(models.User{UUID: fmt.Sprint("p-", i), Login: fmt.Sprint("player", i), CreatedAt: base.AddDate(0, 0, i)})
+	}
+	h := NewAdminHandler(s)
+
+	var all []string
+	query := "sort=-created_at&limit=3"
+	for page := 0; page < 5; page++ {
+		users, w := listUsers(t, h, query)
+		if w.Header().Get("X-Total-Count") != "7" {
+			t.Fatalf("expected X-Total-Count 7, got %q", w.Header().Get("X-Total-Count"))
+		}
+		all = append(all, strings.Split(logins(users), ",")...)
+		next := w.Header().Get("X-Next-Cursor")
+		if next == "" {
+			break
+		}
+		query = "sort=-created_at&limit=3&cursor=" + url.QueryEscape(next)
+	}
+	// записи без даты регистрации идут последними, между собой — по логину в том же направлении
+	want := "player4,player3,player2,player1,player0,GamerVII,banned"
+	if got := strings.Join(all, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// курсор от другой сортировки не принимается
+	_, w := listUsers(t, h, "sort=login&limit=1")
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?sort=-login&cursor="+url.QueryEscape(w.Header().Get("X-Next-Cursor")), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for cursor of another sort, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"gml-auth/limiter"
+	"gml-auth/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// serveLockouts — /admin/lockouts
+//
+//	GET    /admin/lockouts        — активные счётчики и блокировки
+//	DELETE /admin/lockouts/{key}  — снять блокировку (key: login:steve или ip:1.2.3.4)
+func (h *AdminHandler) serveLockouts(w http.ResponseWriter, r *http.Request) {
+	if h.limiter == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Защита от перебора выключена"})
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/lockouts"), "/")
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		list := h.limiter.List()
+		if list == nil {
+			list = []limiter.Status{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case key != "" && r.Method == http.MethodDelete:
+		found, err := h.limiter.Clear(key)
+		if err != nil {
+			log.Printf("[admin] clear lockout %s: %v", key, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Блокировка не найдена"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
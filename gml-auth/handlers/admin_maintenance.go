@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"log"
+	"net/http"
+)
+
+// serveMaintenance — /admin/maintenance
+//
+//	GET    /admin/maintenance  — текущие настройки
+//	PUT    /admin/maintenance  — {"enabled": true, "message": "...", "starts_at": "...", "ends_at": "...",
+//	                              "allow_groups": ["admin"], "allow_logins": ["Notch"]}
+//	DELETE /admin/maintenance  — вернуть настройки из config.json
+func (h *AdminHandler) serveMaintenance(w http.ResponseWriter, r *http.Request) {
+	if h.maintenance == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Режим технических работ выключен"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		settings, err := h.maintenance.Get()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	case http.MethodPut:
+		var req maintenance.Settings
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса: " + err.Error()})
+			return
+		}
+		if !req.StartsAt.IsZero() && !req.EndsAt.IsZero() && !req.EndsAt.After(req.StartsAt) {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "ends_at должен быть позже starts_at"})
+			return
+		}
+		settings, err := h.maintenance.Set(req, adminName(r))
+		if err != nil {
+			log.Printf("[admin] maintenance: %v", err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		log.Printf("[maintenance] %s: enabled=%v starts_at=%v ends_at=%v", settings.UpdatedBy, settings.Enabled, settings.StartsAt, settings.EndsAt)
+		writeJSON(w, http.StatusOK, settings)
+	case http.MethodDelete:
+		if err := h.maintenance.Reset(); err != nil {
+			log.Printf("[admin] maintenance: %v", err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"gml-auth/models"
+	"gml-auth/session"
+	"gml-auth/storage"
+	"net/http"
+)
+
+// listSessions — GET /admin/users/{login}/sessions
+func (h *AdminHandler) listSessions(w http.ResponseWriter, _ *http.Request, login string) {
+	user, ok := h.sessionUser(w, login)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.sessions.List(user.UUID))
+}
+
+// revokeSessionsHandler — DELETE /admin/users/{login}/sessions[/{id}]
+// Без id завершает все сессии пользователя.
+func (h *AdminHandler) revokeSessionsHandler(w http.ResponseWriter, _ *http.Request, login, id string) {
+	user, ok := h.sessionUser(w, login)
+	if !ok {
+		return
+	}
+	if id == "" {
+		if _, err := h.sessions.RevokeUser(user.UUID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	err := h.sessions.Revoke(user.UUID, id)
+	if errors.Is(err, session.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сессия не найдена"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionUser находит пользователя для операций с сессиями и сам пишет ответ об ошибке
+func (h *AdminHandler) sessionUser(w http.ResponseWriter, login string) (models.User, bool) {
+	if h.sessions == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сессии выключены"})
+		return models.User{}, false
+	}
+	if login == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
+		return models.User{}, false
+	}
+	user, err := h.store.FindByLogin(login)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return models.User{}, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return models.User{}, false
+	}
+	return user, true
+}
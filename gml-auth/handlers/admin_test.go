@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gml-auth/apikey"
+	"gml-auth/groups"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/session"
+	"gml-auth/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminEnrollAndDisableTOTP(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/GamerVII/totp", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.TOTPEnrollResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Secret == "" || len(resp.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("unexpected enroll response: %+v", resp)
+	}
+	if !strings.HasPrefix(resp.URI, "otpauth://totp/") {
+		t.Errorf("unexpected uri: %s", resp.URI)
+	}
+	u, _ := s.FindByLogin("GamerVII")
+	if u.TOTPSecret != resp.Secret {
+		t.Error("secret not stored")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/users/GamerVII/totp", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	u, _ = s.FindByLogin("GamerVII")
+	if u.TOTPSecret != "" || u.RecoveryCodes != nil {
+		t.Error("expected 2FA to be disabled")
+	}
+}
+
+func TestAdminEnrollTOTPNotFound(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/nobody/totp", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestAdminLockouts(t *testing.T) {
+	s := setupStorage(t)
+	lim := limiter.New(limiter.Config{Window: time.Minute, MaxLoginFailures: 1, Lockout: time.Minute},
+		storage.NewDocument[limiter.State](filepath.Join(t.TempDir(), "lockouts.json")))
+	lim.Fail("GamerVII", "")
+	h := NewAdminHandler(s, WithLimiter(lim))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var list []limiter.Status
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || !list[0].Locked {
+		t.Fatalf("expected one locked entry, got %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/lockouts/"+list[0].Key, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if lim.Check("GamerVII", "") != 0 {
+		t.Error("expected lockout to be cleared")
+	}
+}
+
+func TestAdminBlockRevokesSessions(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	sessions.Issue("uuid-1", "GamerVII", "", "")
+	h := NewAdminHandler(s, WithSessions(sessions))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/GamerVII/sessions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var list []session.Info
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(list))
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/users/GamerVII/block", strings.NewReader(`{"reason":"test"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if n := len(sessions.List("uuid-1")); n != 0 {
+		t.Errorf("expected sessions revoked on block, got %d", n)
+	}
+}
+
+func TestAdminRevokeSingleSession(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tok, _ := sessions.Issue("uuid-1", "GamerVII", "", "")
+	h := NewAdminHandler(s, WithSessions(sessions))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/users/GamerVII/sessions/"+tok.SessionID, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if _, err := s.FindByLogin("GamerVII"); err != nil {
+		t.Error("deleting a session must not delete the user")
+	}
+}
+
+func TestAdminRequiresAPIKey(t *testing.T) {
+	s := setupStorage(t)
+	keys := apikey.New(storage.NewDocument[apikey.State](filepath.Join(t.TempDir(), "api_keys.json")))
+	readToken, _, _ := keys.Create("reader", []string{apikey.ScopeUsersRead}, 0)
+	h := NewAdminHandler(s, WithAPIKeys(keys))
+
+	do := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do(http.MethodGet, "/admin/users", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without key, got %d", code)
+	}
+	if code := do(http.MethodGet, "/admin/users", "gml_bad_key"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for bad key, got %d", code)
+	}
+	if code := do(http.MethodGet, "/admin/users", readToken); code != http.StatusOK {
+		t.Errorf("expected 200 for users:read, got %d", code)
+	}
+	if code := do(http.MethodDelete, "/admin/users/GamerVII", readToken); code != http.StatusForbidden {
+		t.Errorf("expected 403 for write with read-only key, got %d", code)
+	}
+	if code := do(http.MethodGet, "/admin/lockouts", readToken); code != http.StatusForbidden {
+		t.Errorf("expected 403 for security:read, got %d", code)
+	}
+	if _, err := s.FindByLogin("GamerVII"); err != nil {
+		t.Error("user must survive forbidden delete")
+	}
+}
+
+func TestAdminBackupsRestore(t *testing.T) {
+	s := setupStorage(t)
+	s.EnableBackups(storage.BackupConfig{Dir: t.TempDir()})
+	h := NewAdminHandler(s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/backups", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var b storage.Backup
+	json.NewDecoder(w.Body).Decode(&b)
+
+	s.DeleteUser("GamerVII")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/backups/"+b.Name+"/restore", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := s.FindByLogin("GamerVII"); err != nil {
+		t.Errorf("expected user to be restored, got %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/backups", nil))
+	var list []storage.Backup
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) < 2 {
+		t.Errorf("expected the pre-restore state to be backed up too, got %+v", list)
+	}
+}
+
+func TestAdminCreateUserValidation(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"login":"Notch","password":"secret"}`, http.StatusCreated},
+		{`{"login":"gamervii","password":"secret"}`, http.StatusConflict},
+		{`{"login":"no spaces","password":"secret"}`, http.StatusBadRequest},
+		{`{"login":"ab","password":"secret"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader(c.body)))
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.body, c.code, w.Code)
+		}
+	}
+}
+
+func TestAdminTemporaryBan(t *testing.T) {
+	s := setupStorage(t)
+	keys := apikey.New(storage.NewDocument[apikey.State](filepath.Join(t.TempDir(), "api_keys.json")))
+	token, _, _ := keys.Create("web-panel", []string{apikey.ScopeUsersRead, apikey.ScopeUsersWrite}, 0)
+	h := NewAdminHandler(s, WithAPIKeys(keys))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPatch, "/admin/users/GamerVII/block", `{"reason":"спам","duration":"soon"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad duration, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "/admin/users/GamerVII/block", `{"reason":"спам","until":"2001-01-01T00:00:00Z"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for past until, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "/admin/users/GamerVII/block", `{"reason":"спам","duration":"2d"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := signIn(NewAuthHandler(s), `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	var resp models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusForbidden || !strings.Contains(resp.Message, "осталось 1 д 23 ч") || !strings.Contains(resp.Message, "спам") {
+		t.Errorf("expected 403 with remaining time, got %d %q", w.Code, resp.Message)
+	}
+
+	do(http.MethodPatch, "/admin/users/GamerVII/unblock", "")
+	var history []models.BanRecord
+	json.NewDecoder(do(http.MethodGet, "/admin/users/GamerVII/bans", "").Body).Decode(&history)
+	if len(history) != 1 || history[0].By != "web-panel" || history[0].LiftedBy != "web-panel" || history[0].Until.IsZero() {
+		t.Fatalf("unexpected ban history: %+v", history)
+	}
+}
+
+func TestAdminGroupsAndMembership(t *testing.T) {
+	s := setupStorage(t)
+	store := groups.New(storage.NewDocument[groups.State](filepath.Join(t.TempDir(), "groups.json")))
+	h := NewAdminHandler(s, WithGroups(store))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do(http.MethodPut, "/admin/users/GamerVII/groups/vip", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown group, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/groups", `{"name":"vip","description":"VIP"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/admin/users/GamerVII/groups/vip", `{"duration":"30d"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	u, _ := s.FindByLogin("GamerVII")
+	if !u.InGroup("vip", time.Now()) || u.Groups[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected temporary membership: %+v", u.Groups)
+	}
+
+	var members []models.GroupMember
+	json.NewDecoder(do(http.MethodGet, "/admin/groups/vip/members", "").Body).Decode(&members)
+	if len(members) != 1 || members[0].Login != "GamerVII" {
+		t.Errorf("unexpected members: %+v", members)
+	}
+
+	w := signIn(NewAuthHandler(s), `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	var resp models.AuthResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Groups) != 1 || resp.Groups[0] != "vip" {
+		t.Errorf("expected groups in signin response: %+v", resp)
+	}
+
+	if w := do(http.MethodDelete, "/admin/groups/vip", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if u, _ := s.FindByLogin("GamerVII"); len(u.Groups) != 0 {
+		t.Errorf("expected membership removed with the group: %+v", u.Groups)
+	}
+}
+
+func TestAuthSignInPolicy(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAuthHandler(s, WithSignInPolicy(groups.Policy{Groups: []string{"tester"}}))
+	if w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside the policy, got %d", w.Code)
+	}
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.Groups = append(u.Groups, models.UserGroup{Name: "tester"})
+		return nil
+	})
+	if w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a tester, got %d", w.Code)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	s := setupStorage(t)
+	store := maintenance.New(storage.NewDocument[maintenance.State](filepath.Join(t.TempDir(), "maintenance.json")), maintenance.Settings{})
+	admin := NewAdminHandler(s, WithMaintenance(store))
+	auth := NewAuthHandler(s, WithMaintenance(store))
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/maintenance",
+		strings.NewReader(`{"enabled":true,"message":"Вайп до 18:00","allow_logins":["banned"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = signIn(auth, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Вайп до 18:00") {
+		t.Errorf("expected 403 with maintenance message, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	auth.Status(w, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	var status maintenance.Status
+	json.NewDecoder(w.Body).Decode(&status)
+	if !status.Maintenance || status.Message != "Вайп до 18:00" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/maintenance", nil))
+	if w := signIn(auth, `{"Login":"GamerVII","Password":"pass123","Totp":""}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after maintenance ends, got %d", w.Code)
+	}
+}
+
+func TestPasswordPolicyErrors(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	policy := WithPasswordPolicy(password.Policy{
+		MinLength:     8,
+		RequireDigit:  true,
+		DenyList:      map[string]struct{}{"password1": {}},
+		DisallowLogin: true,
+	})
+	admin := NewAdminHandler(s, policy)
+	account := NewAccountHandler(s, WithSessions(sessions), policy)
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader(`{"login":"Steve","password":"1"}`)))
+	var resp models.ValidationErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusBadRequest || len(resp.Errors) != 1 || resp.Message != resp.Errors[0] ||
+		resp.Fields[0].Field != "password" || resp.Fields[0].Code != password.CodeTooShort {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+	if _, err := s.FindByLogin("Steve"); err == nil {
+		t.Fatal("user with weak password must not be created")
+	}
+
+	// при переименовании пароль сравнивается с новым логином
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/admin/users/GamerVII", strings.NewReader(`{"login":"Steve2024","password":"steve2024"}`)))
+	resp = models.ValidationErrorResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusBadRequest || resp.Fields[0].Code != password.CodeSameAsLogin {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+
+	w = accountRequest(account, http.MethodPut, "/api/v1/account/password",
+		`{"current_password":"pass123","new_password":"Password1"}`,
+		func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) })
+	resp = models.ValidationErrorResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusBadRequest || resp.Fields[0].Field != "new_password" || resp.Fields[0].Code != password.CodeCommon {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+	if user, _ := s.FindByLogin("GamerVII"); user.Password != "pass123" {
+		t.Error("password must not change")
+	}
+}
+
+func TestAdminCreateUUIDStrategy(t *testing.T) {
+	s := setupStorage(t)
+	create := func(h *AdminHandler, body string) (*httptest.ResponseRecorder, models.UserInfo) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader(body)))
+		var info models.UserInfo
+		json.NewDecoder(w.Body).Decode(&info)
+		return w, info
+	}
+
+	w, info := create(NewAdminHandler(s, WithUUIDStrategy(storage.UUIDOffline)), `{"login":"Notch","password":"secret"}`)
+	if w.Code != http.StatusCreated || info.UUID != "b50ad385-829d-3141-a216-7e7d7539ba7f" {
+		t.Fatalf("expected offline uuid, got %d %+v", w.Code, info)
+	}
+	if w, _ := create(NewAdminHandler(s), `{"login":"Jeb","password":"secret","uuid":"853c80ef-3c37-49fd-aa49-938b674adae6"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for uuid with random strategy, got %d", w.Code)
+	}
+	imported := NewAdminHandler(s, WithUUIDStrategy(storage.UUIDImported))
+	w, info = create(imported, `{"login":"Jeb","password":"secret","uuid":"853c80ef-3c37-49fd-aa49-938b674adae6"}`)
+	if w.Code != http.StatusCreated || info.UUID != "853c80ef-3c37-49fd-aa49-938b674adae6" {
+		t.Fatalf("expected imported uuid, got %d %+v", w.Code, info)
+	}
+	if w, _ := create(imported, `{"login":"Dinnerbone","password":"secret","uuid":"853c80ef-3c37-49fd-aa49-938b674adae6"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for taken uuid, got %d", w.Code)
+	}
+}
+
+func TestAdminBlockEmptyChunkedBody(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	// тело без длины, как у chunked запроса без данных
+	req := httptest.NewRequest(http.MethodPatch, "/admin/users/GamerVII/block", io.NopCloser(strings.NewReader("")))
+	if req.ContentLength != -1 {
+		t.Fatalf("expected unknown content length, got %d", req.ContentLength)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	user, _ := s.FindByLogin("GamerVII")
+	if !user.Blocked || !user.BlockedUntil.IsZero() {
+		t.Errorf("expected permanent ban, got %+v", user)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errPreconditionFailed — If-Match не совпал: запись успели изменить
+var errPreconditionFailed = errors.New("precondition failed")
+
+// userETag — версия записи для If-Match. Учитывает поля, которые меняют администраторы
+// (PATCH, смена пароля, группы): вход игрока (время, IP, устройства) не должен давать 412.
+// Хэш пароля попадает в ETag только через SHA-256, чтобы его нельзя было узнать по заголовку.
+func userETag(u models.User) string {
+	passwordSum := sha256.Sum256([]byte(u.Password))
+	data, _ := json.Marshal(struct {
+		UUID, Login           string
+		IsSlim, Blocked       bool
+		BlockReason           string
+		BlockedUntil          time.Time
+		TelegramID, DiscordID string
+		Password              []byte
+		Groups                []models.UserGroup
+	}{u.UUID, u.Login, u.IsSlim, u.Blocked, u.BlockReason, u.BlockedUntil, u.TelegramID, u.DiscordID, passwordSum[:], u.Groups})
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches проверяет заголовок If-Match (список через запятую или *)
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// getUser — GET /admin/users/{login}; ETag в ответе передаётся в If-Match при PATCH
+func (h *AdminHandler) getUser(w http.ResponseWriter, _ *http.Request, login string) {
+	user, err := h.store.FindByLogin(login)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка чтения"})
+		return
+	}
+	w.Header().Set("ETag", userETag(user))
+	writeJSON(w, http.StatusOK, user.Info())
+}
+
+// patchUser — PATCH /admin/users/{login}
+// Меняет только переданные поля. С заголовком If-Match изменение применяется,
+// только если запись не менялась с момента чтения, иначе 412.
+// При переименовании счётчик перебора переносится на новый логин, журнал входов
+// находит прежние записи по UUID. Токены и сессии привязаны к UUID и остаются.
+func (h *AdminHandler) patchUser(w http.ResponseWriter, r *http.Request, login string) {
+	var req models.UpdateUserRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса: " + err.Error()})
+		return
+	}
+	if req.Login != nil {
+		if err := storage.ValidateLogin(*req.Login); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Логин должен быть от 3 до 16 символов: латинские буквы, цифры и _"})
+			return
+		}
+	}
+	for _, id := range []*string{req.TelegramID, req.DiscordID} {
+		if id != nil && *id != "" && !contactPattern.MatchString(*id) {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "telegram_id и discord_id — числовые ID пользователей"})
+			return
+		}
+	}
+	var hashed string
+	if req.Password != nil {
+		newLogin := login
+		if req.Login != nil {
+			newLogin = *req.Login
+		}
+		if h.passwordRejected(w, "password", newLogin, *req.Password) {
+			return
+		}
+		var err error
+		if hashed, err = password.Hash(*req.Password); err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	var (
+		until  time.Time
+		reason string
+	)
+	if req.BlockedUntil != nil && !req.BlockedUntil.IsZero() {
+		if !req.BlockedUntil.After(now) {
+			writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "blocked_until должен быть в будущем"})
+			return
+		}
+		until = req.BlockedUntil.UTC()
+	}
+	if req.BlockReason != nil {
+		reason = *req.BlockReason
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	var (
+		updated      models.User
+		revokeNeeded bool
+	)
+	err := h.store.UpdateUser(login, func(u *models.User) error {
+		if ifMatch != "" && !etagMatches(ifMatch, userETag(*u)) {
+			return errPreconditionFailed
+		}
+		if req.Login != nil {
+			u.Login = *req.Login
+		}
+		if req.Password != nil {
+			u.Password = hashed
+			revokeNeeded = true
+		}
+		if req.IsSlim != nil {
+			u.IsSlim = *req.IsSlim
+		}
+		if req.TelegramID != nil {
+			u.TelegramID = *req.TelegramID
+		}
+		if req.DiscordID != nil {
+			u.DiscordID = *req.DiscordID
+		}
+		if req.Blocked != nil && *req.Blocked != u.Blocked {
+			if *req.Blocked {
+				u.Ban(reason, adminName(r), now, until)
+				revokeNeeded = true
+			} else {
+				u.Unban(adminName(r), now)
+			}
+		} else if u.Blocked {
+			if req.BlockReason != nil {
+				u.BlockReason = *req.BlockReason
+			}
+			if req.BlockedUntil != nil {
+				u.BlockedUntil = until
+			}
+		}
+		updated = *u
+		return nil
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	case errors.Is(err, storage.ErrConflict):
+		writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Логин уже занят"})
+		return
+	case errors.Is(err, errPreconditionFailed):
+		writeJSON(w, http.StatusPreconditionFailed, models.ErrorResponse{Message: "Пользователь изменён другим запросом. Загрузите его заново."})
+		return
+	case err != nil:
+		log.Printf("[admin] update %s: %v", login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	if revokeNeeded {
+		h.revokeSessions(updated.UUID)
+	}
+	if h.limiter != nil && updated.Login != login {
+		if err := h.limiter.Rename(login, updated.Login); err != nil {
+			log.Printf("[admin] limiter rename %s: %v", login, err)
+		}
+	}
+	w.Header().Set("ETag", userETag(updated))
+	writeJSON(w, http.StatusOK, updated.Info())
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gml-auth/models"
+	"gml-auth/password"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func patchUser(h *AdminHandler, login, body, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/admin/users/"+login, strings.NewReader(body))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminPatchUserRenameWithIfMatch(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/gamervii", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", w.Code, etag)
+	}
+
+	w = patchUser(h, "GamerVII", `{"login":"Gamer_VII","is_slim":true}`, etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var u models.User
+	json.NewDecoder(w.Body).Decode(&u)
+	if u.Login != "Gamer_VII" || u.UUID != "uuid-1" || !u.IsSlim {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag must change after update")
+	}
+
+	// второй администратор с устаревшей версией
+	if w := patchUser(h, "Gamer_VII", `{"is_slim":false}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for stale ETag, got %d", w.Code)
+	}
+	if u, _ := s.FindByLogin("Gamer_VII"); !u.IsSlim {
+		t.Error("stale update must not be applied")
+	}
+}
+
+func TestAdminETagIgnoresSignIn(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	signIn := func() {
+		NewAuthHandler(s).SignIn(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/signin",
+			strings.NewReader(`{"Login":"GamerVII","Password":"pass123","Hwid":"AA-BB"}`)))
+	}
+	signIn() // первый вход переводит пароль из открытого вида в хэш — это смена записи
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/GamerVII", nil))
+	etag := w.Header().Get("ETag")
+
+	// игрок вошёл между чтением и изменением
+	signIn()
+	if u, _ := s.FindByLogin("GamerVII"); u.LastLoginAt.IsZero() {
+		t.Fatal("expected sign-in to be recorded")
+	}
+	if w := patchUser(h, "GamerVII", `{"is_slim":true}`, etag); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after sign-in, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminETagCoversPassword(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/GamerVII", nil))
+	etag := w.Header().Get("ETag")
+
+	if w := patchUser(h, "GamerVII", `{"password":"first-pass"}`, etag); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patchUser(h, "GamerVII", `{"password":"second-pass"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale ETag after password change, got %d", w.Code)
+	}
+}
+
+func TestAdminPatchUserValidation(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"login":"banned"}`, http.StatusConflict},
+		{`{"login":"x"}`, http.StatusBadRequest},
+		{`{"password":""}`, http.StatusBadRequest},
+		{`{"uuid":"other"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := patchUser(h, "GamerVII", c.body, ""); w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.body, c.code, w.Code)
+		}
+	}
+	if w := patchUser(h, "nobody", `{"is_slim":true}`, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestAdminPatchUserPasswordAndBlock(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	sessions.Issue("uuid-1", "GamerVII", "", "")
+	h := NewAdminHandler(s, WithSessions(sessions))
+
+	w := patchUser(h, "GamerVII", `{"password":"newpass","blocked":true,"block_reason":"спам"}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	u, _ := s.FindByLogin("GamerVII")
+	if ok, _ := password.Verify(u.Password, "newpass"); !ok || !password.IsHashed(u.Password) {
+		t.Error("expected new hashed password")
+	}
+	if !u.Blocked || u.BlockReason != "спам" {
+		t.Errorf("expected user to be blocked: %+v", u)
+	}
+	if len(sessions.List("uuid-1")) != 0 {
+		t.Error("expected sessions to be revoked")
+	}
+
+	patchUser(h, "GamerVII", `{"blocked":false}`, "")
+	if u, _ := s.FindByLogin("GamerVII"); u.Blocked || u.BlockReason != "" {
+		t.Errorf("expected user to be unblocked: %+v", u)
+	}
+}
+
+func TestAdminUsersNamedLikeActions(t *testing.T) {
+	s := setupStorage(t)
+	for _, login := range []string{"sessions", "skin", "bans", "groups"} {
+		s.AddUser(models.User{UUID: "uuid-" + login, Login: login, Password: "pass"})
+	}
+	h := NewAdminHandler(s)
+
+	for _, login := range []string{"sessions", "skin", "bans", "groups"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/"+login, nil))
+		var u models.UserInfo
+		json.NewDecoder(w.Body).Decode(&u)
+		if w.Code != http.StatusOK || u.Login != login {
+			t.Errorf("GET %s: %d %+v", login, w.Code, u)
+		}
+	}
+	if w := patchUser(h, "skin", `{"is_slim":true}`, ""); w.Code != http.StatusOK {
+		t.Errorf("PATCH skin: %d %s", w.Code, w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/users/sessions", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE sessions: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := s.FindByLogin("sessions"); err == nil {
+		t.Error("user sessions must be deleted")
+	}
+	// действие у пользователя с таким логином по-прежнему работает
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/bans/bans", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET bans/bans: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -4,17 +4,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gml-auth/audit"
+	"gml-auth/ipfilter"
+	"gml-auth/maintenance"
 	"gml-auth/models"
+	"gml-auth/session"
+	"gml-auth/signin"
 	"gml-auth/storage"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type AuthHandler struct {
-	store *storage.Storage
+	store storage.UserStore
+	deps
 }
 
-func NewAuthHandler(store *storage.Storage) *AuthHandler {
-	return &AuthHandler{store: store}
+func NewAuthHandler(store storage.UserStore, opts ...Option) *AuthHandler {
+	return &AuthHandler{store: store, deps: applyOptions(opts)}
+}
+
+// Gate — проверки входа этого обработчика для других точек входа (Yggdrasil)
+func (h *AuthHandler) Gate() *signin.Gate {
+	return h.gate(h.store)
 }
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
@@ -29,50 +44,184 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := ipfilter.ClientIP(r)
 	var req models.AuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Неверный формат запроса"})
+		h.reject(w, r, audit.Entry{Result: audit.ResultBadRequest}, http.StatusBadRequest, "Неверный формат запроса")
 		return
 	}
 
-	user, err := h.store.FindByLogin(req.Login)
-	if errors.Is(err, storage.ErrNotFound) {
-		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+	gate := h.gate(h.store)
+	user, device, denial := gate.Check(signin.Attempt{
+		Login: req.Login, Password: req.Password, TOTP: req.Totp, IP: ip, HWID: req.Hwid,
+	})
+	if denial != nil {
+		h.deny(w, r, denial)
 		return
 	}
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
-		return
+	attempt := audit.Entry{Login: user.Login, UserUUID: user.UUID, HWID: device}
+
+	resp := models.AuthResponse{
+		Login:    user.Login,
+		UserUuid: user.UUID,
+		IsSlim:   user.IsSlim,
+		Message:  "Успешная авторизация",
+		Groups:   user.ActiveGroups(time.Now()),
+	}
+	if h.sessions != nil {
+		tokens, err := h.sessions.Issue(user.UUID, user.Login, ip, r.UserAgent())
+		if err != nil {
+			log.Printf("[auth] issue session %s: %v", user.Login, err)
+			attempt.Result, attempt.Reason = audit.ResultError, err.Error()
+			h.reject(w, r, attempt, http.StatusInternalServerError, "Ошибка сервера")
+			return
+		}
+		resp.AccessToken = tokens.AccessToken
+		resp.RefreshToken = tokens.RefreshToken
+		resp.ExpiresIn = tokens.ExpiresIn
 	}
+	gate.RecordLogin(user.Login, ip, device)
+	attempt.Result, attempt.Code = audit.ResultSuccess, http.StatusOK
+	h.record(r, attempt)
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	if user.Blocked {
-		msg := fmt.Sprintf("Пользователь заблокирован. Причина: %s", user.BlockReason)
-		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: msg})
-		return
+// deny отвечает отказом проверки входа и записывает попытку в журнал
+func (h *AuthHandler) deny(w http.ResponseWriter, r *http.Request, d *signin.Denial) {
+	if d.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	}
+	h.reject(w, r, d.Entry, d.Code, d.Message)
+}
+
+// reject отвечает ошибкой и записывает попытку в журнал.
+// Причиной в журнале становится текст ответа, если своя причина не указана.
+func (h *AuthHandler) reject(w http.ResponseWriter, r *http.Request, e audit.Entry, code int, msg string) {
+	e.Code = code
+	if e.Reason == "" {
+		e.Reason = msg
 	}
+	h.record(r, e)
+	writeJSON(w, code, models.ErrorResponse{Message: msg})
+}
 
-	if user.Password != req.Password {
-		writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Неверный логин или пароль"})
+// record дописывает попытку входа в журнал (если он включён)
+func (h *AuthHandler) record(r *http.Request, e audit.Entry) {
+	h.gate(h.store).Record(r, e)
+}
+
+// Status — GET /api/v1/status: идут ли технические работы (для лаунчера, без авторизации)
+func (h *AuthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	status := maintenance.Status{}
+	if h.maintenance != nil {
+		var err error
+		if status, err = h.maintenance.Status(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+			return
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, status)
+}
 
-	writeJSON(w, http.StatusOK, models.AuthResponse{
-		Login:    user.Login,
-		UserUuid: user.UUID,
-		IsSlim:   user.IsSlim,
-		Message:  "Успешная авторизация",
+// throttled отвечает 429, если логин или IP временно заблокированы
+func (d *deps) throttled(w http.ResponseWriter, login, ip string) bool {
+	if d.limiter == nil {
+		return false
+	}
+	wait := d.limiter.Check(login, ip)
+	if wait <= 0 {
+		return false
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeJSON(w, http.StatusTooManyRequests, models.ErrorResponse{
+		Message: fmt.Sprintf("Слишком много попыток входа. Повторите через %d сек.", seconds),
 	})
+	return true
+}
+
+// fail учитывает неудачную попытку входа
+func (d *deps) fail(login, ip string) {
+	if d.limiter == nil {
+		return
+	}
+	if err := d.limiter.Fail(login, ip); err != nil {
+		log.Printf("[auth] limiter: %v", err)
+	}
+}
+
+// succeed сбрасывает счётчик неудач логина после верного пароля (и кода 2FA)
+func (d *deps) succeed(login string) {
+	if d.limiter == nil {
+		return
+	}
+	if err := d.limiter.Success(login); err != nil {
+		log.Printf("[auth] limiter: %v", err)
+	}
 }
 
-// Refresh — GET /api/v1/users/refresh
-// Сервер не хранит сессии, поэтому всегда 401.
-// GML Launcher web-панель при 401 перенаправит на страницу входа.
+// Refresh — /api/v1/users/refresh
+// Обменивает refresh token на новую пару токенов. Токен передаётся
+// в заголовке Authorization: Bearer или в теле POST {"refreshToken": "..."}.
+// При 401 GML Launcher web-панель перенаправит на страницу входа.
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	writeJSON(w, http.StatusUnauthorized, models.WebErrorResponse{
-		Errors: []string{"Сессия не найдена. Выполните вход."},
+	unauthorized := func() {
+		writeJSON(w, http.StatusUnauthorized, models.WebErrorResponse{
+			Errors: []string{"Сессия не найдена. Выполните вход."},
+		})
+	}
+	if h.sessions == nil {
+		unauthorized()
+		return
+	}
+
+	token := bearerToken(r)
+	if token == "" && r.Method == http.MethodPost {
+		var req models.RefreshRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		token = req.RefreshToken
+	}
+	if token == "" {
+		unauthorized()
+		return
+	}
+
+	tokens, sess, err := h.sessions.Refresh(token)
+	if err != nil {
+		unauthorized()
+		return
+	}
+	user, err := h.store.FindByUUID(sess.UserUUID)
+	if err != nil || user.IsBlocked(time.Now()) {
+		h.revokeSessions(sess.UserUUID)
+		unauthorized()
+		return
+	}
+	// заблокированное устройство, технические работы и закрытый вход
+	// действуют и на уже открытые сессии
+	if denial := h.gate(h.store).Admit(user); denial != nil {
+		if err := h.sessions.Revoke(sess.UserUUID, sess.ID); err != nil && !errors.Is(err, session.ErrNotFound) {
+			log.Printf("[auth] revoke session %s: %v", user.Login, err)
+		}
+		writeJSON(w, denial.Code, models.WebErrorResponse{Errors: []string{denial.Message}})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.AuthResponse{
+		Login:        user.Login,
+		UserUuid:     user.UUID,
+		IsSlim:       user.IsSlim,
+		Message:      "Сессия обновлена",
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	})
 }
@@ -2,21 +2,30 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
+	"gml-auth/audit"
+	"gml-auth/hwid"
+	"gml-auth/ipfilter"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
 	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/session"
 	"gml-auth/storage"
+	"gml-auth/totp"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupStorage(t *testing.T) *storage.Storage {
-	f, _ := os.CreateTemp("", "test-*.json")
-	f.WriteString(`{"users":[]}`)
-	f.Close()
-	t.Cleanup(func() { os.Remove(f.Name()) })
-	s := storage.New(f.Name())
+	path := filepath.Join(t.TempDir(), "users.json")
+	os.WriteFile(path, []byte(`{"users":[]}`), 0644)
+	s := storage.New(path)
 	s.AddUser(models.User{
 		UUID: "uuid-1", Login: "GamerVII", Password: "pass123",
 		IsSlim: false, Blocked: false,
@@ -41,6 +50,9 @@ func TestAuthSuccess(t *testing.T) {
 	if !strings.Contains(w.Body.String(), "GamerVII") {
 		t.Errorf("expected login in response: %s", w.Body.String())
 	}
+	if u, _ := s.FindByLogin("GamerVII"); u.LastLoginAt.IsZero() || u.LastLoginIP != "192.0.2.1" {
+		t.Errorf("expected last login time and IP to be recorded: %+v", u)
+	}
 }
 
 func TestAuthWrongPassword(t *testing.T) {
@@ -78,3 +90,326 @@ func TestAuthBlocked(t *testing.T) {
 		t.Errorf("expected 403, got %d", w.Code)
 	}
 }
+
+func TestAuthRehashesPlainTextPassword(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAuthHandler(s)
+	body := `{"Login":"GamerVII","Password":"pass123","Totp":""}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/integrations/auth/signin", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.SignIn(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	u, _ := s.FindByLogin("GamerVII")
+	if !password.IsHashed(u.Password) {
+		t.Fatalf("expected password to be hashed after login, got %q", u.Password)
+	}
+
+	// повторный вход уже по хэшу
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/integrations/auth/signin", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	h.SignIn(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 after rehash, got %d", w.Code)
+	}
+}
+
+func signIn(h *AuthHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/integrations/auth/signin", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.SignIn(w, req)
+	return w
+}
+
+func TestAuthTOTP(t *testing.T) {
+	s := setupStorage(t)
+	secret, _ := totp.GenerateSecret()
+	_, hashes, _ := totp.GenerateRecoveryCodes(1)
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.TOTPSecret = secret
+		u.RecoveryCodes = hashes
+		return nil
+	})
+	h := NewAuthHandler(s)
+
+	w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(strings.ToLower(w.Body.String()), "2fa") {
+		t.Fatalf("expected 401 with 2fa prompt, got %d: %s", w.Code, w.Body.String())
+	}
+
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	w = signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":"`+code+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with valid code, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":"`+code+`"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for replayed code, got %d", w.Code)
+	}
+}
+
+func TestAuthTOTPRecoveryCode(t *testing.T) {
+	s := setupStorage(t)
+	secret, _ := totp.GenerateSecret()
+	codes, hashes, _ := totp.GenerateRecoveryCodes(2)
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.TOTPSecret = secret
+		u.RecoveryCodes = hashes
+		return nil
+	})
+	h := NewAuthHandler(s)
+
+	body := `{"Login":"GamerVII","Password":"pass123","Totp":"` + codes[0] + `"}`
+	if w := signIn(h, body); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with recovery code, got %d", w.Code)
+	}
+	if w := signIn(h, body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected recovery code to be single-use, got %d", w.Code)
+	}
+	u, _ := s.FindByLogin("GamerVII")
+	if len(u.RecoveryCodes) != 1 {
+		t.Errorf("expected 1 recovery code left, got %d", len(u.RecoveryCodes))
+	}
+}
+
+func TestAuthLockoutAfterFailures(t *testing.T) {
+	s := setupStorage(t)
+	lim := limiter.New(limiter.Config{
+		Window:           time.Minute,
+		MaxLoginFailures: 2,
+		Lockout:          time.Minute,
+	}, storage.NewDocument[limiter.State](filepath.Join(t.TempDir(), "lockouts.json")))
+	h := NewAuthHandler(s, WithLimiter(lim))
+
+	for range 2 {
+		if w := signIn(h, `{"Login":"GamerVII","Password":"wrong","Totp":""}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", w.Code)
+		}
+	}
+	w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 while locked, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func newTestSessions(t *testing.T) *session.Store {
+	return session.New(session.Config{AccessTTL: time.Minute, RefreshTTL: time.Hour},
+		storage.NewDocument[session.State](filepath.Join(t.TempDir(), "sessions.json")))
+}
+
+func TestAuthIssuesAndRefreshesSession(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAuthHandler(s, WithSessions(newTestSessions(t)))
+
+	w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	var resp models.AuthResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected tokens in signin response: %+v", resp)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.RefreshToken)
+	w = httptest.NewRecorder()
+	h.Refresh(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var refreshed models.AuthResponse
+	json.NewDecoder(w.Body).Decode(&refreshed)
+	if refreshed.UserUuid != "uuid-1" || refreshed.RefreshToken == resp.RefreshToken {
+		t.Errorf("expected rotated tokens for uuid-1: %+v", refreshed)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/users/refresh",
+		bytes.NewBufferString(`{"refreshToken":"`+refreshed.RefreshToken+`"}`))
+	w = httptest.NewRecorder()
+	h.Refresh(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for body token, got %d", w.Code)
+	}
+}
+
+func TestRefreshChecksGate(t *testing.T) {
+	s := setupStorage(t)
+	works := maintenance.New(storage.NewDocument[maintenance.State](filepath.Join(t.TempDir(), "maintenance.json")), maintenance.Settings{})
+	bans := hwid.NewBans(storage.NewDocument[hwid.State](filepath.Join(t.TempDir(), "hwid_bans.json")))
+	h := NewAuthHandler(s, WithSessions(newTestSessions(t)), WithMaintenance(works), WithHWID(bans, 0))
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.Refresh(w, req)
+		return w
+	}
+	signInTokens := func() models.AuthResponse {
+		var resp models.AuthResponse
+		json.NewDecoder(signIn(h, `{"Login":"GamerVII","Password":"pass123","Hwid":"pc-1"}`).Body).Decode(&resp)
+		return resp
+	}
+
+	tokens := signInTokens()
+	if _, err := works.Set(maintenance.Settings{Enabled: true, Message: "Вайп до 18:00"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if w := refresh(tokens.RefreshToken); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Вайп до 18:00") {
+		t.Fatalf("expected 403 during maintenance, got %d %s", w.Code, w.Body.String())
+	}
+	works.Set(maintenance.Settings{}, "test")
+	if w := refresh(tokens.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("denied session must be revoked, got %d", w.Code)
+	}
+
+	tokens = signInTokens()
+	if _, err := bans.Add("pc-1", "твинки", "test", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if w := refresh(tokens.RefreshToken); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "твинки") {
+		t.Errorf("expected 403 after HWID ban, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRefreshWithoutSessions(t *testing.T) {
+	h := NewAuthHandler(setupStorage(t))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/refresh", nil)
+	w := httptest.NewRecorder()
+	h.Refresh(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestAuthWritesAuditLog(t *testing.T) {
+	s := setupStorage(t)
+	attempts := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer attempts.Close()
+	h := NewAuthHandler(s, WithAudit(attempts))
+
+	signIn(h, `{"Login":"GamerVII","Password":"wrong","Totp":""}`)
+	signIn(h, `{"Login":"nobody","Password":"x","Totp":""}`)
+	signIn(h, `{"Login":"banned","Password":"pass","Totp":""}`)
+	signIn(h, `{"Login":"gamervii","Password":"pass123","Totp":""}`)
+
+	entries, err := attempts.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		login, result string
+		code          int
+	}{
+		{"GamerVII", audit.ResultSuccess, http.StatusOK},
+		{"banned", audit.ResultBlocked, http.StatusForbidden},
+		{"nobody", audit.ResultNotFound, http.StatusNotFound},
+		{"GamerVII", audit.ResultBadPassword, http.StatusUnauthorized},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Login != want[i].login || e.Result != want[i].result || e.Code != want[i].code || e.IP != "192.0.2.1" {
+			t.Errorf("entry %d: unexpected %+v", i, e)
+		}
+	}
+
+	admin := NewAdminHandler(s, WithAudit(attempts))
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/GamerVII/logins?result=success", nil))
+	var history []audit.Entry
+	json.NewDecoder(w.Body).Decode(&history)
+	if w.Code != http.StatusOK || len(history) != 1 || history[0].UserUUID != "uuid-1" {
+		t.Errorf("unexpected login history: %d %+v", w.Code, history)
+	}
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad limit, got %d", w.Code)
+	}
+}
+
+func TestAuthIPBan(t *testing.T) {
+	s := setupStorage(t)
+	bans := ipfilter.NewBans(storage.NewDocument[ipfilter.State](filepath.Join(t.TempDir(), "ip_bans.json")))
+	admin := NewAdminHandler(s, WithIPBans(bans))
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/ip-bans",
+		strings.NewReader(`{"cidr":"192.0.2.0/24","reason":"ботнет","duration":"1h"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var ban ipfilter.Ban
+	json.NewDecoder(w.Body).Decode(&ban)
+
+	h := NewAuthHandler(s, WithIPBans(bans))
+	w = signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ботнет") {
+		t.Fatalf("expected 403 with reason, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/ip-bans/"+ban.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w = signIn(h, `{"Login":"GamerVII","Password":"pass123","Totp":""}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 after unban, got %d", w.Code)
+	}
+}
+
+func TestAuthHWID(t *testing.T) {
+	s := setupStorage(t)
+	hashed, _ := password.Hash("pass")
+	s.AddUser(models.User{UUID: "uuid-3", Login: "Twink", Password: hashed})
+	bans := hwid.NewBans(storage.NewDocument[hwid.State](filepath.Join(t.TempDir(), "hwid_bans.json")))
+	h := NewAuthHandler(s, WithHWID(bans, 1))
+
+	if w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Hwid":"PC-1"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if u, _ := s.FindByLogin("GamerVII"); !u.HasDevice("pc-1") {
+		t.Fatalf("expected device to be stored: %+v", u.Devices)
+	}
+	// второй аккаунт с того же устройства — сверх лимита
+	if w := signIn(h, `{"Login":"Twink","Password":"pass","Hwid":"pc-1"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 over the account limit, got %d", w.Code)
+	}
+	// уже привязанный аккаунт входит как обычно
+	if w := signIn(h, `{"Login":"GamerVII","Password":"pass123","Hwid":"pc-1"}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a known device, got %d", w.Code)
+	}
+
+	admin := NewAdminHandler(s, WithHWID(bans, 1))
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/hwid-bans", strings.NewReader(`{"hwid":"PC-1","reason":"твинки"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	// блокировка устройства действует и без HWID в запросе: аккаунт с него уже входил
+	if w := signIn(h, `{"Login":"GamerVII","Password":"pass123"}`); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "твинки") {
+		t.Errorf("expected 403 for an account on a banned device, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminLinkedAccounts(t *testing.T) {
+	s := setupStorage(t)
+	now := time.Now().UTC()
+	for _, login := range []string{"GamerVII", "banned"} {
+		s.UpdateUser(login, func(u *models.User) error {
+			u.SeeDevice("pc-1", now)
+			return nil
+		})
+	}
+	w := httptest.NewRecorder()
+	NewAdminHandler(s).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/GamerVII/linked", nil))
+	var linked []models.LinkedAccount
+	json.NewDecoder(w.Body).Decode(&linked)
+	if w.Code != http.StatusOK || len(linked) != 1 || linked[0].Login != "banned" || !linked[0].Blocked || linked[0].HWIDs[0] != "pc-1" {
+		t.Errorf("unexpected linked accounts: %d %+v", w.Code, linked)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"gml-auth/models"
 	"gml-auth/news"
 	"net/http"
 	"strconv"
@@ -39,6 +40,39 @@ func (h *NewsHandler) List(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, items)
 }
 
+// NewsFeeds — источники и объединённые ленты по имени
+type NewsFeeds interface {
+	Get(name string) (news.Reader, bool)
+}
+
+// NewsFeedHandler — GET /api/news/{name}: источник или объединённая лента
+type NewsFeedHandler struct {
+	feeds NewsFeeds
+}
+
+func NewNewsFeedHandler(feeds NewsFeeds) *NewsFeedHandler {
+	return &NewsFeedHandler{feeds: feeds}
+}
+
+func (h *NewsFeedHandler) List(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	feed, ok := h.feeds.Get(name)
+	if !ok && (name == news.TypeTelegram || name == news.TypeDiscord) {
+		// эти адреса были всегда: без источника лаунчер получает пустой список, а не 404
+		feed, ok = noNews{}, true
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Лента не найдена"})
+		return
+	}
+	NewNewsHandler(feed).List(w, r)
+}
+
+// noNews — лента без новостей
+type noNews struct{}
+
+func (noNews) Get(limit, offset int) []news.NewsItem { return nil }
+
 func queryInt(r *http.Request, key string, defaultVal int) int {
 	s := r.URL.Query().Get(key)
 	if s == "" {
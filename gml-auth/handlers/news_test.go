@@ -5,6 +5,7 @@ import (
 	"gml-auth/news"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -76,3 +77,38 @@ func TestNewsHandlerEmpty(t *testing.T) {
 		t.Errorf("expected empty array, got: %s", body)
 	}
 }
+
+type mockFeeds map[string]news.Reader
+
+func (m mockFeeds) Get(name string) (news.Reader, bool) {
+	r, ok := m[name]
+	return r, ok
+}
+
+func TestNewsFeedHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/news/{name}", NewNewsFeedHandler(mockFeeds{
+		"events": &mockCache{items: []news.NewsItem{{ID: 1, Title: "A"}, {ID: 2, Title: "B"}}},
+	}).List)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/news/events?limit=1", nil))
+	var items []news.NewsItem
+	json.NewDecoder(w.Body).Decode(&items)
+	if w.Code != http.StatusOK || len(items) != 1 || items[0].Title != "A" {
+		t.Fatalf("unexpected response %d %+v", w.Code, items)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/news/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown feed, got %d", w.Code)
+	}
+
+	// встроенные адреса без источника отвечают пустым списком
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/news/telegram", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected [] for unconfigured telegram, got %d %s", w.Code, w.Body.String())
+	}
+}
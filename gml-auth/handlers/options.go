@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"gml-auth/apikey"
+	"gml-auth/audit"
+	"gml-auth/groups"
+	"gml-auth/hwid"
+	"gml-auth/invites"
+	"gml-auth/ipfilter"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"gml-auth/notify"
+	"gml-auth/password"
+	"gml-auth/recovery"
+	"gml-auth/session"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"gml-auth/textures"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// deps — необязательные зависимости обработчиков.
+// nil означает, что соответствующая функция выключена.
+type deps struct {
+	limiter  *limiter.Limiter
+	sessions *session.Store
+	apiKeys  *apikey.Store
+	revokers []func(userUUID string) error
+	textures *textures.Store
+	baseURL  string // внешний адрес сервера для ссылок на текстуры
+	audit    *audit.Log
+	ipBans   *ipfilter.Bans
+	hwidBans *hwid.Bans
+	// maxAccountsPerHWID — сколько аккаунтов может входить с одного устройства; 0 — без ограничения
+	maxAccountsPerHWID int
+	groups             *groups.Store
+	signInPolicy       groups.Policy // кому разрешён вход; пустое — всем
+	maintenance        *maintenance.Store
+	invites            *invites.Store // nil — регистрация выключена
+	registrations      *limiter.Rate  // регистрации с одного IP
+	passwordPolicy     password.Policy
+	uuidStrategy       string                   // storage.UUIDRandom, UUIDOffline или UUIDImported; пусто — random
+	recovery           *recovery.Store          // nil — сброс пароля выключен
+	senders            map[string]notify.Sender // боты для кодов по каналам (telegram, discord)
+}
+
+// Option настраивает AuthHandler и AdminHandler
+type Option func(*deps)
+
+// WithLimiter включает защиту от перебора паролей
+func WithLimiter(l *limiter.Limiter) Option {
+	return func(d *deps) { d.limiter = l }
+}
+
+// WithSessions включает выдачу токенов при входе и /api/v1/users/refresh
+func WithSessions(s *session.Store) Option {
+	return func(d *deps) { d.sessions = s }
+}
+
+// WithAPIKeys требует API ключ с нужной областью для всех запросов к /admin/
+func WithAPIKeys(s *apikey.Store) Option {
+	return func(d *deps) { d.apiKeys = s }
+}
+
+// WithRevoker добавляет ещё одно хранилище токенов (например, Yggdrasil),
+// которое нужно чистить вместе с сессиями
+func WithRevoker(fn func(userUUID string) error) Option {
+	return func(d *deps) { d.revokers = append(d.revokers, fn) }
+}
+
+// WithTextures включает загрузку скинов и плащей.
+// baseURL — внешний адрес сервера, из него строятся ссылки /textures/{hash}.
+func WithTextures(s *textures.Store, baseURL string) Option {
+	return func(d *deps) {
+		d.textures = s
+		d.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAudit включает журнал попыток входа и /admin/audit
+func WithAudit(l *audit.Log) Option {
+	return func(d *deps) { d.audit = l }
+}
+
+// WithIPBans включает блокировку адресов и подсетей при входе и /admin/ip-bans
+func WithIPBans(b *ipfilter.Bans) Option {
+	return func(d *deps) { d.ipBans = b }
+}
+
+// WithHWID включает блокировку устройств и /admin/hwid-bans.
+// maxAccounts ограничивает число аккаунтов на одном устройстве (0 — без ограничения).
+func WithHWID(b *hwid.Bans, maxAccounts int) Option {
+	return func(d *deps) {
+		d.hwidBans = b
+		d.maxAccountsPerHWID = maxAccounts
+	}
+}
+
+// WithGroups включает справочник групп и /admin/groups
+func WithGroups(s *groups.Store) Option {
+	return func(d *deps) { d.groups = s }
+}
+
+// WithSignInPolicy разрешает вход только участникам групп или логинам из p
+// (закрытый тест, вход только для персонала)
+func WithSignInPolicy(p groups.Policy) Option {
+	return func(d *deps) { d.signInPolicy = p }
+}
+
+// WithMaintenance включает режим технических работ, /admin/maintenance и /api/v1/status
+func WithMaintenance(s *maintenance.Store) Option {
+	return func(d *deps) { d.maintenance = s }
+}
+
+// WithRegistration включает регистрацию по кодам приглашений (/api/v1/register)
+// и /admin/invites. perIP ограничивает число регистраций с одного адреса (nil — без ограничения).
+func WithRegistration(inv *invites.Store, perIP *limiter.Rate) Option {
+	return func(d *deps) {
+		d.invites = inv
+		d.registrations = perIP
+	}
+}
+
+// WithPasswordPolicy задаёт требования к новым паролям. Без неё нужен только непустой пароль.
+func WithPasswordPolicy(p password.Policy) Option {
+	return func(d *deps) { d.passwordPolicy = p }
+}
+
+// WithRecovery включает привязку Telegram/Discord (/api/v1/account/links/...)
+// и сброс пароля кодом (/api/v1/password-reset). senders — боты по именам каналов.
+func WithRecovery(s *recovery.Store, senders map[string]notify.Sender) Option {
+	return func(d *deps) {
+		d.recovery = s
+		d.senders = senders
+	}
+}
+
+// WithUUIDStrategy задаёт, как выдаются UUID новым аккаунтам (см. storage.NewUUID)
+func WithUUIDStrategy(strategy string) Option {
+	return func(d *deps) { d.uuidStrategy = strategy }
+}
+
+// gate — проверки входа (см. signin.Gate) с зависимостями обработчика
+func (d *deps) gate(store storage.UserStore) *signin.Gate {
+	return &signin.Gate{
+		Store:              store,
+		Limiter:            d.limiter,
+		IPBans:             d.ipBans,
+		HWIDBans:           d.hwidBans,
+		Maintenance:        d.maintenance,
+		Policy:             d.signInPolicy,
+		MaxAccountsPerHWID: d.maxAccountsPerHWID,
+		Audit:              d.audit,
+	}
+}
+
+// revokeSessions завершает все сессии пользователя (блокировка, удаление, смена пароля)
+func (d *deps) revokeSessions(userUUID string) {
+	if d.sessions != nil {
+		if _, err := d.sessions.RevokeUser(userUUID); err != nil {
+			log.Printf("[sessions] revoke %s: %v", userUUID, err)
+		}
+	}
+	for _, revoke := range d.revokers {
+		if err := revoke(userUUID); err != nil {
+			log.Printf("[sessions] revoke %s: %v", userUUID, err)
+		}
+	}
+}
+
+// revokeOtherSessions завершает сессии пользователя, кроме текущей keepID
+// (игрок сменил пароль или вышел на других устройствах). Токены Yggdrasil отзываются все.
+func (d *deps) revokeOtherSessions(userUUID, keepID string) {
+	if d.sessions != nil {
+		if _, err := d.sessions.RevokeOthers(userUUID, keepID); err != nil {
+			log.Printf("[sessions] revoke %s: %v", userUUID, err)
+		}
+	}
+	for _, revoke := range d.revokers {
+		if err := revoke(userUUID); err != nil {
+			log.Printf("[sessions] revoke %s: %v", userUUID, err)
+		}
+	}
+}
+
+// passwordRejected проверяет новый пароль по политике и при нарушениях отвечает 400
+// со списком ошибок: Message — для лаунчера, errors[] и fields[] — для web-панели
+func (d *deps) passwordRejected(w http.ResponseWriter, field, login, plain string) bool {
+	violations := d.passwordPolicy.Check(login, plain)
+	if len(violations) == 0 {
+		return false
+	}
+	resp := models.ValidationErrorResponse{Message: violations[0].Message}
+	for _, v := range violations {
+		resp.Errors = append(resp.Errors, v.Message)
+		resp.Fields = append(resp.Fields, models.FieldError{Field: field, Code: v.Code, Message: v.Message})
+	}
+	writeJSON(w, http.StatusBadRequest, resp)
+	return true
+}
+
+// bearerToken извлекает токен из заголовка Authorization: Bearer <token>
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+		return strings.TrimSpace(h[len(prefix):])
+	}
+	return ""
+}
+
+func applyOptions(opts []Option) deps {
+	var d deps
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return d
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/ipfilter"
+	"gml-auth/models"
+	"gml-auth/notify"
+	"gml-auth/password"
+	"gml-auth/recovery"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// contactPattern — ID пользователя Telegram или Discord (snowflake) — только цифры
+var contactPattern = regexp.MustCompile(`^[0-9]{1,32}$`)
+
+// contactID — привязанный ID пользователя в канале; пусто — не привязан
+func contactID(u models.User, channel string) string {
+	switch channel {
+	case notify.ChannelTelegram:
+		return u.TelegramID
+	case notify.ChannelDiscord:
+		return u.DiscordID
+	}
+	return ""
+}
+
+func setContactID(u *models.User, channel, id string) {
+	switch channel {
+	case notify.ChannelTelegram:
+		u.TelegramID = id
+	case notify.ChannelDiscord:
+		u.DiscordID = id
+	}
+}
+
+// resetChannel выбирает канал для кода: запрошенный, если он привязан,
+// иначе первый привязанный, для которого настроен бот
+func (d *deps) resetChannel(u models.User, preferred string) (string, notify.Sender) {
+	for _, ch := range []string{preferred, notify.ChannelTelegram, notify.ChannelDiscord} {
+		if s := d.senders[ch]; s != nil && contactID(u, ch) != "" {
+			return ch, s
+		}
+	}
+	return "", nil
+}
+
+// resetSentMessage — одинаковый ответ для любого логина, чтобы по нему нельзя было
+// узнать, существует ли аккаунт и привязан ли к нему мессенджер
+const resetSentMessage = "Если к аккаунту привязан Telegram или Discord, код отправлен туда. Повторный запрос возможен через минуту."
+
+// PasswordReset — POST /api/v1/password-reset: {"login": "Steve", "channel": "telegram"}.
+// Выпускает одноразовый код и отправляет его в привязанный мессенджер.
+func (h *AuthHandler) PasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.recovery == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сброс пароля выключен"})
+		return
+	}
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Login == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужен login"})
+		return
+	}
+	ip := ipfilter.ClientIP(r)
+	if ban, ok := h.ipBanned(ip); ok {
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: signin.IPBlockedMessage(ban, time.Now())})
+		return
+	}
+	if h.throttled(w, req.Login, ip) {
+		return
+	}
+
+	user, err := h.store.FindByLogin(req.Login)
+	if err != nil || user.IsBlocked(time.Now()) {
+		writeJSON(w, http.StatusAccepted, models.ErrorResponse{Message: resetSentMessage})
+		return
+	}
+	channel, sender := h.resetChannel(user, req.Channel)
+	if sender == nil {
+		writeJSON(w, http.StatusAccepted, models.ErrorResponse{Message: resetSentMessage})
+		return
+	}
+	code, err := h.recovery.Issue(user.UUID, channel)
+	if errors.Is(err, recovery.ErrTooSoon) {
+		writeJSON(w, http.StatusAccepted, models.ErrorResponse{Message: resetSentMessage})
+		return
+	}
+	if err != nil {
+		log.Printf("[recovery] issue %s: %v", user.Login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+		return
+	}
+	text := "Код для сброса пароля " + user.Login + ": " + code +
+		"\nЕсли вы его не запрашивали, просто проигнорируйте это сообщение."
+	if err := sender.Send(contactID(user, channel), text); err != nil {
+		log.Printf("[recovery] send to %s via %s: %v", user.Login, channel, err)
+		if err := h.recovery.Cancel(user.UUID); err != nil {
+			log.Printf("[recovery] cancel %s: %v", user.Login, err)
+		}
+	} else {
+		log.Printf("[recovery] код для %s отправлен в %s (%s)", user.Login, channel, ip)
+	}
+	writeJSON(w, http.StatusAccepted, models.ErrorResponse{Message: resetSentMessage})
+}
+
+// PasswordResetConfirm — POST /api/v1/password-reset/confirm:
+// {"login": "Steve", "code": "123456", "new_password": "..."}.
+// Ставит новый пароль и завершает все сессии. Неверные коды учитываются защитой от перебора.
+func (h *AuthHandler) PasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.recovery == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Сброс пароля выключен"})
+		return
+	}
+	var req models.PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Login == "" || req.Code == "" || req.NewPassword == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужны login, code и new_password"})
+		return
+	}
+	ip := ipfilter.ClientIP(r)
+	if ban, ok := h.ipBanned(ip); ok {
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: signin.IPBlockedMessage(ban, time.Now())})
+		return
+	}
+	if h.throttled(w, req.Login, ip) {
+		return
+	}
+	// пароль проверяется до кода, чтобы неподходящий пароль не сжигал попытку
+	if h.passwordRejected(w, "new_password", req.Login, req.NewPassword) {
+		return
+	}
+
+	user, err := h.store.FindByLogin(req.Login)
+	if err == nil {
+		err = h.recovery.Verify(user.UUID, req.Code)
+	}
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, recovery.ErrInvalidCode) &&
+			!errors.Is(err, recovery.ErrExpired) && !errors.Is(err, recovery.ErrTooManyAttempts) {
+			log.Printf("[recovery] verify %s: %v", req.Login, err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+			return
+		}
+		h.fail(user.Login, ip)
+		msg := "Неверный или устаревший код"
+		if errors.Is(err, recovery.ErrTooManyAttempts) {
+			msg = "Слишком много неверных попыток. Запросите новый код"
+		}
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+		return
+	}
+
+	hashed, err := password.Hash(req.NewPassword)
+	if err == nil {
+		err = h.store.UpdateUser(user.Login, func(u *models.User) error {
+			u.Password = hashed
+			return nil
+		})
+	}
+	if err != nil {
+		log.Printf("[recovery] update %s: %v", user.Login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	h.revokeSessions(user.UUID)
+	if h.limiter != nil {
+		if err := h.limiter.Success(user.Login); err != nil {
+			log.Printf("[recovery] limiter: %v", err)
+		}
+	}
+	log.Printf("[recovery] пароль %s сброшен (%s)", user.Login, ip)
+	writeJSON(w, http.StatusOK, models.ErrorResponse{Message: "Пароль изменён. Войдите с новым паролем."})
+}
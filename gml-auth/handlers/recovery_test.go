@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"gml-auth/limiter"
+	"gml-auth/models"
+	"gml-auth/notify"
+	"gml-auth/password"
+	"gml-auth/recovery"
+	"gml-auth/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSender запоминает отправленные сообщения; fail — ID, которым бот написать не может
+type fakeSender struct {
+	sent map[string]string
+	fail string
+}
+
+func (f *fakeSender) Send(userID, text string) error {
+	if userID == f.fail {
+		return errors.New("bot can't initiate conversation")
+	}
+	f.sent[userID] = text
+	return nil
+}
+
+func postJSON(fn http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	fn(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestPasswordResetViaTelegram(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	tg := &fakeSender{sent: map[string]string{}, fail: "666"}
+	resets := recovery.New(recovery.Config{TTL: 15 * time.Minute, Resend: time.Minute, MaxAttempts: 3},
+		storage.NewDocument[recovery.State](filepath.Join(t.TempDir(), "password_resets.json")))
+	opts := []Option{WithSessions(sessions), WithRecovery(resets, map[string]notify.Sender{notify.ChannelTelegram: tg})}
+	account := NewAccountHandler(s, opts...)
+	auth := NewAuthHandler(s, opts...)
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }
+
+	// привязка: нужен текущий пароль, бот должен суметь написать, ID только числовой
+	link := func(body string) *httptest.ResponseRecorder {
+		return accountRequest(account, http.MethodPut, "/api/v1/account/links/telegram", body, bearer)
+	}
+	if w := link(`{"id":"@steve","current_password":"pass123"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-numeric id, got %d", w.Code)
+	}
+	if w := link(`{"id":"12345"}`); w.Code != http.StatusForbidden || len(tg.sent) != 0 {
+		t.Fatalf("expected 403 without current password, got %d", w.Code)
+	}
+	if w := link(`{"id":"666","current_password":"pass123"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when bot can't write, got %d", w.Code)
+	}
+	if w := accountRequest(account, http.MethodPut, "/api/v1/account/links/discord", `{"id":"42","current_password":"pass123"}`, bearer); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for channel without bot, got %d", w.Code)
+	}
+	if w := link(`{"id":"12345","current_password":"pass123"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	linkCode := regexp.MustCompile(`[0-9]{6}`).FindString(tg.sent["12345"])
+	if user, _ := s.FindByLogin("GamerVII"); user.TelegramID != "" || linkCode == "" {
+		t.Fatalf("expected code in chat and no link yet, got %+v %q", user, tg.sent["12345"])
+	}
+	if w := link(`{"id":"54321","code":"` + linkCode + `"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("code must be bound to the chat, got %d", w.Code)
+	}
+	if w := link(`{"id":"12345","code":"` + linkCode + `"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if user, _ := s.FindByLogin("GamerVII"); user.TelegramID != "12345" {
+		t.Fatalf("expected linked telegram, got %+v", user)
+	}
+
+	// ответ не зависит от существования аккаунта
+	if w := postJSON(auth.PasswordReset, "/api/v1/password-reset", `{"login":"nobody"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for unknown login, got %d", w.Code)
+	}
+	if w := postJSON(auth.PasswordReset, "/api/v1/password-reset", `{"login":"gamervii"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", w.Code)
+	}
+	code := regexp.MustCompile(`[0-9]{6}`).FindString(tg.sent["12345"])
+	if code == "" {
+		t.Fatalf("no code in message %q", tg.sent["12345"])
+	}
+
+	if w := postJSON(auth.PasswordResetConfirm, "/api/v1/password-reset/confirm",
+		`{"login":"GamerVII","code":"`+code+`","new_password":""}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty password, got %d", w.Code)
+	}
+	if w := postJSON(auth.PasswordResetConfirm, "/api/v1/password-reset/confirm",
+		`{"login":"GamerVII","code":"000000x","new_password":"newpass1"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for wrong code, got %d", w.Code)
+	}
+	w := postJSON(auth.PasswordResetConfirm, "/api/v1/password-reset/confirm",
+		`{"login":"GamerVII","code":"`+code+`","new_password":"newpass1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	user, _ := s.FindByLogin("GamerVII")
+	if ok, _ := password.Verify(user.Password, "newpass1"); !ok || user.Password == "newpass1" {
+		t.Fatalf("expected new hashed password, got %q", user.Password)
+	}
+	if _, err := sessions.Authenticate(tokens.AccessToken); err == nil {
+		t.Error("sessions must be revoked after reset")
+	}
+	if w := postJSON(auth.PasswordResetConfirm, "/api/v1/password-reset/confirm",
+		`{"login":"GamerVII","code":"`+code+`","new_password":"another1"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("code must be single-use, got %d", w.Code)
+	}
+
+	// отвязка тоже требует пароль, а при 2FA — и код
+	tokens, _ = sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	unlink := func(body string) *httptest.ResponseRecorder {
+		return accountRequest(account, http.MethodDelete, "/api/v1/account/links/telegram", body, bearer)
+	}
+	if w := unlink(`{"current_password":"pass123"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for old password, got %d", w.Code)
+	}
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		return nil
+	})
+	if w := unlink(`{"current_password":"newpass1"}`); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "2FA") {
+		t.Fatalf("expected 403 without 2FA code, got %d %s", w.Code, w.Body.String())
+	}
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.TOTPSecret = ""
+		return nil
+	})
+	if w := unlink(`{"current_password":"newpass1"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if user, _ := s.FindByLogin("GamerVII"); user.TelegramID != "" {
+		t.Errorf("expected telegram unlinked, got %+v", user)
+	}
+}
+
+func TestAdminSetsContactIDs(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/admin/users/GamerVII", bytes.NewBufferString(`{"discord_id":"abc"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/admin/users/GamerVII", bytes.NewBufferString(`{"discord_id":"80351110224678912"}`)))
+	if user, _ := s.FindByLogin("GamerVII"); w.Code != http.StatusOK || user.DiscordID != "80351110224678912" {
+		t.Fatalf("expected discord id set, got %d %+v", w.Code, user)
+	}
+}
+
+func TestLinkIdentityGuessesLimited(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, _ := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "launcher")
+	hashed, _ := password.Hash("pass123")
+	s.UpdateUser("GamerVII", func(u *models.User) error {
+		u.Password, u.TOTPSecret = hashed, "JBSWY3DPEHPK3PXP"
+		return nil
+	})
+	resets := recovery.New(recovery.Config{TTL: 15 * time.Minute, Resend: time.Minute, MaxAttempts: 3},
+		storage.NewDocument[recovery.State](filepath.Join(t.TempDir(), "password_resets.json")))
+	lim := limiter.New(limiter.Config{Window: time.Minute, MaxLoginFailures: 2, Lockout: time.Minute},
+		storage.NewDocument[limiter.State](filepath.Join(t.TempDir(), "lockouts.json")))
+	tg := &fakeSender{sent: map[string]string{}}
+	account := NewAccountHandler(s, WithSessions(sessions), WithLimiter(lim),
+		WithRecovery(resets, map[string]notify.Sender{notify.ChannelTelegram: tg}))
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }
+	link := func(body string) *httptest.ResponseRecorder {
+		return accountRequest(account, http.MethodPut, "/api/v1/account/links/telegram", body, bearer)
+	}
+
+	for range 2 {
+		if w := link(`{"id":"12345","current_password":"pass123","totp":"bad"}`); w.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for wrong 2FA code, got %d", w.Code)
+		}
+	}
+	if w := link(`{"id":"12345","current_password":"pass123","totp":"bad"}`); w.Code != http.StatusTooManyRequests || len(tg.sent) != 0 {
+		t.Errorf("expected 429 after failed 2FA guesses, got %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/invites"
+	"gml-auth/ipfilter"
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Register — POST /api/v1/register: регистрация игрока по коду приглашения.
+// Неверные коды учитываются защитой от перебора по IP, удачные регистрации —
+// лимитом регистраций с одного адреса.
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.invites == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Регистрация выключена"})
+		return
+	}
+	ip := ipfilter.ClientIP(r)
+	now := time.Now().UTC()
+	registered := false
+	if ban, ok := h.ipBanned(ip); ok {
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: signin.IPBlockedMessage(ban, now)})
+		return
+	}
+	if h.throttled(w, "", ip) {
+		return
+	}
+	if h.registrations != nil {
+		// место в лимите занимается до создания аккаунта и освобождается при любой неудаче
+		wait, release := h.registrations.Reserve(ip)
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Message: "Слишком много регистраций с вашего адреса. Попробуйте позже"})
+			return
+		}
+		defer func() {
+			if !registered {
+				release()
+			}
+		}()
+	}
+
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Login == "" || req.Password == "" || req.Invite == "" {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Нужны login, password и invite"})
+		return
+	}
+	if err := storage.ValidateLogin(req.Login); err != nil {
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Логин должен быть от 3 до 16 символов: латинские буквы, цифры и _"})
+		return
+	}
+	if h.passwordRejected(w, "password", req.Login, req.Password) {
+		return
+	}
+	// занятый логин проверяется до списания кода, чтобы не тратить приглашение
+	if _, err := h.store.FindByLogin(req.Login); err == nil {
+		writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Логин уже занят"})
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+		return
+	}
+
+	inv, err := h.invites.Redeem(req.Invite, req.Login)
+	if err != nil {
+		msg := "Неверный код приглашения"
+		switch {
+		case errors.Is(err, invites.ErrExpired):
+			msg = "Срок действия приглашения истёк"
+		case errors.Is(err, invites.ErrUsedUp):
+			msg = "Приглашение уже использовано"
+		case !errors.Is(err, invites.ErrInvalidCode):
+			log.Printf("[register] redeem: %v", err)
+			writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сервера"})
+			return
+		}
+		h.fail("", ip)
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Message: msg})
+		return
+	}
+
+	user, err := h.createAccount(req, inv.ID, now)
+	if err != nil {
+		if err := h.invites.Release(inv.ID, req.Login); err != nil {
+			log.Printf("[register] release invite %s: %v", inv.ID, err)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			writeJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Логин уже занят"})
+			return
+		}
+		log.Printf("[register] %s: %v", req.Login, err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка создания"})
+		return
+	}
+	registered = true
+	log.Printf("[register] %s зарегистрирован по приглашению %s (%s)", user.Login, inv.ID, ip)
+	writeJSON(w, http.StatusCreated, user.Info())
+}
+
+func (h *AuthHandler) createAccount(req models.RegisterRequest, inviteID string, now time.Time) (models.User, error) {
+	id, err := storage.NewUUID(h.uuidStrategy, req.Login, "")
+	if err != nil {
+		return models.User{}, err
+	}
+	hashed, err := password.Hash(req.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		UUID:      id,
+		Login:     req.Login,
+		Password:  hashed,
+		IsSlim:    req.IsSlim,
+		CreatedAt: now,
+		InviteID:  inviteID,
+	}
+	return user, h.store.AddUser(user)
+}
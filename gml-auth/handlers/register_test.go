@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gml-auth/invites"
+	"gml-auth/limiter"
+	"gml-auth/password"
+	"gml-auth/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func register(h *AuthHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/register", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.Register(w, req)
+	return w
+}
+
+func TestRegisterWithInvite(t *testing.T) {
+	s := setupStorage(t)
+	inv := invites.New(storage.NewDocument[invites.State](filepath.Join(t.TempDir(), "invites.json")))
+	policy := WithPasswordPolicy(password.Policy{MinLength: 8, DisallowLogin: true})
+	admin := NewAdminHandler(s, WithRegistration(inv, nil), policy)
+	h := NewAuthHandler(s, WithRegistration(inv, limiter.NewRate(2, time.Hour)), policy)
+
+	w := register(NewAuthHandler(s), `{"login":"Steve","password":"longpass1","invite":"x"}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 with registration disabled, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/invites", bytes.NewBufferString(`{"max_uses":2,"duration":"7d"}`)))
+	var created struct {
+		Code string `json:"code"`
+		invites.Invite
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusCreated || created.Code == "" || created.ExpiresAt.IsZero() || created.CreatedBy != "admin" {
+		t.Fatalf("unexpected invite: %d %+v", w.Code, created)
+	}
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"login":"Steve","password":"longpass1"}`, http.StatusBadRequest},
+		{`{"login":"St","password":"longpass1","invite":"` + created.Code + `"}`, http.StatusBadRequest},
+		{`{"login":"Steve","password":"short","invite":"` + created.Code + `"}`, http.StatusBadRequest},
+		{`{"login":"Steve12345","password":"steve12345","invite":"` + created.Code + `"}`, http.StatusBadRequest},
+		{`{"login":"gamervii","password":"longpass1","invite":"` + created.Code + `"}`, http.StatusConflict},
+		{`{"login":"Steve","password":"longpass1","invite":"AAAA-BBBB-CCCC-DDDD"}`, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if w := register(h, c.body); w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d: %s", c.body, c.code, w.Code, w.Body.String())
+		}
+	}
+
+	w = register(h, `{"login":"Steve","password":"longpass1","invite":"`+created.Code+`","is_slim":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	user, err := s.FindByLogin("Steve")
+	if err != nil || !user.IsSlim || user.InviteID != created.ID {
+		t.Fatalf("unexpected user: %+v %v", user, err)
+	}
+	if ok, _ := password.Verify(user.Password, "longpass1"); !ok || user.Password == "longpass1" {
+		t.Error("password must be stored hashed")
+	}
+
+	// второе использование — последнее, дальше код исчерпан
+	if w := register(h, `{"login":"Alex","password":"longpass1","invite":"`+created.Code+`"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/invites", nil))
+	var list []invites.Invite
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Uses != 2 || list[0].Hash != "" {
+		t.Fatalf("unexpected invite list: %+v", list)
+	}
+
+	// лимит регистраций с одного адреса
+	another, _, _ := inv.Create(1, "", "admin", time.Time{})
+	w = register(h, `{"login":"Herobrine","password":"longpass1","invite":"`+another+`"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/invites/"+created.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+}
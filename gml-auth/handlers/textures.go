@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"gml-auth/textures"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Виды текстур в путях /skin и /cape
+const (
+	kindSkin = "skin"
+	kindCape = "cape"
+)
+
+// readTexture читает PNG из тела запроса: либо сырой image/png,
+// либо multipart/form-data с полем file (форма в браузере)
+func readTexture(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, textures.MaxSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var src io.Reader = r.Body
+	if mediaType == "multipart/form-data" {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, textures.ErrTooLarge
+			}
+			return nil, textures.ErrNotPNG
+		}
+		defer f.Close()
+		src = f
+	}
+	data, err := io.ReadAll(src)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return nil, textures.ErrTooLarge
+	}
+	return data, err
+}
+
+// putTexture — загрузка скина или плаща пользователю login.
+// Для скина модель (IsSlim) определяется по самой картинке.
+func (d *deps) putTexture(w http.ResponseWriter, r *http.Request, store storage.UserStore, login, kind string) {
+	if d.textures == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Текстуры выключены"})
+		return
+	}
+	var slim bool
+	data, err := readTexture(w, r)
+	if err == nil {
+		if kind == kindSkin {
+			slim, err = textures.ParseSkin(data)
+		} else {
+			err = textures.ParseCape(data)
+		}
+	}
+	switch {
+	case errors.Is(err, textures.ErrTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, models.ErrorResponse{Message: "Файл слишком большой"})
+		return
+	case errors.Is(err, textures.ErrNotPNG), errors.Is(err, textures.ErrBadDimensions):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Не удалось прочитать файл"})
+		return
+	}
+
+	var old string
+	hash, err := d.textures.Put(data, func(hash string) error {
+		return store.UpdateUser(login, func(u *models.User) error {
+			if kind == kindSkin {
+				old, u.SkinHash, u.IsSlim = u.SkinHash, hash, slim
+			} else {
+				old, u.CapeHash = u.CapeHash, hash
+			}
+			slim = u.IsSlim
+			return nil
+		})
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("[textures] save: %v", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	if old != hash {
+		d.releaseTexture(store, old)
+	}
+	writeJSON(w, http.StatusOK, models.TextureResponse{Hash: hash, URL: d.textureURL(hash), IsSlim: slim})
+}
+
+// deleteTexture — удаление скина или плаща пользователя login
+func (d *deps) deleteTexture(w http.ResponseWriter, store storage.UserStore, login, kind string) {
+	if d.textures == nil {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Текстуры выключены"})
+		return
+	}
+	var old string
+	err := store.UpdateUser(login, func(u *models.User) error {
+		if kind == kindSkin {
+			old, u.SkinHash, u.IsSlim = u.SkinHash, "", false
+		} else {
+			old, u.CapeHash = u.CapeHash, ""
+		}
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Пользователь не найден"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Ошибка сохранения"})
+		return
+	}
+	d.releaseTexture(store, old)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// releaseTexture удаляет файл, если на него больше не ссылается ни один пользователь
+// (одинаковые картинки у разных игроков хранятся одним файлом)
+func (d *deps) releaseTexture(store storage.UserStore, hash string) {
+	if hash == "" {
+		return
+	}
+	err := d.textures.Release(hash, func(hash string) (bool, error) {
+		users, err := store.ListUsers()
+		if err != nil {
+			return false, err
+		}
+		for _, u := range users {
+			if u.SkinHash == hash || u.CapeHash == hash {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		log.Printf("[textures] release %s: %v", hash, err)
+	}
+}
+
+func (d *deps) textureURL(hash string) string {
+	return d.baseURL + "/textures/" + hash
+}
+
+// TexturesHandler отдаёт файлы текстур:
+//
+//	GET /textures/{hash}       — неизменяемый файл, кэшируется навсегда
+//	GET /skins/{login}.png     — текущий скин игрока
+//	GET /capes/{login}.png     — текущий плащ игрока
+type TexturesHandler struct {
+	store    storage.UserStore
+	textures *textures.Store
+}
+
+func NewTexturesHandler(store storage.UserStore, tex *textures.Store) *TexturesHandler {
+	return &TexturesHandler{store: store, textures: tex}
+}
+
+func (h *TexturesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/textures/"):
+		h.serve(w, r, strings.TrimPrefix(path, "/textures/"), "public, max-age=31536000, immutable")
+	case strings.HasPrefix(path, "/skins/"), strings.HasPrefix(path, "/capes/"):
+		kind, name, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+		login, ok := strings.CutSuffix(name, ".png")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		user, err := h.store.FindByLogin(login)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		hash := user.SkinHash
+		if kind == "capes" {
+			hash = user.CapeHash
+		}
+		// игрок может сменить скин, поэтому кэш короткий; ETag избавляет от повторной загрузки
+		h.serve(w, r, hash, "public, max-age=60")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *TexturesHandler) serve(w http.ResponseWriter, r *http.Request, hash, cacheControl string) {
+	f, err := h.textures.Open(hash)
+	if errors.Is(err, textures.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
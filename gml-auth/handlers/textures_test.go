@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gml-auth/models"
+	"gml-auth/textures"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// skinPNG — скин 64x64; slim очищает столбцы 54–55 правой руки
+func skinPNG(t *testing.T, slim bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if slim && x >= 54 && x < 56 && y >= 20 && y < 32 {
+				continue
+			}
+			img.Set(x, y, color.NRGBA{G: 120, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestAdminUploadSkinAndServe(t *testing.T) {
+	s := setupStorage(t)
+	dir := t.TempDir()
+	tex := textures.New(dir)
+	h := NewAdminHandler(s, WithTextures(tex, "http://mc.local:5003/"))
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/users/GamerVII/skin", bytes.NewReader(skinPNG(t, true)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.TextureResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !resp.IsSlim || resp.URL != "http://mc.local:5003/textures/"+resp.Hash {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	user, _ := s.FindByLogin("GamerVII")
+	if user.SkinHash != resp.Hash || !user.IsSlim {
+		t.Fatalf("user not updated: %+v", user)
+	}
+
+	serve := NewTexturesHandler(s, tex)
+	w = httptest.NewRecorder()
+	serve.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/textures/"+resp.Hash, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" ||
+		w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatalf("unexpected texture response: %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/skins/GamerVII.png", nil)
+	req.Header.Set("If-None-Match", `"`+resp.Hash+`"`)
+	w = httptest.NewRecorder()
+	serve.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	// классический скин сбрасывает IsSlim, старый файл удаляется
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/users/GamerVII/skin", bytes.NewReader(skinPNG(t, false))))
+	user, _ = s.FindByLogin("GamerVII")
+	if w.Code != http.StatusOK || user.IsSlim {
+		t.Fatalf("expected classic skin, got %d %+v", w.Code, user)
+	}
+	if _, err := os.Stat(filepath.Join(dir, resp.Hash+".png")); !os.IsNotExist(err) {
+		t.Error("unused texture file must be removed")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/users/GamerVII/skin", nil))
+	user, _ = s.FindByLogin("GamerVII")
+	if w.Code != http.StatusNoContent || user.SkinHash != "" {
+		t.Errorf("expected skin removed, got %d %+v", w.Code, user)
+	}
+}
+
+func TestAdminUploadInvalidCape(t *testing.T) {
+	s := setupStorage(t)
+	h := NewAdminHandler(s, WithTextures(textures.New(t.TempDir()), ""))
+	// скин 64x64 не подходит как плащ
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/users/GamerVII/cape", bytes.NewReader(skinPNG(t, false))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestAccountUploadSkinMultipart(t *testing.T) {
+	s := setupStorage(t)
+	sessions := newTestSessions(t)
+	tokens, err := sessions.Issue("uuid-1", "GamerVII", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewAccountHandler(s, WithSessions(sessions), WithTextures(textures.New(t.TempDir()), ""))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "skin.png")
+	part.Write(skinPNG(t, false))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/account/skin", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	part, _ = mw.CreateFormFile("file", "skin.png")
+	part.Write(skinPNG(t, false))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/account/skin", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if user, _ := s.FindByLogin("GamerVII"); user.SkinHash == "" {
+		t.Error("expected skin hash to be stored")
+	}
+}
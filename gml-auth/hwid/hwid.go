@@ -0,0 +1,166 @@
+// Package hwid — идентификаторы устройств игроков (HWID от лаунчера) и их блокировка.
+package hwid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gml-auth/storage"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxLength — длиннее HWID не бывает; всё, что длиннее, — мусор от клиента
+const MaxLength = 128
+
+var (
+	ErrNotFound = errors.New("hwid ban not found")
+	ErrInvalid  = errors.New("invalid hwid")
+)
+
+// Normalize приводит HWID к виду для сравнения: без пробелов по краям, в нижнем регистре
+func Normalize(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || len(s) > MaxLength {
+		return "", ErrInvalid
+	}
+	for _, c := range s {
+		if c < 0x21 || c > 0x7e {
+			return "", ErrInvalid
+		}
+	}
+	return s, nil
+}
+
+// Ban — заблокированное устройство
+type Ban struct {
+	ID        string    `json:"id"`
+	HWID      string    `json:"hwid"`
+	Reason    string    `json:"reason"`
+	By        string    `json:"by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // пусто — бессрочно
+}
+
+func (b Ban) expired(now time.Time) bool {
+	return !b.ExpiresAt.IsZero() && !now.Before(b.ExpiresAt)
+}
+
+// State — содержимое data/hwid_bans.json
+type State struct {
+	Bans []*Ban `json:"bans"`
+}
+
+type Bans struct {
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func NewBans(doc *storage.Document[State]) *Bans {
+	return &Bans{doc: doc, now: time.Now}
+}
+
+// Add блокирует устройство. Повторная блокировка заменяет причину и срок.
+func (s *Bans) Add(id, reason, by string, expires time.Time) (Ban, error) {
+	id, err := Normalize(id)
+	if err != nil {
+		return Ban{}, err
+	}
+	banID, err := randomID()
+	if err != nil {
+		return Ban{}, err
+	}
+	ban := Ban{
+		ID:        banID,
+		HWID:      id,
+		Reason:    reason,
+		By:        by,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expires,
+	}
+	err = s.doc.Update(func(st *State) error {
+		s.prune(st)
+		for i, b := range st.Bans {
+			if b.HWID == ban.HWID {
+				ban.ID = b.ID
+				st.Bans[i] = &ban
+				return nil
+			}
+		}
+		st.Bans = append(st.Bans, &ban)
+		return nil
+	})
+	return ban, err
+}
+
+// List — действующие блокировки, старые первыми
+func (s *Bans) List() ([]Ban, error) {
+	now := s.now()
+	var out []Ban
+	err := s.doc.Read(func(st *State) {
+		for _, b := range st.Bans {
+			if !b.expired(now) {
+				out = append(out, *b)
+			}
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, err
+}
+
+// Remove снимает блокировку по ID
+func (s *Bans) Remove(id string) error {
+	return s.doc.Update(func(st *State) error {
+		for i, b := range st.Bans {
+			if b.ID == id {
+				st.Bans = append(st.Bans[:i], st.Bans[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Match ищет действующую блокировку любого из устройств ids
+func (s *Bans) Match(ids ...string) (Ban, bool) {
+	now := s.now()
+	var (
+		found Ban
+		ok    bool
+	)
+	s.doc.Read(func(st *State) {
+		for _, b := range st.Bans {
+			if b.expired(now) {
+				continue
+			}
+			for _, id := range ids {
+				if b.HWID == id {
+					found, ok = *b, true
+					return
+				}
+			}
+		}
+	})
+	return found, ok
+}
+
+// prune убирает истёкшие блокировки при очередной записи
+func (s *Bans) prune(st *State) {
+	now := s.now()
+	kept := st.Bans[:0]
+	for _, b := range st.Bans {
+		if !b.expired(now) {
+			kept = append(kept, b)
+		}
+	}
+	st.Bans = kept
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,50 @@
+package hwid
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	if got, err := Normalize("  ABC-123  "); err != nil || got != "abc-123" {
+		t.Errorf("expected abc-123, got %q %v", got, err)
+	}
+	for _, bad := range []string{"", "   ", "with space", "таб", strings.Repeat("a", MaxLength+1)} {
+		if _, err := Normalize(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: expected ErrInvalid, got %v", bad, err)
+		}
+	}
+}
+
+func TestBans(t *testing.T) {
+	s := NewBans(storage.NewDocument[State](filepath.Join(t.TempDir(), "hwid_bans.json")))
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	ban, err := s.Add("PC-1", "твинки", "admin", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := s.Match("other", "pc-1"); !ok || b.ID != ban.ID {
+		t.Errorf("expected match by normalized hwid, got %+v %v", b, ok)
+	}
+	// повторная блокировка сохраняет ID и меняет срок
+	again, _ := s.Add("pc-1", "навсегда", "admin", time.Time{})
+	if again.ID != ban.ID {
+		t.Errorf("expected the same ban to be updated, got %s", again.ID)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok := s.Match("pc-1"); !ok {
+		t.Error("permanent ban must still match")
+	}
+	if err := s.Remove(ban.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); len(list) != 0 {
+		t.Errorf("expected no bans, got %+v", list)
+	}
+}
@@ -0,0 +1,178 @@
+package invites
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"gml-auth/storage"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("invite not found")
+	ErrInvalidCode = errors.New("invalid invite code")
+	ErrExpired     = errors.New("invite expired")
+	ErrUsedUp      = errors.New("invite used up")
+)
+
+// codeEncoding — коды из A–Z и 2–7; регистр и дефисы при проверке не учитываются
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Invite — код приглашения. Сам код хранится только в виде SHA-256.
+type Invite struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash,omitempty"`
+	MaxUses   int       `json:"max_uses"`
+	Uses      int       `json:"uses"`
+	UsedBy    []string  `json:"used_by,omitempty"` // логины зарегистрированных по коду
+	Note      string    `json:"note,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // пусто — бессрочно
+}
+
+func (i Invite) expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// Remaining — сколько ещё регистраций доступно по коду
+func (i Invite) Remaining() int {
+	return max(i.MaxUses-i.Uses, 0)
+}
+
+// State — содержимое data/invites.json
+type State struct {
+	Invites []*Invite `json:"invites"`
+}
+
+type Store struct {
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func New(doc *storage.Document[State]) *Store {
+	return &Store{doc: doc, now: time.Now}
+}
+
+// Create выпускает код на maxUses регистраций (минимум одна). expires = 0 — бессрочно.
+// Код вида XXXX-XXXX-XXXX-XXXX возвращается один раз и больше нигде не хранится.
+func (s *Store) Create(maxUses int, note, by string, expires time.Time) (string, Invite, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", Invite{}, err
+	}
+	secret := make([]byte, 10)
+	if _, err := rand.Read(secret); err != nil {
+		return "", Invite{}, err
+	}
+	raw := codeEncoding.EncodeToString(secret)
+	code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+	inv := Invite{
+		ID:        id,
+		Hash:      hashCode(code),
+		MaxUses:   max(maxUses, 1),
+		Note:      note,
+		CreatedBy: by,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expires,
+	}
+	err = s.doc.Update(func(st *State) error {
+		c := inv
+		st.Invites = append(st.Invites, &c)
+		return nil
+	})
+	inv.Hash = ""
+	return code, inv, err
+}
+
+// List возвращает коды без хэшей, старые первыми (вместе с истёкшими и исчерпанными)
+func (s *Store) List() ([]Invite, error) {
+	out := []Invite{}
+	err := s.doc.Read(func(st *State) {
+		for _, inv := range st.Invites {
+			c := *inv
+			c.Hash = ""
+			c.UsedBy = slices.Clone(inv.UsedBy)
+			out = append(out, c)
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, err
+}
+
+// Revoke удаляет код; уже созданные по нему аккаунты остаются
+func (s *Store) Revoke(id string) error {
+	return s.doc.Update(func(st *State) error {
+		for i, inv := range st.Invites {
+			if inv.ID == id {
+				st.Invites = append(st.Invites[:i], st.Invites[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Redeem списывает одно использование кода на регистрацию login
+func (s *Store) Redeem(code, login string) (Invite, error) {
+	hash := hashCode(code)
+	now := s.now()
+	var out Invite
+	err := s.doc.Update(func(st *State) error {
+		for _, inv := range st.Invites {
+			if inv.Hash != hash {
+				continue
+			}
+			switch {
+			case inv.expired(now):
+				return ErrExpired
+			case inv.Remaining() == 0:
+				return ErrUsedUp
+			}
+			inv.Uses++
+			inv.UsedBy = append(inv.UsedBy, login)
+			out = *inv
+			out.Hash = ""
+			return nil
+		}
+		return ErrInvalidCode
+	})
+	return out, err
+}
+
+// Release возвращает использование, если аккаунт по коду так и не был создан
+func (s *Store) Release(id, login string) error {
+	return s.doc.Update(func(st *State) error {
+		for _, inv := range st.Invites {
+			if inv.ID != id {
+				continue
+			}
+			if i := slices.Index(inv.UsedBy, login); i >= 0 {
+				inv.UsedBy = slices.Delete(inv.UsedBy, i, i+1)
+				inv.Uses--
+			}
+			return nil
+		}
+		return ErrNotFound
+	})
+}
+
+// hashCode — SHA-256 кода без учёта регистра, пробелов и дефисов
+func hashCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,73 @@
+package invites
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, *time.Time) {
+	s := New(storage.NewDocument[State](filepath.Join(t.TempDir(), "invites.json")))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestRedeemUses(t *testing.T) {
+	s, _ := newTestStore(t)
+	code, inv, err := s.Create(2, "друзья", "admin", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Hash != "" || inv.MaxUses != 2 || inv.CreatedBy != "admin" {
+		t.Fatalf("unexpected invite: %+v", inv)
+	}
+	// регистр и дефисы не важны
+	if _, err := s.Redeem(strings.ToLower(strings.ReplaceAll(code, "-", "")), "Steve"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := s.Redeem(code, "Alex"); err != nil {
+		t.Fatalf("second use: %v", err)
+	}
+	if _, err := s.Redeem(code, "Herobrine"); !errors.Is(err, ErrUsedUp) {
+		t.Fatalf("expected ErrUsedUp, got %v", err)
+	}
+	if err := s.Release(inv.ID, "Alex"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Redeem(code, "Herobrine")
+	if err != nil {
+		t.Fatalf("released use must be available: %v", err)
+	}
+	if got.Uses != 2 || strings.Join(got.UsedBy, ",") != "Steve,Herobrine" {
+		t.Errorf("unexpected usage: %+v", got)
+	}
+	if _, err := s.Redeem("AAAA-BBBB-CCCC-DDDD", "Steve"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestExpiryAndRevoke(t *testing.T) {
+	s, now := newTestStore(t)
+	code, inv, _ := s.Create(0, "", "admin", now.Add(time.Hour))
+	if inv.MaxUses != 1 {
+		t.Errorf("expected single-use invite by default, got %d", inv.MaxUses)
+	}
+	*now = now.Add(time.Hour)
+	if _, err := s.Redeem(code, "Steve"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	list, _ := s.List()
+	if len(list) != 1 || list[0].Hash != "" {
+		t.Fatalf("expected expired invite in list without hash, got %+v", list)
+	}
+	if err := s.Revoke(inv.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(inv.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package ipfilter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"gml-auth/storage"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+var ErrNotFound = errors.New("ip ban not found")
+
+// Ban — заблокированный адрес или подсеть
+type Ban struct {
+	ID        string    `json:"id"`
+	CIDR      string    `json:"cidr"` // 203.0.113.0/24, 2001:db8::/32; адрес — /32 или /128
+	Reason    string    `json:"reason"`
+	By        string    `json:"by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // пусто — бессрочно
+}
+
+func (b Ban) expired(now time.Time) bool {
+	return !b.ExpiresAt.IsZero() && !now.Before(b.ExpiresAt)
+}
+
+// State — содержимое data/ip_bans.json
+type State struct {
+	Bans []*Ban `json:"bans"`
+}
+
+type Bans struct {
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func NewBans(doc *storage.Document[State]) *Bans {
+	return &Bans{doc: doc, now: time.Now}
+}
+
+// Add блокирует адрес или подсеть. Повторная блокировка той же подсети
+// заменяет причину и срок. expires = 0 — бессрочно.
+func (s *Bans) Add(target, reason, by string, expires time.Time) (Ban, error) {
+	prefix, err := ParsePrefix(target)
+	if err != nil {
+		return Ban{}, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return Ban{}, err
+	}
+	ban := Ban{
+		ID:        id,
+		CIDR:      prefix.String(),
+		Reason:    reason,
+		By:        by,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expires,
+	}
+	err = s.doc.Update(func(st *State) error {
+		s.prune(st)
+		for i, b := range st.Bans {
+			if b.CIDR == ban.CIDR {
+				ban.ID = b.ID
+				st.Bans[i] = &ban
+				return nil
+			}
+		}
+		st.Bans = append(st.Bans, &ban)
+		return nil
+	})
+	return ban, err
+}
+
+// List — действующие блокировки, старые первыми
+func (s *Bans) List() ([]Ban, error) {
+	now := s.now()
+	var out []Ban
+	err := s.doc.Read(func(st *State) {
+		for _, b := range st.Bans {
+			if !b.expired(now) {
+				out = append(out, *b)
+			}
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, err
+}
+
+// Remove снимает блокировку по ID
+func (s *Bans) Remove(id string) error {
+	return s.doc.Update(func(st *State) error {
+		for i, b := range st.Bans {
+			if b.ID == id {
+				st.Bans = append(st.Bans[:i], st.Bans[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Match ищет действующую блокировку, под которую попадает ip.
+// Из нескольких подходящих выбирается самая узкая подсеть.
+func (s *Bans) Match(ip string) (Ban, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Ban{}, false
+	}
+	addr = addr.Unmap()
+	now := s.now()
+	var (
+		found Ban
+		bits  = -1
+	)
+	s.doc.Read(func(st *State) {
+		for _, b := range st.Bans {
+			prefix, err := netip.ParsePrefix(b.CIDR)
+			if err != nil || b.expired(now) || !prefix.Contains(addr) {
+				continue
+			}
+			if prefix.Bits() > bits {
+				found, bits = *b, prefix.Bits()
+			}
+		}
+	})
+	return found, bits >= 0
+}
+
+// prune убирает истёкшие блокировки при очередной записи
+func (s *Bans) prune(st *State) {
+	now := s.now()
+	kept := st.Bans[:0]
+	for _, b := range st.Bans {
+		if !b.expired(now) {
+			kept = append(kept, b)
+		}
+	}
+	st.Bans = kept
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,58 @@
+package ipfilter
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	s := NewBans(storage.NewDocument[State](filepath.Join(t.TempDir(), "ip_bans.json")))
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	wide, err := s.Add("203.0.113.0/24", "спам", "admin", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("203.0.113.7", "перебор", "admin", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("2001:db8::/32", "ботнет", "admin", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, ok := s.Match("203.0.113.7"); !ok || b.Reason != "перебор" {
+		t.Errorf("expected the narrowest ban, got %+v %v", b, ok)
+	}
+	if b, ok := s.Match("::ffff:203.0.113.8"); !ok || b.ID != wide.ID {
+		t.Errorf("expected IPv4-mapped address to match /24, got %+v %v", b, ok)
+	}
+	if _, ok := s.Match("2001:db8:1::1"); !ok {
+		t.Error("expected IPv6 subnet to match")
+	}
+	if _, ok := s.Match("198.51.100.1"); ok {
+		t.Error("unexpected match")
+	}
+
+	// истёкшая блокировка больше не действует
+	now = now.Add(2 * time.Hour)
+	if b, ok := s.Match("203.0.113.7"); !ok || b.ID != wide.ID {
+		t.Errorf("expected expired ban to be skipped, got %+v", b)
+	}
+	if list, _ := s.List(); len(list) != 2 {
+		t.Errorf("expected 2 active bans, got %+v", list)
+	}
+
+	if err := s.Remove(wide.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(wide.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, ok := s.Match("203.0.113.7"); ok {
+		t.Error("expected no ban after removal")
+	}
+}
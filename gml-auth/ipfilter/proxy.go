@@ -0,0 +1,127 @@
+// Package ipfilter — адрес клиента за обратным прокси и блокировка IP адресов и подсетей.
+package ipfilter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Proxies — доверенные обратные прокси (GML backend, nginx). Только от них
+// принимаются заголовки X-Forwarded-For и X-Real-IP: любой другой клиент может их подделать.
+type Proxies struct {
+	trusted []netip.Prefix
+}
+
+// ParseProxies разбирает список адресов и подсетей (10.0.0.1, 172.16.0.0/12, ::1)
+func ParseProxies(list []string) (*Proxies, error) {
+	p := &Proxies{}
+	for _, s := range list {
+		prefix, err := ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies: %w", err)
+		}
+		p.trusted = append(p.trusted, prefix)
+	}
+	return p, nil
+}
+
+func (p *Proxies) trusts(addr netip.Addr) bool {
+	if p == nil {
+		return false
+	}
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve — адрес клиента. Если запрос пришёл от доверенного прокси, X-Forwarded-For
+// разбирается справа налево до первого недоверенного адреса; без него берётся X-Real-IP.
+func (p *Proxies) Resolve(r *http.Request) string {
+	remote := remoteIP(r)
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !p.trusts(addr.Unmap()) {
+		return remote
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// мусор в заголовке — дальше цепочке верить нельзя
+				break
+			}
+			client = hop.Unmap().String()
+			if !p.trusts(hop.Unmap()) {
+				return client
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+	if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return ip.Unmap().String()
+	}
+	return remote
+}
+
+type clientIPKey struct{}
+
+// Middleware определяет адрес клиента один раз и сохраняет его в контексте запроса
+// для ClientIP. Без доверенных прокси это просто адрес соединения.
+func (p *Proxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, p.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientIP — адрес клиента без порта: определённый Middleware или адрес соединения
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ParsePrefix принимает адрес или подсеть IPv4/IPv6. Одиночный адрес становится /32 или /128,
+// IPv4 в виде ::ffff:a.b.c.d приводится к IPv4.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("неверный адрес %q", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("неверная подсеть %q", s)
+	}
+	if prefix.Addr().Is4In6() {
+		bits := prefix.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("неверная подсеть %q", s)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,60 @@
+package ipfilter
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	p, err := ParseProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, remote, xff, realIP, want string
+	}{
+		{"direct client ignores headers", "203.0.113.5:1234", "1.2.3.4", "5.6.7.8", "203.0.113.5"},
+		{"trusted proxy", "10.0.0.2:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"chain of proxies", "10.0.0.2:1234", "198.51.100.7, 203.0.113.9, 10.1.1.1", "", "203.0.113.9"},
+		{"x-real-ip", "[::1]:1234", "", "2001:db8::5", "2001:db8::5"},
+		{"garbage in header", "10.0.0.2:1234", "not-an-ip", "", "10.0.0.2"},
+		{"all hops trusted", "10.0.0.2:1234", "10.9.9.9", "", "10.9.9.9"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.xff != "" {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := p.Resolve(r); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+	if _, err := ParseProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected error for bad CIDR")
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.7":         "203.0.113.7/32",
+		"203.0.113.7/24":      "203.0.113.0/24",
+		"::ffff:203.0.113.7":  "203.0.113.7/32",
+		"2001:db8::1/32":      "2001:db8::/32",
+		"::ffff:10.0.0.0/104": "10.0.0.0/8",
+	}
+	for in, want := range cases {
+		p, err := ParsePrefix(in)
+		if err != nil || p.String() != want {
+			t.Errorf("%s: expected %s, got %s (%v)", in, want, p, err)
+		}
+	}
+	for _, in := range []string{"", "300.1.1.1", "1.2.3.4/40", "example.com"} {
+		if _, err := ParsePrefix(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package limiter
+
+import (
+	"gml-auth/storage"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config — параметры защиты от перебора паролей
+type Config struct {
+	Window           time.Duration // окно, в котором считаются неудачные попытки
+	MaxLoginFailures int           // порог блокировки по логину
+	MaxIPFailures    int           // порог блокировки по IP
+	Lockout          time.Duration // длительность временной блокировки
+	BaseDelay        time.Duration // задержка после второй ошибки, дальше удваивается
+	MaxDelay         time.Duration // потолок прогрессивной задержки
+}
+
+// Entry — счётчик неудач для одного ключа ("login:steve" или "ip:1.2.3.4")
+type Entry struct {
+	Failures    []time.Time `json:"failures"`
+	LockedUntil time.Time   `json:"locked_until,omitzero"`
+	NextAttempt time.Time   `json:"next_attempt,omitzero"`
+}
+
+// State — содержимое файла состояния
+type State struct {
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Status — состояние ключа для admin API
+type Status struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	Locked      bool      `json:"locked"`
+	LockedUntil time.Time `json:"locked_until,omitzero"`
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+}
+
+type Limiter struct {
+	cfg   Config
+	state *storage.Document[State]
+	now   func() time.Time
+}
+
+func New(cfg Config, state *storage.Document[State]) *Limiter {
+	return &Limiter{cfg: cfg, state: state, now: time.Now}
+}
+
+func LoginKey(login string) string { return "login:" + strings.ToLower(login) }
+func IPKey(ip string) string       { return "ip:" + ip }
+
+// unavailableWait — ожидание, если файл состояния не читается: защита от перебора
+// не должна молча выключаться, поэтому попытка отклоняется
+const unavailableWait = time.Minute
+
+// Check возвращает, сколько нужно подождать перед следующей попыткой.
+// Ноль — попытку можно выполнять. Пустые login/ip не проверяются.
+func (l *Limiter) Check(login, ip string) time.Duration {
+	now := l.now()
+	var wait time.Duration
+	err := l.state.Read(func(s *State) {
+		for _, key := range keys(login, ip) {
+			e := s.Entries[key]
+			if e == nil {
+				continue
+			}
+			for _, until := range []time.Time{e.LockedUntil, e.NextAttempt} {
+				if d := until.Sub(now); d > wait {
+					wait = d
+				}
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("[limiter] состояние не читается, попытка отклонена: %v", err)
+		return unavailableWait
+	}
+	return wait
+}
+
+// Fail регистрирует неудачную попытку. login может быть пустым,
+// если пользователь не найден — тогда учитывается только IP.
+func (l *Limiter) Fail(login, ip string) error {
+	now := l.now()
+	return l.state.Update(func(s *State) error {
+		if s.Entries == nil {
+			s.Entries = map[string]*Entry{}
+		}
+		l.prune(s, now)
+		if login != "" {
+			l.fail(s, LoginKey(login), l.cfg.MaxLoginFailures, now)
+		}
+		if ip != "" {
+			l.fail(s, IPKey(ip), l.cfg.MaxIPFailures, now)
+		}
+		return nil
+	})
+}
+
+func (l *Limiter) fail(s *State, key string, max int, now time.Time) {
+	e := s.Entries[key]
+	if e == nil {
+		e = &Entry{}
+		s.Entries[key] = e
+	}
+	e.Failures = append(e.Failures, now)
+	n := len(e.Failures)
+	if max > 0 && n >= max {
+		e.LockedUntil = now.Add(l.cfg.Lockout)
+		e.Failures = nil
+		e.NextAttempt = time.Time{}
+		return
+	}
+	e.NextAttempt = now.Add(l.delay(n))
+}
+
+// delay — прогрессивная задержка: первая ошибка бесплатна, дальше base, 2·base, 4·base…
+func (l *Limiter) delay(failures int) time.Duration {
+	if failures < 2 || l.cfg.BaseDelay <= 0 {
+		return 0
+	}
+	d := l.cfg.BaseDelay
+	for i := 2; i < failures && d < l.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if l.cfg.MaxDelay > 0 && d > l.cfg.MaxDelay {
+		d = l.cfg.MaxDelay
+	}
+	return d
+}
+
+// Success сбрасывает счётчик логина после успешного входа.
+// Счётчик IP не сбрасывается: с одного адреса могут перебирать разные аккаунты.
+func (l *Limiter) Success(login string) error {
+	key := LoginKey(login)
+	found := false
+	l.state.Read(func(s *State) { _, found = s.Entries[key] })
+	if !found {
+		return nil
+	}
+	return l.state.Update(func(s *State) error {
+		delete(s.Entries, key)
+		return nil
+	})
+}
+
+// Rename переносит счётчик неудач на новый логин после переименования аккаунта,
+// чтобы смена логина не снимала блокировку за перебор
+func (l *Limiter) Rename(oldLogin, newLogin string) error {
+	from, to := LoginKey(oldLogin), LoginKey(newLogin)
+	if from == to {
+		return nil
+	}
+	return l.state.Update(func(s *State) error {
+		if e, ok := s.Entries[from]; ok {
+			s.Entries[to] = e
+			delete(s.Entries, from)
+		}
+		return nil
+	})
+}
+
+// List возвращает активные записи, отсортированные по ключу
+func (l *Limiter) List() []Status {
+	now := l.now()
+	var out []Status
+	l.state.Read(func(s *State) {
+		for key, e := range s.Entries {
+			if l.expired(e, now) {
+				continue
+			}
+			out = append(out, Status{
+				Key:         key,
+				Failures:    len(e.Failures),
+				Locked:      e.LockedUntil.After(now),
+				LockedUntil: e.LockedUntil,
+				NextAttempt: e.NextAttempt,
+			})
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Clear снимает блокировку и обнуляет счётчик. Возвращает false, если ключа не было.
+func (l *Limiter) Clear(key string) (bool, error) {
+	found := false
+	err := l.state.Update(func(s *State) error {
+		_, found = s.Entries[key]
+		delete(s.Entries, key)
+		return nil
+	})
+	return found, err
+}
+
+// prune удаляет устаревшие попытки и истёкшие записи
+func (l *Limiter) prune(s *State, now time.Time) {
+	for key, e := range s.Entries {
+		kept := e.Failures[:0]
+		for _, f := range e.Failures {
+			if now.Sub(f) < l.cfg.Window {
+				kept = append(kept, f)
+			}
+		}
+		e.Failures = kept
+		if l.expired(e, now) {
+			delete(s.Entries, key)
+		}
+	}
+}
+
+func (l *Limiter) expired(e *Entry, now time.Time) bool {
+	if e.LockedUntil.After(now) || e.NextAttempt.After(now) {
+		return false
+	}
+	for _, f := range e.Failures {
+		if now.Sub(f) < l.cfg.Window {
+			return false
+		}
+	}
+	return true
+}
+
+func keys(login, ip string) []string {
+	var out []string
+	if login != "" {
+		out = append(out, LoginKey(login))
+	}
+	if ip != "" {
+		out = append(out, IPKey(ip))
+	}
+	return out
+}
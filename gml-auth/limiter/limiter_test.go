@@ -0,0 +1,172 @@
+package limiter
+
+import (
+	"gml-auth/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	Window:           10 * time.Minute,
+	MaxLoginFailures: 3,
+	MaxIPFailures:    5,
+	Lockout:          15 * time.Minute,
+	BaseDelay:        time.Second,
+	MaxDelay:         4 * time.Second,
+}
+
+func newTestLimiter(t *testing.T) (*Limiter, *time.Time, string) {
+	path := filepath.Join(t.TempDir(), "lockouts.json")
+	l := New(testConfig, storage.NewDocument[State](path))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, &now, path
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	l, now, _ := newTestLimiter(t)
+
+	l.Fail("Steve", "1.1.1.1")
+	if d := l.Check("Steve", "1.1.1.1"); d != 0 {
+		t.Errorf("first failure must not delay, got %v", d)
+	}
+	l.Fail("Steve", "1.1.1.1")
+	if d := l.Check("Steve", "1.1.1.1"); d != time.Second {
+		t.Errorf("expected 1s delay, got %v", d)
+	}
+	*now = now.Add(2 * time.Second)
+	if d := l.Check("steve", "2.2.2.2"); d != 0 {
+		t.Errorf("expected delay to pass, got %v", d)
+	}
+}
+
+func TestLockoutAndExpiry(t *testing.T) {
+	l, now, _ := newTestLimiter(t)
+	for range 3 {
+		l.Fail("Steve", "")
+	}
+	if d := l.Check("STEVE", ""); d != 15*time.Minute {
+		t.Fatalf("expected 15m lockout, got %v", d)
+	}
+	st := l.List()
+	if len(st) != 1 || !st[0].Locked || st[0].Key != "login:steve" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	*now = now.Add(16 * time.Minute)
+	if d := l.Check("Steve", ""); d != 0 {
+		t.Errorf("expected lockout to expire, got %v", d)
+	}
+	if st := l.List(); len(st) != 0 {
+		t.Errorf("expected no active entries, got %+v", st)
+	}
+}
+
+func TestIPLockoutAcrossLogins(t *testing.T) {
+	l, _, _ := newTestLimiter(t)
+	for _, login := range []string{"a", "b", "c", "d", ""} {
+		l.Fail(login, "1.1.1.1")
+	}
+	if d := l.Check("fresh", "1.1.1.1"); d != 15*time.Minute {
+		t.Errorf("expected IP lockout, got %v", d)
+	}
+}
+
+func TestSuccessAndClear(t *testing.T) {
+	l, _, _ := newTestLimiter(t)
+	l.Fail("Steve", "1.1.1.1")
+	l.Fail("Steve", "1.1.1.1")
+	l.Success("Steve")
+	if d := l.Check("Steve", ""); d != 0 {
+		t.Errorf("expected login counter reset, got %v", d)
+	}
+	if d := l.Check("", "1.1.1.1"); d == 0 {
+		t.Error("expected IP counter to survive success")
+	}
+	if ok, _ := l.Clear(IPKey("1.1.1.1")); !ok {
+		t.Error("expected key to be cleared")
+	}
+	if ok, _ := l.Clear(IPKey("1.1.1.1")); ok {
+		t.Error("expected second clear to report missing key")
+	}
+}
+
+func TestRenameKeepsLockout(t *testing.T) {
+	l, _, _ := newTestLimiter(t)
+	for range testConfig.MaxLoginFailures {
+		l.Fail("Steve", "")
+	}
+	if err := l.Rename("Steve", "Steve2"); err != nil {
+		t.Fatal(err)
+	}
+	if l.Check("Steve2", "") == 0 {
+		t.Error("expected lockout to follow the new login")
+	}
+	if l.Check("Steve", "") != 0 {
+		t.Error("old login must be free")
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	l, now, path := newTestLimiter(t)
+	for range 3 {
+		l.Fail("Steve", "")
+	}
+	restarted := New(testConfig, storage.NewDocument[State](path))
+	restarted.now = l.now
+	if d := restarted.Check("Steve", ""); d != 15*time.Minute {
+		t.Errorf("expected lockout after restart, got %v (now %v)", d, *now)
+	}
+}
+
+func TestRate(t *testing.T) {
+	r := NewRate(2, time.Hour)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	r.Add("1.1.1.1")
+	now = now.Add(10 * time.Minute)
+	r.Add("1.1.1.1")
+	if d := r.Wait("1.1.1.1"); d != 50*time.Minute {
+		t.Fatalf("expected 50m until the first event expires, got %v", d)
+	}
+	if d := r.Wait("2.2.2.2"); d != 0 {
+		t.Errorf("other key must not be limited, got %v", d)
+	}
+	now = now.Add(50 * time.Minute)
+	if d := r.Wait("1.1.1.1"); d != 0 {
+		t.Errorf("expected limit to pass, got %v", d)
+	}
+	if d := NewRate(0, time.Hour).Wait("1.1.1.1"); d != 0 {
+		t.Errorf("zero max must disable the limit, got %v", d)
+	}
+}
+
+func TestRateReserve(t *testing.T) {
+	r := NewRate(2, time.Hour)
+	if wait, _ := r.Reserve("1.1.1.1"); wait != 0 {
+		t.Fatalf("expected first reservation, got %v", wait)
+	}
+	wait, release := r.Reserve("1.1.1.1")
+	if wait != 0 {
+		t.Fatalf("expected second reservation, got %v", wait)
+	}
+	if wait, _ := r.Reserve("1.1.1.1"); wait <= 0 {
+		t.Fatal("third reservation must wait")
+	}
+	release()
+	if wait, _ := r.Reserve("1.1.1.1"); wait != 0 {
+		t.Errorf("released slot must be free again, got %v", wait)
+	}
+}
+
+func TestUnreadableStateRefuses(t *testing.T) {
+	l, _, path := newTestLimiter(t)
+	if err := os.WriteFile(path, []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d := l.Check("Steve", "1.1.1.1"); d <= 0 {
+		t.Errorf("broken lockout file must refuse attempts, got %v", d)
+	}
+}
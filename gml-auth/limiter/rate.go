@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Rate — не больше max событий на ключ за скользящее окно (например, регистраций с одного IP).
+// Счётчики живут в памяти: после перезапуска окно начинается заново.
+type Rate struct {
+	mu     sync.Mutex
+	max    int
+	window time.Duration
+	hits   map[string][]time.Time
+	now    func() time.Time
+}
+
+// NewRate — max <= 0 отключает ограничение
+func NewRate(max int, window time.Duration) *Rate {
+	return &Rate{max: max, window: window, hits: map[string][]time.Time{}, now: time.Now}
+}
+
+// Wait возвращает, сколько ждать до следующего разрешённого события. Ноль — можно.
+func (r *Rate) Wait(key string) time.Duration {
+	if r.max <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	hits := r.prune(key, now)
+	if len(hits) < r.max {
+		return 0
+	}
+	return hits[len(hits)-r.max].Add(r.window).Sub(now)
+}
+
+// Add учитывает событие для key
+func (r *Rate) Add(key string) {
+	if r.max <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.hits[key] = append(r.prune(key, now), now)
+}
+
+// Reserve проверяет лимит и сразу учитывает событие — одной операцией, чтобы параллельные
+// запросы с одного ключа не прошли проверку вместе. При превышении возвращает ожидание
+// и ничего не учитывает. release отменяет резерв, если событие не состоялось.
+func (r *Rate) Reserve(key string) (wait time.Duration, release func()) {
+	if r.max <= 0 {
+		return 0, func() {}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	hits := r.prune(key, now)
+	if len(hits) >= r.max {
+		return hits[len(hits)-r.max].Add(r.window).Sub(now), func() {}
+	}
+	r.hits[key] = append(hits, now)
+	return 0, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		hits := r.hits[key]
+		if i := slices.IndexFunc(hits, now.Equal); i >= 0 {
+			r.hits[key] = slices.Delete(hits, i, i+1)
+		}
+		r.prune(key, r.now())
+	}
+}
+
+// prune убирает события старше окна; пустые ключи удаляются
+func (r *Rate) prune(key string, now time.Time) []time.Time {
+	hits := r.hits[key]
+	i := 0
+	for i < len(hits) && !now.Before(hits[i].Add(r.window)) {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) == 0 {
+		delete(r.hits, key)
+		return nil
+	}
+	r.hits[key] = hits
+	return hits
+}
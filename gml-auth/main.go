@@ -1,14 +1,34 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"gml-auth/apikey"
+	"gml-auth/audit"
 	"gml-auth/config"
+	"gml-auth/groups"
 	"gml-auth/handlers"
+	"gml-auth/hwid"
+	"gml-auth/invites"
+	"gml-auth/ipfilter"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
 	"gml-auth/news"
+	"gml-auth/notify"
+	"gml-auth/password"
+	"gml-auth/recovery"
+	"gml-auth/session"
 	"gml-auth/storage"
+	"gml-auth/textures"
+	"gml-auth/yggdrasil"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -18,7 +38,16 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		lw := &responseWriter{ResponseWriter: w, code: http.StatusOK}
 		next.ServeHTTP(lw, r)
 		log.Printf("[%s] %s %s → %d (%s)",
-			r.RemoteAddr, r.Method, r.URL.Path, lw.code, time.Since(start))
+			ipfilter.ClientIP(r), r.Method, r.URL.Path, lw.code, time.Since(start))
+	})
+}
+
+// authlibInjectorMiddleware добавляет заголовок API Location Indication (ALI),
+// чтобы в authlib-injector можно было указать просто адрес сервера
+func authlibInjectorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Authlib-Injector-API-Location", "/yggdrasil/")
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -56,75 +85,310 @@ func localIPs() []string {
 	return ips
 }
 
-func buildCache(provider news.Provider, interval time.Duration) *news.Cache {
-	cache := news.NewCache(provider, interval)
-	cache.Start()
-	return cache
+// passwordPolicy собирает политику паролей; отсутствующий список распространённых
+// паролей не мешает запуску, проверка по нему просто не выполняется
+func passwordPolicy(c config.PasswordConfig) password.Policy {
+	p := password.Policy{
+		MinLength:     c.MinLength,
+		RequireLower:  c.RequireLower,
+		RequireUpper:  c.RequireUpper,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+		DisallowLogin: c.DisallowLogin,
+	}
+	if c.DenyListPath == "" {
+		return p
+	}
+	list, err := password.LoadDenyList(c.DenyListPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Printf("[password] %s не найден, проверка распространённых паролей выключена", c.DenyListPath)
+	case err != nil:
+		log.Printf("[password] %s: %v", c.DenyListPath, err)
+	default:
+		p.DenyList = list
+		log.Printf("[password] запрещённых паролей: %d", len(list))
+	}
+	return p
 }
 
-func registerNews(mux *http.ServeMux, path string, cache *news.Cache) {
-	if cache != nil {
-		mux.HandleFunc(path, handlers.NewNewsHandler(cache).List)
-	} else {
-		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte("[]\n"))
+func bruteForceConfig(c config.BruteForceConfig) limiter.Config {
+	return limiter.Config{
+		Window:           time.Duration(c.WindowSeconds) * time.Second,
+		MaxLoginFailures: c.MaxLoginFailures,
+		MaxIPFailures:    c.MaxIPFailures,
+		Lockout:          time.Duration(c.LockoutSeconds) * time.Second,
+		BaseDelay:        time.Duration(c.DelaySeconds) * time.Second,
+		MaxDelay:         time.Duration(c.MaxDelaySeconds) * time.Second,
+	}
+}
+
+// yggdrasilTextures — ссылки на скин и плащ для свойства textures профиля
+func yggdrasilTextures(baseURL string) yggdrasil.TextureSource {
+	return func(u models.User) map[string]yggdrasil.Texture {
+		out := map[string]yggdrasil.Texture{}
+		if u.SkinHash != "" {
+			skin := yggdrasil.Texture{URL: baseURL + "/textures/" + u.SkinHash}
+			if u.IsSlim {
+				skin.Metadata = map[string]string{"model": "slim"}
+			}
+			out["SKIN"] = skin
+		}
+		if u.CapeHash != "" {
+			out["CAPE"] = yggdrasil.Texture{URL: baseURL + "/textures/" + u.CapeHash}
+		}
+		return out
+	}
+}
+
+// openStore открывает хранилище пользователей из config.json (сервер и CLI)
+func openStore(cfg config.StorageConfig) (storage.UserStore, error) {
+	store, err := storage.Open(cfg.Backend, cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if js, ok := store.(*storage.Storage); ok && cfg.Backups.Enabled {
+		js.EnableBackups(storage.BackupConfig{
+			Dir:      cfg.Backups.Dir,
+			Interval: time.Duration(cfg.Backups.IntervalSeconds) * time.Second,
+			Keep:     cfg.Backups.Keep,
+			MaxAge:   time.Duration(cfg.Backups.MaxAgeDays) * 24 * time.Hour,
 		})
 	}
+	return store, nil
 }
 
+// auditLogins сообщает о пользователях с совпадающими логинами (без учёта регистра)
+// или UUID. Вход по такому логину попадает в первую запись, остальные нужно переименовать.
+func auditLogins(store storage.UserStore) {
+	collisions, err := storage.Audit(store)
+	if err != nil {
+		log.Printf("[storage] проверка логинов: %v", err)
+		return
+	}
+	for _, c := range collisions {
+		log.Printf("[storage] ВНИМАНИЕ: одинаковый %s %q у записей %s — используется первая, переименуйте остальные",
+			c.Kind, c.Key, strings.Join(c.Logins, ", "))
+	}
+}
+
+// sweepBans раз в минуту снимает временные блокировки с истёкшим сроком.
+// Вход проверяет срок сам, очистка нужна для списка пользователей и истории.
+func sweepBans(store storage.UserStore) {
+	for range time.Tick(time.Minute) {
+		lifted, err := storage.LiftExpiredBans(store, time.Now().UTC())
+		if err != nil {
+			log.Printf("[bans] снятие истёкших блокировок: %v", err)
+		}
+		for _, login := range lifted {
+			log.Printf("[bans] %s: срок блокировки истёк, блокировка снята", login)
+		}
+	}
+}
+
+// newsSources — включённые источники из config.json для news.Build
+func newsSources(c config.NewsConfig) []news.SourceConfig {
+	var out []news.SourceConfig
+	for _, s := range c.SourceList() {
+		if !s.IsEnabled() {
+			continue
+		}
+		out = append(out, news.SourceConfig{
+			Type:     s.Type,
+			Name:     s.Name,
+			Token:    s.Token,
+			Channel:  s.Channel,
+			Interval: time.Duration(s.RefreshSeconds) * time.Second,
+		})
+	}
+	return out
+}
+
+func newsFeeds(c config.NewsConfig) []news.FeedConfig {
+	out := make([]news.FeedConfig, 0, len(c.Feeds))
+	for _, f := range c.Feeds {
+		out = append(out, news.FeedConfig{Name: f.Name, Sources: f.Sources})
+	}
+	return out
+}
+
+// Файлы состояния рядом с users.json
+const (
+	apiKeysPath      = "data/api_keys.json"
+	yggdrasilKeyPath = "data/yggdrasil_key.pem"
+	texturesDir      = "data/textures"
+	ipBansPath       = "data/ip_bans.json"
+	hwidBansPath     = "data/hwid_bans.json"
+	groupsPath       = "data/groups.json"
+	maintenancePath  = "data/maintenance.json"
+	invitesPath      = "data/invites.json"
+	resetsPath       = "data/password_resets.json"
+	sessionsPath     = "data/sessions.json"
+	yggTokensPath    = "data/yggdrasil_tokens.json"
+)
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	const port = "5003"
 
-	store := storage.New("data/users.json")
-	authHandler := handlers.NewAuthHandler(store)
-	adminHandler := handlers.NewAdminHandler(store)
+	cfg, err := config.Load("config.json")
+	if err != nil {
+		log.Printf("[config] config.json не прочитан (%v), используются настройки по умолчанию", err)
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", authHandler.SignIn)
-	mux.HandleFunc("/api/v1/integrations/auth/signin", authHandler.SignIn)
-	mux.HandleFunc("/api/v1/users/refresh", authHandler.Refresh)
-	mux.Handle("/admin/users", adminHandler)
-	mux.Handle("/admin/users/", adminHandler)
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		log.Fatalf("[storage] %v", err)
+	}
+	log.Printf("[storage] пользователи: %s", cfg.Storage.Backend)
+	if !storage.ValidUUIDStrategy(cfg.Storage.UUIDStrategy) {
+		log.Fatalf("[config] storage.uuid_strategy: неизвестное значение %q (random, offline или imported)", cfg.Storage.UUIDStrategy)
+	}
+	auditLogins(store)
+	go sweepBans(store)
+	lim := limiter.New(bruteForceConfig(cfg.Security.BruteForce),
+		storage.NewDocument[limiter.State]("data/lockouts.json"))
 
-	// Новости
-	cfg, err := config.Load("config.json")
+	apiKeys := apikey.New(storage.NewDocument[apikey.State](apiKeysPath))
+	if keys, err := apiKeys.List(); err == nil && len(keys) == 0 {
+		log.Printf("[admin] нет ни одного API ключа, /admin/ недоступен. Создайте ключ: gml-auth apikey create -name admin -scopes '*'")
+	}
+
+	baseURL := strings.TrimSuffix(cfg.Textures.PublicURL, "/")
+	if baseURL == "" {
+		baseURL = "http://127.0.0.1:" + port
+		log.Printf("[textures] textures.public_url не задан, ссылки на скины будут вести на %s", baseURL)
+	}
+	tex := textures.New(texturesDir)
+
+	proxies, err := ipfilter.ParseProxies(cfg.Security.TrustedProxies)
 	if err != nil {
-		log.Printf("[news] config.json не найден, /api/news/* вернёт []")
+		log.Fatalf("[config] %v", err)
 	}
-	interval := time.Duration(cfg.News.RefreshSeconds) * time.Second
 
-	var tgCache, dcCache *news.Cache
-	if cfg.News.Telegram.Token != "" {
-		tgCache = buildCache(news.NewTelegramProvider(cfg.News.Telegram.Token, cfg.News.Telegram.Channel), interval)
-		log.Printf("[news] Telegram: %s → /api/news/telegram", cfg.News.Telegram.Channel)
+	opts := []handlers.Option{
+		handlers.WithLimiter(lim),
+		handlers.WithAPIKeys(apiKeys),
+		handlers.WithTextures(tex, baseURL),
+		handlers.WithIPBans(ipfilter.NewBans(storage.NewDocument[ipfilter.State](ipBansPath))),
+		handlers.WithHWID(hwid.NewBans(storage.NewDocument[hwid.State](hwidBansPath)), cfg.Security.HWID.MaxAccounts),
+		handlers.WithGroups(groups.New(storage.NewDocument[groups.State](groupsPath))),
+		handlers.WithMaintenance(maintenance.New(storage.NewDocument[maintenance.State](maintenancePath), maintenance.Settings{
+			Enabled:     cfg.Maintenance.Enabled,
+			Message:     cfg.Maintenance.Message,
+			StartsAt:    cfg.Maintenance.StartsAt,
+			EndsAt:      cfg.Maintenance.EndsAt,
+			AllowGroups: cfg.Maintenance.AllowGroups,
+			AllowLogins: cfg.Maintenance.AllowLogins,
+		})),
+		handlers.WithUUIDStrategy(cfg.Storage.UUIDStrategy),
+		handlers.WithPasswordPolicy(passwordPolicy(cfg.Security.Password)),
+		handlers.WithSignInPolicy(groups.Policy{Groups: cfg.Security.SignIn.Groups, Logins: cfg.Security.SignIn.Logins}),
+	}
+	if reg := cfg.Registration; reg.Enabled {
+		opts = append(opts, handlers.WithRegistration(
+			invites.New(storage.NewDocument[invites.State](invitesPath)),
+			limiter.NewRate(reg.MaxPerIP, time.Duration(reg.WindowSeconds)*time.Second)))
 	}
-	if cfg.News.Discord.Token != "" {
-		dcCache = buildCache(news.NewDiscordProvider(cfg.News.Discord.Token, cfg.News.Discord.Channel), interval)
-		log.Printf("[news] Discord: %s → /api/news/discord", cfg.News.Discord.Channel)
+	if rc := cfg.Recovery; rc.Enabled {
+		senders := map[string]notify.Sender{}
+		if token := cfg.News.BotToken(news.TypeTelegram); token != "" {
+			senders[notify.ChannelTelegram] = notify.NewTelegramBot(token)
+		}
+		if token := cfg.News.BotToken(news.TypeDiscord); token != "" {
+			senders[notify.ChannelDiscord] = notify.NewDiscordBot(token)
+		}
+		if len(senders) == 0 {
+			log.Printf("[recovery] в news нет токена Telegram или Discord бота: коды сброса пароля некуда отправлять")
+		}
+		opts = append(opts, handlers.WithRecovery(recovery.New(recovery.Config{
+			TTL:         time.Duration(rc.CodeTTLSeconds) * time.Second,
+			Resend:      time.Duration(rc.ResendSeconds) * time.Second,
+			MaxAttempts: rc.MaxAttempts,
+		}, storage.NewDocument[recovery.State](resetsPath)), senders))
+	}
+	if a := cfg.Security.Audit; a.Enabled {
+		opts = append(opts, handlers.WithAudit(audit.New(audit.Config{
+			Path:    a.Path,
+			MaxSize: int64(a.MaxSizeMB) << 20,
+			MaxAge:  time.Duration(a.MaxAgeDays) * 24 * time.Hour,
+		})))
+	}
+	if cfg.Sessions.Enabled {
+		sessions := session.New(session.Config{
+			AccessTTL:  time.Duration(cfg.Sessions.AccessTTLSeconds) * time.Second,
+			RefreshTTL: time.Duration(cfg.Sessions.RefreshTTLSeconds) * time.Second,
+		}, storage.NewDocument[session.State](sessionsPath))
+		opts = append(opts, handlers.WithSessions(sessions))
+	}
+	var ygg *yggdrasil.Handler
+	if cfg.Yggdrasil.Enabled {
+		key, err := yggdrasil.LoadOrCreateKey(yggdrasilKeyPath)
+		if err != nil {
+			log.Fatalf("[yggdrasil] ключ подписи: %v", err)
+		}
+		// клиент принимает текстуры только с доменов из skinDomains
+		skinDomains := cfg.Yggdrasil.SkinDomains
+		if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+			skinDomains = append(skinDomains, u.Hostname())
+		}
+		ygg, err = yggdrasil.New(yggdrasil.Config{
+			ServerName:  cfg.Yggdrasil.ServerName,
+			Homepage:    cfg.Yggdrasil.Homepage,
+			SkinDomains: skinDomains,
+		}, store, key, storage.NewDocument[yggdrasil.TokenState](yggTokensPath))
+		if err != nil {
+			log.Fatalf("[yggdrasil] %v", err)
+		}
+		ygg.SetTextures(yggdrasilTextures(baseURL))
+		opts = append(opts, handlers.WithRevoker(ygg.RevokeUser))
 	}
 
-	registerNews(mux, "/api/news/telegram", tgCache)
-	registerNews(mux, "/api/news/discord", dcCache)
+	authHandler := handlers.NewAuthHandler(store, opts...)
+	adminHandler := handlers.NewAdminHandler(store, opts...)
+	accountHandler := handlers.NewAccountHandler(store, opts...)
+	if ygg != nil {
+		ygg.SetGate(authHandler.Gate())
+	}
+	texturesHandler := handlers.NewTexturesHandler(store, tex)
 
-	// /api/news — объединённая лента (оба источника)
-	switch {
-	case tgCache != nil && dcCache != nil:
-		combined := buildCache(news.NewMultiProvider(
-			news.NewTelegramProvider(cfg.News.Telegram.Token, cfg.News.Telegram.Channel),
-			news.NewDiscordProvider(cfg.News.Discord.Token, cfg.News.Discord.Channel),
-		), interval)
-		mux.HandleFunc("/api/news", handlers.NewNewsHandler(combined).List)
-	case tgCache != nil:
-		mux.HandleFunc("/api/news", handlers.NewNewsHandler(tgCache).List)
-	case dcCache != nil:
-		mux.HandleFunc("/api/news", handlers.NewNewsHandler(dcCache).List)
-	default:
-		mux.HandleFunc("/api/news", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte("[]\n"))
-		})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", authHandler.SignIn)
+	mux.HandleFunc("/api/v1/integrations/auth/signin", authHandler.SignIn)
+	mux.HandleFunc("/api/v1/users/refresh", authHandler.Refresh)
+	mux.HandleFunc("/api/v1/status", authHandler.Status)
+	mux.HandleFunc("/api/v1/register", authHandler.Register)
+	mux.HandleFunc("/api/v1/password-reset", authHandler.PasswordReset)
+	mux.HandleFunc("/api/v1/password-reset/confirm", authHandler.PasswordResetConfirm)
+	mux.Handle("/admin/", adminHandler)
+	mux.Handle("/api/v1/account", accountHandler)
+	mux.Handle("/api/v1/account/", accountHandler)
+	mux.Handle("/textures/", texturesHandler)
+	mux.Handle("/skins/", texturesHandler)
+	mux.Handle("/capes/", texturesHandler)
+	if ygg != nil {
+		mux.Handle("/yggdrasil/", http.StripPrefix("/yggdrasil", ygg))
+		log.Printf("[yggdrasil] authlib-injector: http://<адрес>:%s/yggdrasil/", port)
+	}
+
+	// Новости: /api/news — все источники, /api/news/{name} — источник или лента из news.feeds
+	feeds, err := news.Build(news.NewRegistry(), newsSources(cfg.News), newsFeeds(cfg.News),
+		time.Duration(cfg.News.RefreshSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("[news] %v", err)
+	}
+	feeds.Start()
+	for _, name := range feeds.Sources() {
+		log.Printf("[news] источник → /api/news/%s", name)
 	}
+	for _, f := range cfg.News.Feeds {
+		log.Printf("[news] лента %s (%s) → /api/news/%s", f.Name, strings.Join(f.Sources, ", "), f.Name)
+	}
+	mux.HandleFunc("/api/news", handlers.NewNewsHandler(feeds.All()).List)
+	mux.HandleFunc("/api/news/{name}", handlers.NewNewsFeedHandler(feeds).List)
 
 	fmt.Println("===========================================")
 	fmt.Println("  GML Auth Server")
@@ -137,5 +401,10 @@ func main() {
 	fmt.Println("===========================================")
 	log.Printf("Сервер запущен, ожидаю подключения на :%s", port)
 
-	log.Fatal(http.ListenAndServe(":"+port, loggingMiddleware(mux)))
+	var handler http.Handler = mux
+	if ygg != nil {
+		handler = authlibInjectorMiddleware(handler)
+	}
+	// адрес клиента определяется до журнала, чтобы в нём был адрес игрока, а не прокси
+	log.Fatal(http.ListenAndServe(":"+port, proxies.Middleware(loggingMiddleware(handler))))
 }
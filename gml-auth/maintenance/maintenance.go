@@ -0,0 +1,126 @@
+// Package maintenance — режим технических работ: вход закрыт для всех,
+// кроме разрешённых групп и логинов. Включается в config.json или через admin API,
+// может начинаться и заканчиваться по расписанию.
+package maintenance
+
+import (
+	"gml-auth/groups"
+	"gml-auth/models"
+	"gml-auth/storage"
+	"time"
+)
+
+// DefaultMessage — текст для игроков, если свой не задан
+const DefaultMessage = "Идут технические работы. Вход временно закрыт."
+
+// Settings — режим работ
+type Settings struct {
+	Enabled     bool      `json:"enabled"`
+	Message     string    `json:"message"`
+	StartsAt    time.Time `json:"starts_at,omitzero"` // пусто — сразу
+	EndsAt      time.Time `json:"ends_at,omitzero"`   // пусто — до выключения
+	AllowGroups []string  `json:"allow_groups"`
+	AllowLogins []string  `json:"allow_logins"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+// Active — работы идут в момент now
+func (s Settings) Active(now time.Time) bool {
+	return s.Enabled &&
+		(s.StartsAt.IsZero() || !now.Before(s.StartsAt)) &&
+		(s.EndsAt.IsZero() || now.Before(s.EndsAt))
+}
+
+// Scheduled — работы включены и начнутся позже
+func (s Settings) Scheduled(now time.Time) bool {
+	return s.Enabled && !s.StartsAt.IsZero() && now.Before(s.StartsAt)
+}
+
+// Allows — пользователь может входить во время работ
+func (s Settings) Allows(u models.User, now time.Time) bool {
+	p := groups.Policy{Groups: s.AllowGroups, Logins: s.AllowLogins}
+	// пустой список разрешённых — вход закрыт для всех
+	return !p.Empty() && p.Allows(u, now)
+}
+
+// Text — сообщение игрокам
+func (s Settings) Text() string {
+	if s.Message == "" {
+		return DefaultMessage
+	}
+	return s.Message
+}
+
+// Status — публичное состояние для лаунчера (GET /api/v1/status)
+type Status struct {
+	Maintenance bool      `json:"maintenance"`
+	Scheduled   bool      `json:"scheduled"`
+	Message     string    `json:"message,omitempty"`
+	StartsAt    time.Time `json:"starts_at,omitzero"`
+	EndsAt      time.Time `json:"ends_at,omitzero"`
+}
+
+// State — содержимое data/maintenance.json. Settings == nil — действуют настройки из config.json.
+type State struct {
+	Settings *Settings `json:"settings"`
+}
+
+type Store struct {
+	doc      *storage.Document[State]
+	defaults Settings
+	now      func() time.Time
+}
+
+// New — defaults берутся из config.json, пока режим не изменили через admin API
+func New(doc *storage.Document[State], defaults Settings) *Store {
+	return &Store{doc: doc, defaults: defaults, now: time.Now}
+}
+
+// Get — текущие настройки
+func (s *Store) Get() (Settings, error) {
+	settings := s.defaults
+	err := s.doc.Read(func(st *State) {
+		if st.Settings != nil {
+			settings = *st.Settings
+		}
+	})
+	return settings, err
+}
+
+// Set сохраняет настройки; by — кто изменил
+func (s *Store) Set(settings Settings, by string) (Settings, error) {
+	settings.UpdatedBy = by
+	settings.UpdatedAt = s.now().UTC()
+	err := s.doc.Update(func(st *State) error {
+		st.Settings = &settings
+		return nil
+	})
+	return settings, err
+}
+
+// Reset возвращает настройки из config.json
+func (s *Store) Reset() error {
+	return s.doc.Update(func(st *State) error {
+		st.Settings = nil
+		return nil
+	})
+}
+
+// Status — состояние на текущий момент
+func (s *Store) Status() (Status, error) {
+	settings, err := s.Get()
+	if err != nil {
+		return Status{}, err
+	}
+	now := s.now()
+	st := Status{
+		Maintenance: settings.Active(now),
+		Scheduled:   settings.Scheduled(now),
+	}
+	if st.Maintenance || st.Scheduled {
+		st.Message = settings.Text()
+		st.StartsAt, st.EndsAt = settings.StartsAt, settings.EndsAt
+	}
+	return st, nil
+}
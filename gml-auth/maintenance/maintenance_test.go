@@ -0,0 +1,56 @@
+package maintenance
+
+import (
+	"gml-auth/models"
+	"gml-auth/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	s := Settings{Enabled: true, StartsAt: now.Add(time.Hour), EndsAt: now.Add(3 * time.Hour)}
+	if s.Active(now) || !s.Scheduled(now) {
+		t.Error("expected scheduled, not active yet")
+	}
+	if !s.Active(now.Add(2*time.Hour)) || s.Active(now.Add(3*time.Hour)) {
+		t.Error("expected active only between starts_at and ends_at")
+	}
+	if (Settings{StartsAt: now}).Active(now) {
+		t.Error("disabled settings must not be active")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	now := time.Now()
+	admin := models.User{Login: "Notch", Groups: []models.UserGroup{{Name: "admin"}}}
+	player := models.User{Login: "Steve"}
+	if (Settings{Enabled: true}).Allows(admin, now) {
+		t.Error("without allow lists nobody may sign in")
+	}
+	s := Settings{Enabled: true, AllowGroups: []string{"admin"}, AllowLogins: []string{"alex"}}
+	if !s.Allows(admin, now) || s.Allows(player, now) || !s.Allows(models.User{Login: "Alex"}, now) {
+		t.Error("unexpected allow result")
+	}
+}
+
+func TestStoreOverridesConfig(t *testing.T) {
+	s := New(storage.NewDocument[State](filepath.Join(t.TempDir(), "maintenance.json")), Settings{Enabled: true, Message: "из конфига"})
+	if st, _ := s.Status(); !st.Maintenance || st.Message != "из конфига" {
+		t.Errorf("expected config defaults: %+v", st)
+	}
+	if _, err := s.Set(Settings{Enabled: false}, "web-panel"); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := s.Status(); st.Maintenance || st.Message != "" {
+		t.Errorf("expected maintenance off: %+v", st)
+	}
+	if got, _ := s.Get(); got.UpdatedBy != "web-panel" {
+		t.Errorf("expected author to be recorded: %+v", got)
+	}
+	s.Reset()
+	if st, _ := s.Status(); !st.Maintenance {
+		t.Error("expected config defaults after reset")
+	}
+}
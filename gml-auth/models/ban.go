@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// BanRecord — запись истории блокировок пользователя
+type BanRecord struct {
+	Reason   string    `json:"reason"`
+	By       string    `json:"by"` // имя API ключа администратора
+	At       time.Time `json:"at"`
+	Until    time.Time `json:"until,omitzero"` // пусто — бессрочно
+	LiftedAt time.Time `json:"lifted_at,omitzero"`
+	LiftedBy string    `json:"lifted_by,omitempty"` // имя ключа или "expired"
+}
+
+// LiftedByExpiry — отметка в истории, что блокировка снята по истечении срока
+const LiftedByExpiry = "expired"
+
+// IsBlocked — блокировка действует: бессрочная или срок ещё не истёк.
+// Истёкшую блокировку снимает фоновая очистка, но проверки не ждут её.
+func (u User) IsBlocked(now time.Time) bool {
+	return u.Blocked && (u.BlockedUntil.IsZero() || now.Before(u.BlockedUntil))
+}
+
+// BanExpired — временная блокировка, срок которой уже прошёл
+func (u User) BanExpired(now time.Time) bool {
+	return u.Blocked && !u.BlockedUntil.IsZero() && !now.Before(u.BlockedUntil)
+}
+
+// Ban блокирует пользователя и добавляет запись в историю.
+// Повторная блокировка закрывает предыдущую запись и начинает новую.
+func (u *User) Ban(reason, by string, at, until time.Time) {
+	if u.Blocked {
+		u.closeBan(by, at)
+	}
+	u.Blocked = true
+	u.BlockReason = reason
+	u.BlockedAt = at
+	u.BlockedBy = by
+	u.BlockedUntil = until
+	u.BanHistory = append(u.BanHistory, BanRecord{Reason: reason, By: by, At: at, Until: until})
+}
+
+// Unban снимает блокировку; by — кто снял (или LiftedByExpiry)
+func (u *User) Unban(by string, at time.Time) {
+	if u.Blocked {
+		u.closeBan(by, at)
+	}
+	u.Blocked = false
+	u.BlockReason = ""
+	u.BlockedAt = time.Time{}
+	u.BlockedBy = ""
+	u.BlockedUntil = time.Time{}
+}
+
+func (u *User) closeBan(by string, at time.Time) {
+	if n := len(u.BanHistory); n > 0 && u.BanHistory[n-1].LiftedAt.IsZero() {
+		u.BanHistory[n-1].LiftedAt = at
+		u.BanHistory[n-1].LiftedBy = by
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// maxDevices — сколько последних устройств помнить у пользователя
+const maxDevices = 20
+
+// Device — устройство (HWID от лаунчера), с которого входил пользователь
+type Device struct {
+	HWID        string    `json:"hwid"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// HasDevice — пользователь уже входил с устройства hwid
+func (u User) HasDevice(hwid string) bool {
+	return slices.ContainsFunc(u.Devices, func(d Device) bool { return d.HWID == hwid })
+}
+
+// HWIDs — все известные устройства пользователя
+func (u User) HWIDs() []string {
+	ids := make([]string, len(u.Devices))
+	for i, d := range u.Devices {
+		ids[i] = d.HWID
+	}
+	return ids
+}
+
+// SeeDevice отмечает вход с устройства hwid. Хранятся только maxDevices
+// последних устройств, самые давние забываются.
+func (u *User) SeeDevice(hwid string, now time.Time) {
+	for i := range u.Devices {
+		if u.Devices[i].HWID == hwid {
+			u.Devices[i].LastSeenAt = now
+			return
+		}
+	}
+	u.Devices = append(u.Devices, Device{HWID: hwid, FirstSeenAt: now, LastSeenAt: now})
+	if len(u.Devices) > maxDevices {
+		oldest := 0
+		for i, d := range u.Devices {
+			if d.LastSeenAt.Before(u.Devices[oldest].LastSeenAt) {
+				oldest = i
+			}
+		}
+		u.Devices = slices.Delete(u.Devices, oldest, oldest+1)
+	}
+}
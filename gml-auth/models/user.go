@@ -1,13 +1,143 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 // User — запись пользователя в JSON базе данных
 type User struct {
 	UUID        string `json:"uuid"`
 	Login       string `json:"login"`
-	Password    string `json:"password"`
+	Password    string `json:"password"` // argon2id/bcrypt хэш; открытый текст мигрирует при входе
 	IsSlim      bool   `json:"is_slim"`
 	Blocked     bool   `json:"blocked"`
 	BlockReason string `json:"block_reason"`
+
+	// Подробности текущей блокировки; пустой BlockedUntil — бессрочно
+	BlockedAt    time.Time   `json:"blocked_at,omitzero"`
+	BlockedBy    string      `json:"blocked_by,omitempty"`
+	BlockedUntil time.Time   `json:"blocked_until,omitzero"`
+	BanHistory   []BanRecord `json:"ban_history,omitempty"`
+
+	// Двухфакторная аутентификация (TOTP). Пустой секрет — 2FA выключена.
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"` // защита от повторного использования кода
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // SHA-256 хэши одноразовых кодов
+
+	// Текстуры — SHA-256 PNG файлов в data/textures. Пусто — текстуры нет.
+	SkinHash string `json:"skin_hash,omitempty"`
+	CapeHash string `json:"cape_hash,omitempty"`
+
+	Groups      []UserGroup `json:"groups,omitempty"`
+	CreatedAt   time.Time   `json:"created_at,omitzero"`    // пусто у записей, созданных до появления поля
+	LastLoginAt time.Time   `json:"last_login_at,omitzero"` // последний успешный вход
+	LastLoginIP string      `json:"last_login_ip,omitempty"`
+	Devices     []Device    `json:"devices,omitempty"`   // устройства, с которых был вход
+	InviteID    string      `json:"invite_id,omitempty"` // код приглашения, по которому игрок зарегистрировался
+
+	// Привязки к мессенджерам (числовые ID пользователей) для кодов сброса пароля
+	TelegramID string `json:"telegram_id,omitempty"`
+	DiscordID  string `json:"discord_id,omitempty"`
+}
+
+// Clone — копия, не делящая срезы с оригиналом: хранилище отдаёт копии,
+// а колбэки UpdateUser меняют Devices и RecoveryCodes на месте
+func (u User) Clone() User {
+	u.BanHistory = slices.Clone(u.BanHistory)
+	u.RecoveryCodes = slices.Clone(u.RecoveryCodes)
+	u.Groups = slices.Clone(u.Groups)
+	u.Devices = slices.Clone(u.Devices)
+	return u
+}
+
+// UserGroup — членство в группе; пустой ExpiresAt — бессрочно
+type UserGroup struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Active — членство ещё не истекло
+func (g UserGroup) Active(now time.Time) bool {
+	return g.ExpiresAt.IsZero() || now.Before(g.ExpiresAt)
+}
+
+// InGroup — пользователь состоит в группе name и срок членства не истёк
+func (u User) InGroup(name string, now time.Time) bool {
+	for _, g := range u.Groups {
+		if g.Name == name && g.Active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveGroups — имена групп с действующим членством
+func (u User) ActiveGroups(now time.Time) []string {
+	var names []string
+	for _, g := range u.Groups {
+		if g.Active(now) {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// UserInfo — пользователь в ответах admin API. Хэш пароля, секрет 2FA
+// и коды восстановления сюда не попадают.
+type UserInfo struct {
+	UUID         string      `json:"uuid"`
+	Login        string      `json:"login"`
+	IsSlim       bool        `json:"is_slim"`
+	Blocked      bool        `json:"blocked"`
+	BlockReason  string      `json:"block_reason"`
+	BlockedAt    time.Time   `json:"blocked_at,omitzero"`
+	BlockedBy    string      `json:"blocked_by,omitempty"`
+	BlockedUntil time.Time   `json:"blocked_until,omitzero"`
+	TOTPEnabled  bool        `json:"totp_enabled"`
+	SkinHash     string      `json:"skin_hash,omitempty"`
+	CapeHash     string      `json:"cape_hash,omitempty"`
+	Groups       []UserGroup `json:"groups"`
+	CreatedAt    time.Time   `json:"created_at,omitzero"`
+	LastLoginAt  time.Time   `json:"last_login_at,omitzero"`
+	LastLoginIP  string      `json:"last_login_ip,omitempty"`
+	Devices      []Device    `json:"devices"`
+	InviteID     string      `json:"invite_id,omitempty"`
+	TelegramID   string      `json:"telegram_id,omitempty"`
+	DiscordID    string      `json:"discord_id,omitempty"`
+}
+
+// Info — представление пользователя для admin API
+func (u User) Info() UserInfo {
+	groups := u.Groups
+	if groups == nil {
+		groups = []UserGroup{}
+	}
+	devices := u.Devices
+	if devices == nil {
+		devices = []Device{}
+	}
+	return UserInfo{
+		UUID:         u.UUID,
+		Login:        u.Login,
+		IsSlim:       u.IsSlim,
+		Blocked:      u.Blocked,
+		BlockReason:  u.BlockReason,
+		BlockedAt:    u.BlockedAt,
+		BlockedBy:    u.BlockedBy,
+		BlockedUntil: u.BlockedUntil,
+		TOTPEnabled:  u.TOTPSecret != "",
+		SkinHash:     u.SkinHash,
+		CapeHash:     u.CapeHash,
+		Groups:       groups,
+		CreatedAt:    u.CreatedAt,
+		LastLoginAt:  u.LastLoginAt,
+		LastLoginIP:  u.LastLoginIP,
+		Devices:      devices,
+		InviteID:     u.InviteID,
+		TelegramID:   u.TelegramID,
+		DiscordID:    u.DiscordID,
+	}
 }
 
 // Database — структура JSON файла
@@ -20,14 +150,26 @@ type AuthRequest struct {
 	Login    string `json:"Login"`
 	Password string `json:"Password"`
 	Totp     string `json:"Totp"`
+	Hwid     string `json:"Hwid,omitempty"` // идентификатор устройства, если лаунчер его передаёт
 }
 
-// AuthResponse — ответ при успешной авторизации (200)
+// AuthResponse — ответ при успешной авторизации (200).
+// Токены заполняются, только если в config.json включены сессии.
 type AuthResponse struct {
-	Login    string `json:"Login"`
-	UserUuid string `json:"UserUuid"`
-	IsSlim   bool   `json:"IsSlim"`
-	Message  string `json:"Message"`
+	Login        string `json:"Login"`
+	UserUuid     string `json:"UserUuid"`
+	IsSlim       bool   `json:"IsSlim"`
+	Message      string `json:"Message"`
+	AccessToken  string `json:"AccessToken,omitempty"`
+	RefreshToken string `json:"RefreshToken,omitempty"`
+	ExpiresIn    int    `json:"ExpiresIn,omitempty"`
+	// Groups — действующие группы пользователя (vip, tester ...) для лаунчера и плагинов
+	Groups []string `json:"Groups,omitempty"`
+}
+
+// RefreshRequest — тело POST /api/v1/users/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 // ErrorResponse — ответ при ошибке (401, 403, 404)
@@ -40,14 +182,157 @@ type CreateUserRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 	IsSlim   bool   `json:"is_slim"`
+	// UUID — сохранить существующий UUID (только при storage.uuid_strategy = imported)
+	UUID string `json:"uuid,omitempty"`
+}
+
+// RegisterRequest — POST /api/v1/register
+type RegisterRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Invite   string `json:"invite"`
+	IsSlim   bool   `json:"is_slim"`
+}
+
+// InviteRequest — POST /admin/invites; срок — duration или until, не оба сразу
+type InviteRequest struct {
+	MaxUses  int       `json:"max_uses"` // 0 — одноразовый
+	Note     string    `json:"note"`
+	Duration string    `json:"duration"`
+	Until    time.Time `json:"until,omitzero"`
+}
+
+// UpdateUserRequest — PATCH /admin/users/{login}: меняются только переданные поля
+type UpdateUserRequest struct {
+	Login       *string `json:"login"` // переименование, UUID сохраняется
+	Password    *string `json:"password"`
+	IsSlim      *bool   `json:"is_slim"`
+	Blocked     *bool   `json:"blocked"`
+	BlockReason *string `json:"block_reason"`
+	// BlockedUntil — срок блокировки; нулевое время — бессрочно
+	BlockedUntil *time.Time `json:"blocked_until"`
+	// Привязки к мессенджерам; пустая строка — отвязать
+	TelegramID *string `json:"telegram_id"`
+	DiscordID  *string `json:"discord_id"`
 }
 
-// BlockRequest — запрос для блокировки пользователя
+// TOTPEnrollResponse — результат включения 2FA; секрет и коды показываются один раз
+type TOTPEnrollResponse struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TextureResponse — результат загрузки скина или плаща
+type TextureResponse struct {
+	Hash   string `json:"hash"`
+	URL    string `json:"url"`
+	IsSlim bool   `json:"is_slim"`
+}
+
+// BlockRequest — запрос для блокировки пользователя.
+// Без duration и until блокировка бессрочная.
 type BlockRequest struct {
-	Reason string `json:"reason"`
+	Reason   string    `json:"reason"`
+	Duration string    `json:"duration,omitempty"` // 30m, 12h, 7d, 2w
+	Until    time.Time `json:"until,omitzero"`     // RFC 3339
+}
+
+// IPBanRequest — POST /admin/ip-bans. Без duration и until блокировка бессрочная.
+type IPBanRequest struct {
+	CIDR     string    `json:"cidr"` // адрес или подсеть: 203.0.113.7, 203.0.113.0/24, 2001:db8::/32
+	Reason   string    `json:"reason"`
+	Duration string    `json:"duration,omitempty"`
+	Until    time.Time `json:"until,omitzero"`
+}
+
+// HWIDBanRequest — POST /admin/hwid-bans. Без duration и until блокировка бессрочная.
+type HWIDBanRequest struct {
+	HWID     string    `json:"hwid"`
+	Reason   string    `json:"reason"`
+	Duration string    `json:"duration,omitempty"`
+	Until    time.Time `json:"until,omitzero"`
+}
+
+// LinkedAccount — другой аккаунт, с которым у пользователя общее устройство
+type LinkedAccount struct {
+	UUID    string   `json:"uuid"`
+	Login   string   `json:"login"`
+	Blocked bool     `json:"blocked"`
+	HWIDs   []string `json:"hwids"` // общие устройства
+}
+
+// GroupRequest — POST /admin/groups, PATCH /admin/groups/{name}
+type GroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// MembershipRequest — PUT /admin/users/{login}/groups/{name}.
+// Без duration и expires_at членство бессрочное.
+type MembershipRequest struct {
+	Duration  string    `json:"duration,omitempty"` // 30d, 2w ...
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// GroupMember — участник группы в GET /admin/groups/{name}/members
+type GroupMember struct {
+	UUID      string    `json:"uuid"`
+	Login     string    `json:"login"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// ChangePasswordRequest — PUT /api/v1/account/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// AccountUpdateRequest — PATCH /api/v1/account: меняются только переданные поля
+type AccountUpdateRequest struct {
+	IsSlim *bool `json:"is_slim"`
+}
+
+// LinkRequest — PUT/DELETE /api/v1/account/links/{telegram|discord}.
+// Первый запрос с текущим паролем (и кодом 2FA) отправляет код в чат,
+// второй — с тем же id и полученным code — сохраняет привязку.
+type LinkRequest struct {
+	ID              string `json:"id"`
+	CurrentPassword string `json:"current_password"`
+	TOTP            string `json:"totp"`
+	Code            string `json:"code"`
+}
+
+// PasswordResetRequest — POST /api/v1/password-reset: выслать код.
+// Channel (telegram или discord) можно не указывать — выбирается привязанный.
+type PasswordResetRequest struct {
+	Login   string `json:"login"`
+	Channel string `json:"channel"`
+}
+
+// PasswordResetConfirmRequest — POST /api/v1/password-reset/confirm
+type PasswordResetConfirmRequest struct {
+	Login       string `json:"login"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
 }
 
 // WebErrorResponse — формат ошибки для GML Launcher web-панели (ожидает errors[])
 type WebErrorResponse struct {
 	Errors []string `json:"errors"`
 }
+
+// FieldError — ошибка проверки одного поля; Code — машинный код (too_short, common ...)
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse — 400 с разбором по полям. Message совпадает с первой ошибкой,
+// чтобы клиенты, понимающие только ErrorResponse, тоже показали причину.
+type ValidationErrorResponse struct {
+	Message string       `json:"Message"`
+	Errors  []string     `json:"errors"`
+	Fields  []FieldError `json:"fields"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestUserSerialization(t *testing.T) {
@@ -26,3 +27,45 @@ func TestUserSerialization(t *testing.T) {
 		t.Errorf("expected testuser, got %s", u2.Login)
 	}
 }
+
+func TestBanHistory(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	var u User
+	u.Ban("спам", "web-panel", now, now.Add(time.Hour))
+	if !u.IsBlocked(now) || u.IsBlocked(now.Add(time.Hour)) {
+		t.Error("temporary ban must end at BlockedUntil")
+	}
+	if !u.BanExpired(now.Add(time.Hour)) {
+		t.Error("expected ban to be expired")
+	}
+	// повторная блокировка закрывает предыдущую запись
+	u.Ban("читы", "admin", now.Add(time.Minute), time.Time{})
+	if !u.IsBlocked(now.Add(24*time.Hour)) || u.BanExpired(now.Add(24*time.Hour)) {
+		t.Error("permanent ban must not expire")
+	}
+	u.Unban("admin", now.Add(2*time.Minute))
+	if u.Blocked || u.BlockReason != "" || !u.BlockedUntil.IsZero() {
+		t.Errorf("expected ban fields cleared: %+v", u)
+	}
+	if len(u.BanHistory) != 2 {
+		t.Fatalf("expected 2 records, got %+v", u.BanHistory)
+	}
+	if u.BanHistory[0].LiftedBy != "admin" || u.BanHistory[1].LiftedAt.IsZero() {
+		t.Errorf("expected both records closed: %+v", u.BanHistory)
+	}
+}
+
+func TestSeeDeviceKeepsRecent(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	var u User
+	for i := 0; i <= maxDevices; i++ {
+		u.SeeDevice(string(rune('a'+i)), now.Add(time.Duration(i)*time.Minute))
+	}
+	if len(u.Devices) != maxDevices || u.HasDevice("a") {
+		t.Fatalf("expected the oldest device to be forgotten: %+v", u.HWIDs())
+	}
+	u.SeeDevice("b", now.Add(time.Hour))
+	if u.Devices[0].HWID != "b" || !u.Devices[0].LastSeenAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected last seen time updated: %+v", u.Devices[0])
+	}
+}
@@ -24,12 +24,16 @@ func (m *MultiProvider) Fetch(limit int) ([]NewsItem, error) {
 		}
 		all = append(all, items...)
 	}
-	// Сортировка по дате убывания (RFC3339 сортируется лексикографически)
-	sort.Slice(all, func(i, j int) bool {
-		return all[i].CreatedAt > all[j].CreatedAt
-	})
+	sortNewest(all)
 	if len(all) > limit {
 		all = all[:limit]
 	}
 	return all, nil
 }
+
+// sortNewest сортирует по дате убывания (RFC3339 сортируется лексикографически)
+func sortNewest(items []NewsItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt > items[j].CreatedAt
+	})
+}
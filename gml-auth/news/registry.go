@@ -0,0 +1,76 @@
+package news
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Типы источников, которые есть в NewRegistry
+const (
+	TypeTelegram = "telegram"
+	TypeDiscord  = "discord"
+)
+
+var ErrUnknownType = errors.New("unknown news source type")
+
+// SourceConfig — один источник из news.sources в config.json
+type SourceConfig struct {
+	Type     string
+	Name     string // часть пути /api/news/{name}
+	Token    string
+	Channel  string
+	Interval time.Duration // период обновления; 0 — общий из news.refresh_seconds
+}
+
+// Factory создаёт Provider для источника своего типа
+type Factory func(SourceConfig) (Provider, error)
+
+// Registry — фабрики источников по типу. Новый тип (RSS, VK ...) добавляется
+// через Register без изменений в main.go.
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry — реестр со встроенными типами telegram и discord
+func NewRegistry() *Registry {
+	r := &Registry{factories: map[string]Factory{}}
+	r.Register(TypeTelegram, func(c SourceConfig) (Provider, error) {
+		if c.Token == "" || c.Channel == "" {
+			return nil, errors.New("нужны token и channel")
+		}
+		return NewTelegramProvider(c.Token, c.Channel), nil
+	})
+	r.Register(TypeDiscord, func(c SourceConfig) (Provider, error) {
+		if c.Token == "" || c.Channel == "" {
+			return nil, errors.New("нужны token и channel")
+		}
+		return NewDiscordProvider(c.Token, c.Channel), nil
+	})
+	return r
+}
+
+// Register добавляет или заменяет фабрику для типа typ
+func (r *Registry) Register(typ string, f Factory) {
+	r.factories[typ] = f
+}
+
+// New создаёт Provider по типу источника
+func (r *Registry) New(c SourceConfig) (Provider, error) {
+	f, ok := r.factories[c.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (известные: %v)", ErrUnknownType, c.Type, r.Types())
+	}
+	return f(c)
+}
+
+// Types — известные типы источников по алфавиту
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.factories))
+	for t := range r.factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,139 @@
+package news
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// validName — имя источника или ленты в пути /api/news/{name}
+var validName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Reader — лента, из которой отдаются новости
+type Reader interface {
+	Get(limit, offset int) []NewsItem
+}
+
+// FeedConfig — объединённая лента из нескольких источников (news.feeds в config.json)
+type FeedConfig struct {
+	Name    string
+	Sources []string
+}
+
+// Set — все источники и ленты сервера. У каждого источника свой кэш и период
+// обновления; объединённые ленты читают кэши источников и сами к API не обращаются,
+// поэтому один бот не опрашивается дважды.
+type Set struct {
+	sources map[string]*Cache
+	order   []string // источники в порядке конфигурации
+	feeds   map[string]Merged
+}
+
+// Build создаёт кэши источников и ленты. Имена источников и лент общие для /api/news/{name}
+// и не должны повторяться. Кэши не запускаются до Start.
+func Build(reg *Registry, sources []SourceConfig, feeds []FeedConfig, interval time.Duration) (*Set, error) {
+	s := &Set{sources: map[string]*Cache{}, feeds: map[string]Merged{}}
+	taken := func(name string) error {
+		if !validName.MatchString(name) {
+			return fmt.Errorf("имя %q: только a-z, 0-9, _ и -, до 32 символов", name)
+		}
+		if _, ok := s.sources[name]; ok {
+			return fmt.Errorf("имя %q уже занято", name)
+		}
+		if _, ok := s.feeds[name]; ok {
+			return fmt.Errorf("имя %q уже занято", name)
+		}
+		return nil
+	}
+	for _, c := range sources {
+		if err := taken(c.Name); err != nil {
+			return nil, err
+		}
+		p, err := reg.New(c)
+		if err != nil {
+			return nil, fmt.Errorf("источник %s: %w", c.Name, err)
+		}
+		every := c.Interval
+		if every <= 0 {
+			every = interval
+		}
+		s.sources[c.Name] = NewCache(p, every)
+		s.order = append(s.order, c.Name)
+	}
+	for _, f := range feeds {
+		if err := taken(f.Name); err != nil {
+			return nil, err
+		}
+		if len(f.Sources) == 0 {
+			return nil, fmt.Errorf("лента %s: нет источников", f.Name)
+		}
+		var merged Merged
+		for _, name := range f.Sources {
+			c, ok := s.sources[name]
+			if !ok {
+				return nil, fmt.Errorf("лента %s: источник %q не найден или выключен", f.Name, name)
+			}
+			merged = append(merged, c)
+		}
+		s.feeds[f.Name] = merged
+	}
+	return s, nil
+}
+
+// Start запускает обновление всех источников
+func (s *Set) Start() {
+	for _, name := range s.order {
+		s.sources[name].Start()
+	}
+}
+
+// Stop останавливает обновление
+func (s *Set) Stop() {
+	for _, name := range s.order {
+		s.sources[name].Stop()
+	}
+}
+
+// Get — источник или объединённая лента по имени
+func (s *Set) Get(name string) (Reader, bool) {
+	if c, ok := s.sources[name]; ok {
+		return c, true
+	}
+	if f, ok := s.feeds[name]; ok {
+		return f, true
+	}
+	return nil, false
+}
+
+// All — все источники одной лентой (/api/news)
+func (s *Set) All() Reader {
+	all := make(Merged, 0, len(s.order))
+	for _, name := range s.order {
+		all = append(all, s.sources[name])
+	}
+	return all
+}
+
+// Sources — имена источников в порядке конфигурации
+func (s *Set) Sources() []string {
+	return s.order
+}
+
+// Merged — несколько кэшей одной лентой, новые первыми
+type Merged []*Cache
+
+func (m Merged) Get(limit, offset int) []NewsItem {
+	var all []NewsItem
+	for _, c := range m {
+		all = append(all, c.Get(0, 0)...)
+	}
+	sortNewest(all)
+	if offset >= len(all) {
+		return []NewsItem{}
+	}
+	all = all[offset:]
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
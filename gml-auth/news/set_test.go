@@ -0,0 +1,89 @@
+package news
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockRegistry — реестр с типом mock, который отдаёт заранее заданные новости по имени источника
+func mockRegistry(items map[string][]NewsItem) *Registry {
+	r := NewRegistry()
+	r.Register("mock", func(c SourceConfig) (Provider, error) {
+		return &mockProvider{items: items[c.Name]}, nil
+	})
+	return r
+}
+
+func TestBuildValidation(t *testing.T) {
+	reg := mockRegistry(nil)
+	cases := []struct {
+		name    string
+		sources []SourceConfig
+		feeds   []FeedConfig
+		want    string
+	}{
+		{"unknown type", []SourceConfig{{Type: "rss", Name: "a"}}, nil, "unknown news source type"},
+		{"bad name", []SourceConfig{{Type: "mock", Name: "News Main"}}, nil, "только a-z"},
+		{"duplicate", []SourceConfig{{Type: "mock", Name: "a"}, {Type: "mock", Name: "a"}}, nil, "уже занято"},
+		{"feed clashes with source", []SourceConfig{{Type: "mock", Name: "a"}}, []FeedConfig{{Name: "a", Sources: []string{"a"}}}, "уже занято"},
+		{"feed unknown source", []SourceConfig{{Type: "mock", Name: "a"}}, []FeedConfig{{Name: "main", Sources: []string{"b"}}}, "не найден"},
+		{"telegram without token", []SourceConfig{{Type: TypeTelegram, Name: "tg", Channel: "@ch"}}, nil, "нужны token"},
+	}
+	for _, c := range cases {
+		_, err := Build(reg, c.sources, c.feeds, time.Minute)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected error with %q, got %v", c.name, c.want, err)
+		}
+	}
+	if _, err := NewRegistry().New(SourceConfig{Type: "rss"}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestSetFeeds(t *testing.T) {
+	reg := mockRegistry(map[string][]NewsItem{
+		"tg-main":  {{ID: 1, Title: "tg new", CreatedAt: "2026-01-03T00:00:00Z"}, {ID: 2, Title: "tg old", CreatedAt: "2026-01-01T00:00:00Z"}},
+		"tg-event": {{ID: 3, Title: "event", CreatedAt: "2026-01-04T00:00:00Z"}},
+		"dc":       {{ID: 4, Title: "dc", CreatedAt: "2026-01-02T00:00:00Z"}},
+	})
+	set, err := Build(reg, []SourceConfig{
+		{Type: "mock", Name: "tg-main"},
+		{Type: "mock", Name: "tg-event", Interval: time.Hour},
+		{Type: "mock", Name: "dc"},
+	}, []FeedConfig{{Name: "main", Sources: []string{"tg-main", "dc"}}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range set.Sources() {
+		set.sources[name].refresh()
+	}
+
+	titles := func(items []NewsItem) string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.Title)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := titles(set.All().Get(10, 0)); got != "event,tg new,dc,tg old" {
+		t.Errorf("all: unexpected order %s", got)
+	}
+	main, ok := set.Get("main")
+	if !ok {
+		t.Fatal("feed main not found")
+	}
+	if got := titles(main.Get(2, 1)); got != "dc,tg old" {
+		t.Errorf("main with offset: got %s", got)
+	}
+	if src, ok := set.Get("tg-event"); !ok || titles(src.Get(10, 0)) != "event" {
+		t.Error("source must be available by name")
+	}
+	if _, ok := set.Get("missing"); ok {
+		t.Error("unknown name must not be found")
+	}
+	if set.sources["tg-event"].interval != time.Hour || set.sources["dc"].interval != time.Minute {
+		t.Error("source interval must override the default")
+	}
+}
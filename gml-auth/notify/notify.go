@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Каналы доставки — совпадают с именами привязок в аккаунте
+const (
+	ChannelTelegram = "telegram"
+	ChannelDiscord  = "discord"
+)
+
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Sender доставляет личное сообщение пользователю мессенджера по его ID
+type Sender interface {
+	Send(userID, text string) error
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+const (
+	telegramBaseURL = "https://api.telegram.org"
+	discordBaseURL  = "https://discord.com/api/v10"
+)
+
+// TelegramBot пишет через Bot API. Бот может написать только тем,
+// кто уже начал с ним диалог (/start).
+type TelegramBot struct {
+	token   string
+	baseURL string
+}
+
+func NewTelegramBot(token string) *TelegramBot {
+	return &TelegramBot{token: token, baseURL: telegramBaseURL}
+}
+
+func (b *TelegramBot) Send(userID, text string) error {
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", b.baseURL, b.token)
+	status, err := postJSON(url, "", map[string]string{"chat_id": userID, "text": text}, &resp)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram: %d %s", status, resp.Description)
+	}
+	return nil
+}
+
+// DiscordBot пишет в личные сообщения: сначала открывает DM канал, затем отправляет сообщение.
+// Пользователь должен быть на одном сервере с ботом и не запрещать личные сообщения.
+type DiscordBot struct {
+	token   string
+	baseURL string
+}
+
+func NewDiscordBot(token string) *DiscordBot {
+	return &DiscordBot{token: "Bot " + token, baseURL: discordBaseURL}
+}
+
+func (b *DiscordBot) Send(userID, text string) error {
+	var channel struct {
+		ID string `json:"id"`
+	}
+	status, err := postJSON(b.baseURL+"/users/@me/channels", b.token, map[string]string{"recipient_id": userID}, &channel)
+	if err != nil {
+		return err
+	}
+	if status >= 300 || channel.ID == "" {
+		return fmt.Errorf("discord: open dm: %d", status)
+	}
+	status, err = postJSON(b.baseURL+"/channels/"+channel.ID+"/messages", b.token, map[string]string{"content": text}, nil)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("discord: send: %d", status)
+	}
+	return nil
+}
+
+// postJSON отправляет body и разбирает ответ в out (если не nil), возвращая HTTP статус
+func postJSON(url, auth string, body, out any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && resp.StatusCode < 300 {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
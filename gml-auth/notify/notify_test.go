@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTelegramSend(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottesttoken/sendMessage" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["chat_id"] == "403" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"ok":false,"description":"Forbidden: bot can't initiate conversation with a user"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	b := &TelegramBot{token: "testtoken", baseURL: srv.URL}
+	if err := b.Send("12345", "Код: 123456"); err != nil {
+		t.Fatal(err)
+	}
+	if got["chat_id"] != "12345" || got["text"] != "Код: 123456" {
+		t.Errorf("unexpected request: %v", got)
+	}
+	if err := b.Send("403", "Код"); err == nil {
+		t.Error("expected error when bot can't write to the user")
+	}
+}
+
+func TestDiscordSend(t *testing.T) {
+	var content string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot testtoken" {
+			t.Errorf("unexpected Authorization %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/users/@me/channels":
+			if body["recipient_id"] != "42" {
+				t.Errorf("unexpected recipient %v", body)
+			}
+			w.Write([]byte(`{"id":"dm-1"}`))
+		case "/channels/dm-1/messages":
+			content = body["content"]
+			w.Write([]byte(`{"id":"m-1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b := &DiscordBot{token: "Bot testtoken", baseURL: srv.URL}
+	if err := b.Send("42", "Код: 654321"); err != nil {
+		t.Fatal(err)
+	}
+	if content != "Код: 654321" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
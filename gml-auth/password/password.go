@@ -0,0 +1,122 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Параметры argon2id (рекомендации OWASP: 19 MiB, 2 итерации, 1 поток)
+const (
+	argonMemory  = 19 * 1024
+	argonTime    = 2
+	argonThreads = 1
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+const argonPrefix = "$argon2id$"
+
+// maxArgonMemory — верхняя граница m в сохранённом хэше (1 GiB в KiB).
+// Больше не принимаем: каждая попытка входа выделяла бы столько памяти.
+const maxArgonMemory = 1024 * 1024
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// Hash возвращает argon2id хэш в формате PHC:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func Hash(plain string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonPrefix, argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// IsHashed сообщает, похоже ли значение на хэш в одном из поддерживаемых форматов.
+// Всё остальное считается паролем в открытом виде (старые записи users.json).
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, argonPrefix) || isBcrypt(stored)
+}
+
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// Verify сравнивает пароль с сохранённым значением.
+// needsRehash = true, если пароль верный, но хранится в открытом виде,
+// в bcrypt или с устаревшими параметрами argon2id — такой пароль нужно перехэшировать.
+func Verify(stored, plain string) (ok, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(stored, argonPrefix):
+		p, err := parseArgon(stored)
+		if err != nil {
+			return false, false
+		}
+		key := argon2.IDKey([]byte(plain), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return false, false
+		}
+		return true, p.memory != argonMemory || p.time != argonTime || p.threads != argonThreads
+	case isBcrypt(stored):
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(plain)) != nil {
+			return false, false
+		}
+		return true, true
+	default:
+		if stored == "" {
+			return false, false
+		}
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(plain)) == 1, true
+	}
+}
+
+type argonParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon разбирает хэш argon2id и проверяет параметры: из отредактированного
+// вручную или импортированного хэша t=0 или p=0 уронили бы argon2.IDKey.
+func parseArgon(stored string) (argonParams, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return argonParams{}, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argonParams{}, ErrMalformedHash
+	}
+	var p argonParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return argonParams{}, ErrMalformedHash
+	}
+	if p.time < 1 || p.threads < 1 || p.memory < 8*uint32(p.threads) || p.memory > maxArgonMemory {
+		return argonParams{}, ErrMalformedHash
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argonParams{}, ErrMalformedHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) != argonKeyLen {
+		return argonParams{}, ErrMalformedHash
+	}
+	return p, nil
+}
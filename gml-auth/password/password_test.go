@@ -0,0 +1,111 @@
+package password
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	h, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(h, "$argon2id$v=19$") {
+		t.Fatalf("unexpected format: %s", h)
+	}
+	ok, rehash := Verify(h, "secret")
+	if !ok || rehash {
+		t.Errorf("expected ok without rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _ := Verify(h, "wrong"); ok {
+		t.Error("wrong password accepted")
+	}
+}
+
+func TestHashUsesRandomSalt(t *testing.T) {
+	a, _ := Hash("secret")
+	b, _ := Hash("secret")
+	if a == b {
+		t.Error("expected different hashes for the same password")
+	}
+}
+
+func TestVerifyPlainTextNeedsRehash(t *testing.T) {
+	ok, rehash := Verify("pass123", "pass123")
+	if !ok || !rehash {
+		t.Errorf("expected ok with rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _ := Verify("pass123", "pass12"); ok {
+		t.Error("wrong plain text password accepted")
+	}
+	if ok, _ := Verify("", ""); ok {
+		t.Error("empty stored password must never match")
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	h, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, rehash := Verify(string(h), "secret")
+	if !ok || !rehash {
+		t.Errorf("expected ok with rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _ := Verify(string(h), "wrong"); ok {
+		t.Error("wrong bcrypt password accepted")
+	}
+}
+
+func TestVerifyOutdatedArgonParams(t *testing.T) {
+	// тот же формат, но m=8192,t=1 — должен пройти и запросить перехэширование
+	salt := []byte("saltsaltsaltsalt")
+	key := argon2.IDKey([]byte("secret"), salt, 1, 8192, 1, 32)
+	old := "$argon2id$v=19$m=8192,t=1,p=1$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(key)
+	ok, rehash := Verify(old, "secret")
+	if !ok || !rehash {
+		t.Errorf("expected ok with rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _ := Verify("$argon2id$broken", "secret"); ok {
+		t.Error("malformed hash accepted")
+	}
+}
+
+func TestIsHashed(t *testing.T) {
+	h, _ := Hash("x")
+	if !IsHashed(h) || !IsHashed("$2b$10$abc") {
+		t.Error("expected hashed values to be detected")
+	}
+	if IsHashed("plain") {
+		t.Error("plain text detected as hash")
+	}
+}
+
+func TestVerifyRejectsUnsafeArgonParams(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("saltsaltsaltsalt"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	for _, params := range []string{
+		"m=19456,t=0,p=1",   // argon2.IDKey паникует
+		"m=19456,t=2,p=0",   // тоже
+		"m=4,t=2,p=1",       // меньше 8·p KiB
+		"m=4194304,t=2,p=1", // 4 GiB на каждую попытку
+	} {
+		stored := "$argon2id$v=19$" + params + "$" + salt + "$" + key
+		if _, err := parseArgon(stored); err == nil {
+			t.Errorf("%s: expected error", params)
+		}
+		if ok, _ := Verify(stored, "secret"); ok {
+			t.Errorf("%s: accepted", params)
+		}
+	}
+	short := "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "$" + base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	if _, err := parseArgon(short); err == nil {
+		t.Error("16-byte key: expected error")
+	}
+}
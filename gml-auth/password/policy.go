@@ -0,0 +1,115 @@
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды нарушений политики — по ним web-панель может подсветить поле или перевести текст
+const (
+	CodeEmpty         = "empty"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeMissingLower  = "missing_lower"
+	CodeMissingUpper  = "missing_upper"
+	CodeMissingDigit  = "missing_digit"
+	CodeMissingSymbol = "missing_symbol"
+	CodeCommon        = "common"
+	CodeSameAsLogin   = "same_as_login"
+)
+
+// maxLength — верхняя граница длины: argon2id хэширует любой ввод,
+// но мегабайтный «пароль» — это уже нагрузка на сервер
+const maxLength = 256
+
+// Violation — одно нарушение политики
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Policy — требования к новым паролям. Нулевое значение требует только непустой пароль.
+// Существующие пароли политика не трогает: она проверяется при создании и смене.
+type Policy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool // не буква и не цифра
+	// DenyList — распространённые пароли в нижнем регистре (см. LoadDenyList)
+	DenyList map[string]struct{}
+	// DisallowLogin — пароль не может совпадать с логином (без учёта регистра)
+	DisallowLogin bool
+}
+
+// Check возвращает все нарушения сразу, чтобы игрок исправил пароль за одну попытку.
+// Пустой результат — пароль подходит.
+func (p Policy) Check(login, plain string) []Violation {
+	if plain == "" {
+		return []Violation{{CodeEmpty, "Пароль не может быть пустым"}}
+	}
+	var out []Violation
+	n := utf8.RuneCountInString(plain)
+	if n < p.MinLength {
+		out = append(out, Violation{CodeTooShort, fmt.Sprintf("Пароль должен быть не короче %d символов", p.MinLength)})
+	}
+	if n > maxLength {
+		out = append(out, Violation{CodeTooLong, fmt.Sprintf("Пароль должен быть не длиннее %d символов", maxLength)})
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		out = append(out, Violation{CodeMissingLower, "Добавьте строчную букву"})
+	}
+	if p.RequireUpper && !upper {
+		out = append(out, Violation{CodeMissingUpper, "Добавьте заглавную букву"})
+	}
+	if p.RequireDigit && !digit {
+		out = append(out, Violation{CodeMissingDigit, "Добавьте цифру"})
+	}
+	if p.RequireSymbol && !symbol {
+		out = append(out, Violation{CodeMissingSymbol, "Добавьте символ, который не является буквой или цифрой"})
+	}
+	if _, ok := p.DenyList[strings.ToLower(plain)]; ok {
+		out = append(out, Violation{CodeCommon, "Пароль слишком распространённый"})
+	}
+	if p.DisallowLogin && login != "" && strings.EqualFold(plain, login) {
+		out = append(out, Violation{CodeSameAsLogin, "Пароль не должен совпадать с логином"})
+	}
+	return out
+}
+
+// LoadDenyList читает список запрещённых паролей: по одному в строке,
+// пустые строки и строки с # пропускаются, регистр не учитывается
+func LoadDenyList(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list := map[string]struct{}{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	return list, sc.Err()
+}
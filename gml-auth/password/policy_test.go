@@ -0,0 +1,62 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func codes(vs []Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.Code)
+	}
+	return out
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := Policy{
+		MinLength:     8,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		DenyList:      map[string]struct{}{"qwerty123!a": {}},
+		DisallowLogin: true,
+	}
+	cases := []struct {
+		login, pass string
+		want        []string
+	}{
+		{"Steve", "", []string{CodeEmpty}},
+		{"Steve", "1", []string{CodeTooShort, CodeMissingLower, CodeMissingUpper, CodeMissingSymbol}},
+		{"Steve", "Пароль-2024", nil},
+		{"Steve", "QWERTY123!a", []string{CodeCommon}},
+		{"Ab1!Ab1!x", "ab1!ab1!X", []string{CodeSameAsLogin}},
+		{"Steve", "Correct-Horse-7", nil},
+	}
+	for _, c := range cases {
+		if got := codes(p.Check(c.login, c.pass)); !slices.Equal(got, c.want) {
+			t.Errorf("%q: expected %v, got %v", c.pass, c.want, got)
+		}
+	}
+	if got := (Policy{}).Check("Steve", "1"); len(got) != 0 {
+		t.Errorf("zero policy must accept any non-empty password, got %v", got)
+	}
+}
+
+func TestLoadDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	os.WriteFile(path, []byte("# топ паролей\n123456\n\n  Password \n"), 0644)
+	list, err := LoadDenyList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 entries, got %v", list)
+	}
+	if _, ok := list["password"]; !ok {
+		t.Error("entries must be trimmed and lowercased")
+	}
+}
@@ -0,0 +1,155 @@
+package recovery
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gml-auth/storage"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTooSoon         = errors.New("code requested too soon")
+	ErrInvalidCode     = errors.New("invalid code")
+	ErrExpired         = errors.New("code expired")
+	ErrTooManyAttempts = errors.New("too many attempts")
+)
+
+// Config — параметры одноразовых кодов сброса пароля
+type Config struct {
+	TTL         time.Duration // сколько действует код
+	Resend      time.Duration // не чаще одного кода за интервал
+	MaxAttempts int           // неверных вводов до сгорания кода
+}
+
+// Code — выданный код. Сам код хранится только в виде SHA-256.
+type Code struct {
+	Hash      string    `json:"hash"`
+	Channel   string    `json:"channel"` // куда отправлен: telegram или discord
+	SentAt    time.Time `json:"sent_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"attempts"`
+}
+
+// State — содержимое data/password_resets.json; ключ — UUID пользователя
+// для сброса пароля или "link:канал:id:UUID" для подтверждения привязки мессенджера
+type State struct {
+	Codes map[string]*Code `json:"codes"`
+}
+
+type Store struct {
+	cfg Config
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func New(cfg Config, doc *storage.Document[State]) *Store {
+	return &Store{cfg: cfg, doc: doc, now: time.Now}
+}
+
+// Issue выпускает новый шестизначный код для пользователя, заменяя прежний.
+// Повторный запрос раньше cfg.Resend возвращает ErrTooSoon.
+func (s *Store) Issue(userUUID, channel string) (string, error) {
+	code, err := randomCode()
+	if err != nil {
+		return "", err
+	}
+	now := s.now().UTC()
+	err = s.doc.Update(func(st *State) error {
+		if st.Codes == nil {
+			st.Codes = map[string]*Code{}
+		}
+		s.prune(st, now)
+		if c := st.Codes[userUUID]; c != nil && now.Before(c.SentAt.Add(s.cfg.Resend)) {
+			return ErrTooSoon
+		}
+		st.Codes[userUUID] = &Code{
+			Hash:      hashCode(code),
+			Channel:   channel,
+			SentAt:    now,
+			ExpiresAt: now.Add(s.cfg.TTL),
+		}
+		return nil
+	})
+	return code, err
+}
+
+// Cancel удаляет код (например, если его не удалось доставить)
+func (s *Store) Cancel(userUUID string) error {
+	return s.doc.Update(func(st *State) error {
+		delete(st.Codes, userUUID)
+		return nil
+	})
+}
+
+// CancelUser удаляет все коды пользователя: сброса пароля и подтверждения привязки
+// (например, когда у аккаунта сменился UUID)
+func (s *Store) CancelUser(userUUID string) error {
+	return s.doc.Update(func(st *State) error {
+		for key := range st.Codes {
+			if key == userUUID || strings.HasSuffix(key, ":"+userUUID) {
+				delete(st.Codes, key)
+			}
+		}
+		return nil
+	})
+}
+
+// Verify проверяет и гасит код. Неверный ввод увеличивает счётчик попыток;
+// после cfg.MaxAttempts код сгорает.
+func (s *Store) Verify(userUUID, code string) error {
+	now := s.now()
+	hash := hashCode(code)
+	var result error
+	err := s.doc.Update(func(st *State) error {
+		c := st.Codes[userUUID]
+		switch {
+		case c == nil:
+			result = ErrInvalidCode
+		case !now.Before(c.ExpiresAt):
+			delete(st.Codes, userUUID)
+			result = ErrExpired
+		case subtle.ConstantTimeCompare([]byte(c.Hash), []byte(hash)) == 1:
+			delete(st.Codes, userUUID)
+		default:
+			c.Attempts++
+			result = ErrInvalidCode
+			if s.cfg.MaxAttempts > 0 && c.Attempts >= s.cfg.MaxAttempts {
+				delete(st.Codes, userUUID)
+				result = ErrTooManyAttempts
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// prune убирает истёкшие коды, если с момента отправки прошёл и интервал повтора
+func (s *Store) prune(st *State, now time.Time) {
+	for id, c := range st.Codes {
+		if !now.Before(c.ExpiresAt) && !now.Before(c.SentAt.Add(s.cfg.Resend)) {
+			delete(st.Codes, id)
+		}
+	}
+}
+
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package recovery
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, *time.Time) {
+	s := New(Config{TTL: 15 * time.Minute, Resend: time.Minute, MaxAttempts: 3},
+		storage.NewDocument[State](filepath.Join(t.TempDir(), "password_resets.json")))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	s, now := newTestStore(t)
+	code, err := s.Issue("uuid-1", "telegram")
+	if err != nil || len(code) != 6 {
+		t.Fatalf("unexpected code %q: %v", code, err)
+	}
+	if _, err := s.Issue("uuid-1", "telegram"); !errors.Is(err, ErrTooSoon) {
+		t.Fatalf("expected ErrTooSoon, got %v", err)
+	}
+	*now = now.Add(time.Minute)
+	code, err = s.Issue("uuid-1", "discord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("uuid-1", "wrong"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+	if err := s.Verify("uuid-2", code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("code must not work for another user, got %v", err)
+	}
+	if err := s.Verify("uuid-1", code); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("uuid-1", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("code must be single-use, got %v", err)
+	}
+}
+
+func TestExpiryAndAttempts(t *testing.T) {
+	s, now := newTestStore(t)
+	code, _ := s.Issue("uuid-1", "telegram")
+	*now = now.Add(15 * time.Minute)
+	if err := s.Verify("uuid-1", code); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+
+	code, _ = s.Issue("uuid-1", "telegram")
+	s.Verify("uuid-1", "000000x")
+	s.Verify("uuid-1", "000000y")
+	if err := s.Verify("uuid-1", "000000z"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if err := s.Verify("uuid-1", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("burned code must not work, got %v", err)
+	}
+}
+
+func TestCancelUser(t *testing.T) {
+	s, _ := newTestStore(t)
+	reset, _ := s.Issue("uuid-1", "telegram")
+	link, _ := s.Issue("link:telegram:12345:uuid-1", "telegram")
+	other, _ := s.Issue("uuid-2", "telegram")
+	if err := s.CancelUser("uuid-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("uuid-1", reset); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("reset code must be cancelled, got %v", err)
+	}
+	if err := s.Verify("link:telegram:12345:uuid-1", link); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("link code must be cancelled, got %v", err)
+	}
+	if err := s.Verify("uuid-2", other); err != nil {
+		t.Errorf("other user's code must stay: %v", err)
+	}
+}
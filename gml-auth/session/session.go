@@ -0,0 +1,281 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gml-auth/storage"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrNotFound     = errors.New("session not found")
+)
+
+// Config — время жизни токенов
+type Config struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Session — серверная сессия. Токены хранятся только в виде SHA-256.
+type Session struct {
+	ID               string    `json:"id"`
+	UserUUID         string    `json:"user_uuid"`
+	Login            string    `json:"login"`
+	AccessHash       string    `json:"access_hash"`
+	RefreshHash      string    `json:"refresh_hash"`
+	PrevRefreshHash  string    `json:"prev_refresh_hash,omitempty"` // для обнаружения повторного использования
+	CreatedAt        time.Time `json:"created_at"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	IP               string    `json:"ip,omitempty"`
+	UserAgent        string    `json:"user_agent,omitempty"`
+}
+
+// Info — сессия без хэшей токенов, для admin API и самого игрока
+type Info struct {
+	ID               string    `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	LastUsedAt       time.Time `json:"last_used_at"`
+	RefreshExpiresAt time.Time `json:"expires_at"`
+	IP               string    `json:"ip,omitempty"`
+	UserAgent        string    `json:"user_agent,omitempty"`
+}
+
+// State — содержимое data/sessions.json
+type State struct {
+	Sessions map[string]*Session `json:"sessions"`
+}
+
+// Tokens — пара токенов, выдаваемая клиенту
+type Tokens struct {
+	SessionID    string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // секунды до истечения access token
+}
+
+type Store struct {
+	cfg Config
+	doc *storage.Document[State]
+	now func() time.Time
+}
+
+func New(cfg Config, doc *storage.Document[State]) *Store {
+	return &Store{cfg: cfg, doc: doc, now: time.Now}
+}
+
+// Issue создаёт новую сессию для пользователя
+func (s *Store) Issue(userUUID, login, ip, userAgent string) (Tokens, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return Tokens{}, err
+	}
+	now := s.now()
+	sess := &Session{
+		ID:        id,
+		UserUUID:  userUUID,
+		Login:     login,
+		CreatedAt: now,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+	tokens, err := s.rotate(sess, now)
+	if err != nil {
+		return Tokens{}, err
+	}
+	err = s.doc.Update(func(st *State) error {
+		if st.Sessions == nil {
+			st.Sessions = map[string]*Session{}
+		}
+		s.prune(st, now)
+		st.Sessions[id] = sess
+		return nil
+	})
+	return tokens, err
+}
+
+// rotate выпускает новую пару токенов для сессии
+func (s *Store) rotate(sess *Session, now time.Time) (Tokens, error) {
+	access, err := randomToken(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	sess.PrevRefreshHash = sess.RefreshHash
+	sess.AccessHash = hashToken(access)
+	sess.RefreshHash = hashToken(refresh)
+	sess.LastUsedAt = now
+	sess.AccessExpiresAt = now.Add(s.cfg.AccessTTL)
+	sess.RefreshExpiresAt = now.Add(s.cfg.RefreshTTL)
+	return Tokens{
+		SessionID:    sess.ID,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(s.cfg.AccessTTL.Seconds()),
+	}, nil
+}
+
+// Authenticate проверяет access token и возвращает сессию
+func (s *Store) Authenticate(access string) (Session, error) {
+	h := hashToken(access)
+	now := s.now()
+	var out Session
+	err := ErrInvalidToken
+	s.doc.Read(func(st *State) {
+		for _, sess := range st.Sessions {
+			if sess.AccessHash != h {
+				continue
+			}
+			if now.After(sess.AccessExpiresAt) {
+				err = ErrExpiredToken
+				return
+			}
+			out, err = *sess, nil
+			return
+		}
+	})
+	return out, err
+}
+
+// Refresh обменивает refresh token на новую пару (ротация).
+// Повторное предъявление уже использованного refresh token означает утечку —
+// такая сессия отзывается целиком.
+func (s *Store) Refresh(refresh string) (Tokens, Session, error) {
+	h := hashToken(refresh)
+	now := s.now()
+	var (
+		tokens Tokens
+		out    Session
+	)
+	err := s.doc.Update(func(st *State) error {
+		for id, sess := range st.Sessions {
+			switch {
+			case sess.PrevRefreshHash == h:
+				delete(st.Sessions, id)
+				return nil
+			case sess.RefreshHash != h:
+				continue
+			case now.After(sess.RefreshExpiresAt):
+				delete(st.Sessions, id)
+				return nil
+			}
+			t, err := s.rotate(sess, now)
+			if err != nil {
+				return err
+			}
+			tokens, out = t, *sess
+			return nil
+		}
+		return ErrInvalidToken
+	})
+	if err == nil && tokens.AccessToken == "" {
+		err = ErrInvalidToken
+	}
+	return tokens, out, err
+}
+
+// List возвращает активные сессии пользователя, новые первыми
+func (s *Store) List(userUUID string) []Info {
+	now := s.now()
+	out := []Info{}
+	s.doc.Read(func(st *State) {
+		for _, sess := range st.Sessions {
+			if sess.UserUUID != userUUID || now.After(sess.RefreshExpiresAt) {
+				continue
+			}
+			out = append(out, Info{
+				ID:               sess.ID,
+				CreatedAt:        sess.CreatedAt,
+				LastUsedAt:       sess.LastUsedAt,
+				RefreshExpiresAt: sess.RefreshExpiresAt,
+				IP:               sess.IP,
+				UserAgent:        sess.UserAgent,
+			})
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Revoke удаляет одну сессию пользователя
+func (s *Store) Revoke(userUUID, id string) error {
+	return s.doc.Update(func(st *State) error {
+		sess, ok := st.Sessions[id]
+		if !ok || sess.UserUUID != userUUID {
+			return ErrNotFound
+		}
+		delete(st.Sessions, id)
+		return nil
+	})
+}
+
+// RevokeUser удаляет все сессии пользователя и возвращает их количество
+func (s *Store) RevokeUser(userUUID string) (int, error) {
+	n := 0
+	s.doc.Read(func(st *State) {
+		for _, sess := range st.Sessions {
+			if sess.UserUUID == userUUID {
+				n++
+			}
+		}
+	})
+	if n == 0 {
+		return 0, nil
+	}
+	err := s.doc.Update(func(st *State) error {
+		for id, sess := range st.Sessions {
+			if sess.UserUUID == userUUID {
+				delete(st.Sessions, id)
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// RevokeOthers удаляет все сессии пользователя, кроме keepID (текущей), и возвращает их количество
+func (s *Store) RevokeOthers(userUUID, keepID string) (int, error) {
+	n := 0
+	err := s.doc.Update(func(st *State) error {
+		for id, sess := range st.Sessions {
+			if sess.UserUUID == userUUID && id != keepID {
+				delete(st.Sessions, id)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (s *Store) prune(st *State, now time.Time) {
+	for id, sess := range st.Sessions {
+		if now.After(sess.RefreshExpiresAt) {
+			delete(st.Sessions, id)
+		}
+	}
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"errors"
+	"gml-auth/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, *time.Time) {
+	doc := storage.NewDocument[State](filepath.Join(t.TempDir(), "sessions.json"))
+	s := New(Config{AccessTTL: time.Minute, RefreshTTL: time.Hour}, doc)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	s, now := newTestStore(t)
+	tok, err := s.Issue("uuid-1", "Steve", "1.1.1.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.ExpiresIn != 60 {
+		t.Errorf("expected expires_in 60, got %d", tok.ExpiresIn)
+	}
+	sess, err := s.Authenticate(tok.AccessToken)
+	if err != nil || sess.UserUUID != "uuid-1" {
+		t.Fatalf("expected session for uuid-1, got %+v, %v", sess, err)
+	}
+	if _, err := s.Authenticate(tok.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh token must not work as access token, got %v", err)
+	}
+	*now = now.Add(2 * time.Minute)
+	if _, err := s.Authenticate(tok.AccessToken); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expected expired access token, got %v", err)
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	s, _ := newTestStore(t)
+	first, _ := s.Issue("uuid-1", "Steve", "", "")
+
+	second, sess, err := s.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.ID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Fatal("expected rotated tokens for the same session")
+	}
+	if _, err := s.Authenticate(first.AccessToken); err == nil {
+		t.Error("old access token must stop working after refresh")
+	}
+
+	// повтор старого refresh token — сессия отзывается
+	if _, _, err := s.Refresh(first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected reuse to fail, got %v", err)
+	}
+	if _, _, err := s.Refresh(second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Error("expected session to be revoked after reuse")
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	s, now := newTestStore(t)
+	tok, _ := s.Issue("uuid-1", "Steve", "", "")
+	*now = now.Add(2 * time.Hour)
+	if _, _, err := s.Refresh(tok.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected expired refresh to fail, got %v", err)
+	}
+}
+
+func TestListAndRevoke(t *testing.T) {
+	s, now := newTestStore(t)
+	a, _ := s.Issue("uuid-1", "Steve", "", "")
+	*now = now.Add(time.Second)
+	s.Issue("uuid-1", "Steve", "", "")
+	s.Issue("uuid-2", "Alex", "", "")
+
+	list := s.List("uuid-1")
+	if len(list) != 2 || list[1].ID != a.SessionID {
+		t.Fatalf("expected 2 sessions newest first, got %+v", list)
+	}
+	if err := s.Revoke("uuid-2", a.SessionID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("must not revoke other user's session, got %v", err)
+	}
+	if err := s.Revoke("uuid-1", a.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.RevokeUser("uuid-1"); n != 1 {
+		t.Errorf("expected 1 remaining session revoked, got %d", n)
+	}
+	if len(s.List("uuid-2")) != 1 {
+		t.Error("other user's sessions must survive")
+	}
+}
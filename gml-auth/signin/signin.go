@@ -0,0 +1,381 @@
+// Package signin — проверки входа по логину и паролю, общие для /signin,
+// Yggdrasil (authlib-injector) и Basic-авторизации в /api/v1/account:
+// блокировки адресов, устройств и аккаунта, защита от перебора, пароль, 2FA,
+// технические работы и политика входа.
+package signin
+
+import (
+	"errors"
+	"fmt"
+	"gml-auth/audit"
+	"gml-auth/groups"
+	"gml-auth/hwid"
+	"gml-auth/ipfilter"
+	"gml-auth/limiter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/storage"
+	"gml-auth/totp"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// msgClosed — отказ по политике входа (закрытый тест, вход только для персонала)
+const msgClosed = "Вход для вашего аккаунта закрыт"
+
+// Gate — последовательность проверок входа. nil-зависимость выключает свою проверку.
+type Gate struct {
+	Store       storage.UserStore
+	Limiter     *limiter.Limiter
+	IPBans      *ipfilter.Bans
+	HWIDBans    *hwid.Bans
+	Maintenance *maintenance.Store
+	Policy      groups.Policy // кому разрешён вход; пустое — всем
+	// MaxAccountsPerHWID — сколько аккаунтов может входить с одного устройства; 0 — без ограничения
+	MaxAccountsPerHWID int
+	Audit              *audit.Log // журнал попыток; nil — не ведётся
+}
+
+// Attempt — попытка входа
+type Attempt struct {
+	Login    string
+	Password string
+	TOTP     string
+	IP       string
+	HWID     string // как прислал клиент; пусто — клиент HWID не передаёт
+	// TOTPInPassword — код 2FA дописан к паролю через двоеточие: "пароль:123456"
+	// (Yggdrasil не умеет спрашивать код отдельно)
+	TOTPInPassword bool
+}
+
+// Denial — отказ во входе: ответ игроку и запись для журнала (без IP и User-Agent)
+type Denial struct {
+	Code       int
+	Message    string
+	RetryAfter time.Duration // для 429
+	Entry      audit.Entry
+}
+
+// Error — причина для лога (у сбоев хранилища это текст ошибки, а не «Ошибка сервера»)
+func (d *Denial) Error() string { return d.Entry.Reason }
+
+// Check проверяет попытку входа. При успехе возвращает пользователя и нормализованный HWID.
+// Неудачи по паролю и коду учитываются в защите от перебора, удачный вход сбрасывает счётчик.
+func (g *Gate) Check(a Attempt) (models.User, string, *Denial) {
+	deny := func(e audit.Entry, code int, msg string) (models.User, string, *Denial) {
+		e.Code = code
+		if e.Reason == "" {
+			e.Reason = msg
+		}
+		return models.User{}, "", &Denial{Code: code, Message: msg, Entry: e}
+	}
+
+	if ban, banned := g.ipBanned(a.IP); banned {
+		return deny(audit.Entry{Login: a.Login, Result: audit.ResultIPBlocked, Reason: ban.Reason},
+			http.StatusForbidden, IPBlockedMessage(ban, time.Now()))
+	}
+	var device string
+	if a.HWID != "" {
+		var err error
+		if device, err = hwid.Normalize(a.HWID); err != nil {
+			return deny(audit.Entry{Login: a.Login, Result: audit.ResultBadRequest}, http.StatusBadRequest, "Неверный HWID")
+		}
+		if ban, banned := g.hwidBanned(device); banned {
+			return deny(audit.Entry{Login: a.Login, HWID: device, Result: audit.ResultHWIDBlocked, Reason: ban.Reason},
+				http.StatusForbidden, HWIDBlockedMessage(ban, time.Now()))
+		}
+	}
+	if wait := g.wait(a.Login, a.IP); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		user, _, d := deny(audit.Entry{Login: a.Login, Result: audit.ResultThrottled}, http.StatusTooManyRequests,
+			fmt.Sprintf("Слишком много попыток входа. Повторите через %d сек.", seconds))
+		d.RetryAfter = wait
+		return user, "", d
+	}
+
+	user, err := g.Store.FindByLogin(a.Login)
+	if errors.Is(err, storage.ErrNotFound) {
+		g.fail("", a.IP)
+		return deny(audit.Entry{Login: a.Login, Result: audit.ResultNotFound}, http.StatusNotFound, "Пользователь не найден")
+	}
+	if err != nil {
+		return deny(audit.Entry{Login: a.Login, Result: audit.ResultError, Reason: err.Error()}, http.StatusInternalServerError, "Ошибка сервера")
+	}
+	attempt := audit.Entry{Login: user.Login, UserUUID: user.UUID, HWID: device}
+
+	if d := g.account(user, attempt); d != nil {
+		return models.User{}, "", d
+	}
+
+	secret, code := a.Password, a.TOTP
+	if a.TOTPInPassword && user.TOTPSecret != "" {
+		if i := strings.LastIndex(secret, ":"); i >= 0 {
+			secret, code = secret[:i], secret[i+1:]
+		}
+	}
+	ok, needsRehash := password.Verify(user.Password, secret)
+	if !ok {
+		g.fail(user.Login, a.IP)
+		attempt.Result = audit.ResultBadPassword
+		return deny(attempt, http.StatusUnauthorized, "Неверный логин или пароль")
+	}
+	if needsRehash {
+		g.rehash(user.Login, user.Password, secret)
+	}
+
+	if user.TOTPSecret != "" {
+		// Слово "2FA" в ответе — сигнал GML показать поле ввода кода
+		if code == "" {
+			attempt.Result = audit.ResultTOTPNeeded
+			return deny(attempt, http.StatusUnauthorized, "Введите код 2FA")
+		}
+		ok, err := g.VerifySecondFactor(user.Login, code)
+		if err != nil {
+			attempt.Result, attempt.Reason = audit.ResultError, err.Error()
+			return deny(attempt, http.StatusInternalServerError, "Ошибка сервера")
+		}
+		if !ok {
+			g.fail(user.Login, a.IP)
+			attempt.Result = audit.ResultBadTOTP
+			return deny(attempt, http.StatusUnauthorized, "Неверный код 2FA")
+		}
+	}
+
+	if !g.Policy.Allows(user, time.Now()) {
+		attempt.Result = audit.ResultDenied
+		return deny(attempt, http.StatusForbidden, msgClosed)
+	}
+
+	if device != "" && g.MaxAccountsPerHWID > 0 && !user.HasDevice(device) {
+		n, err := g.accountsOnDevice(device)
+		if err != nil {
+			attempt.Result, attempt.Reason = audit.ResultError, err.Error()
+			return deny(attempt, http.StatusInternalServerError, "Ошибка сервера")
+		}
+		if n >= g.MaxAccountsPerHWID {
+			attempt.Result = audit.ResultHWIDLimit
+			return deny(attempt, http.StatusForbidden,
+				fmt.Sprintf("С этого устройства уже входили в другие аккаунты (%d), новый вход запрещён", n))
+		}
+	}
+
+	if g.Limiter != nil {
+		if err := g.Limiter.Success(user.Login); err != nil {
+			log.Printf("[auth] limiter: %v", err)
+		}
+	}
+	return user, device, nil
+}
+
+// Admit — можно ли пускать владельца уже выданного токена (Yggdrasil join, hasJoined,
+// refresh): аккаунт не заблокирован, не входил с заблокированных устройств,
+// технические работы его пропускают, и он проходит политику входа
+// (работы и политику могли включить после выдачи токена)
+func (g *Gate) Admit(u models.User) *Denial {
+	e := audit.Entry{Login: u.Login, UserUUID: u.UUID}
+	if d := g.account(u, e); d != nil {
+		return d
+	}
+	if !g.Policy.Allows(u, time.Now()) {
+		e.Result, e.Code, e.Reason = audit.ResultDenied, http.StatusForbidden, msgClosed
+		return &Denial{Code: http.StatusForbidden, Message: msgClosed, Entry: e}
+	}
+	return nil
+}
+
+// account — проверки самого аккаунта, не зависящие от пароля: блокировка,
+// заблокированные устройства, технические работы
+func (g *Gate) account(u models.User, e audit.Entry) *Denial {
+	deny := func(result, reason, msg string) *Denial {
+		e.Result, e.Reason, e.Code = result, reason, http.StatusForbidden
+		return &Denial{Code: http.StatusForbidden, Message: msg, Entry: e}
+	}
+	if now := time.Now(); u.IsBlocked(now) {
+		return deny(audit.ResultBlocked, u.BlockReason, BlockedMessage(u, now))
+	}
+	// блокировка устройства распространяется на все аккаунты, которые с него входили
+	if ban, banned := g.hwidBanned(u.HWIDs()...); banned {
+		return deny(audit.ResultHWIDBlocked, ban.Reason, HWIDBlockedMessage(ban, time.Now()))
+	}
+	if msg, closed := g.underMaintenance(u); closed {
+		return deny(audit.ResultMaintenance, msg, msg)
+	}
+	return nil
+}
+
+// Record дописывает попытку входа в журнал с адресом и User-Agent запроса.
+// Ошибка записи не мешает входу.
+func (g *Gate) Record(r *http.Request, e audit.Entry) {
+	if g.Audit == nil {
+		return
+	}
+	e.IP, e.UserAgent = ipfilter.ClientIP(r), r.UserAgent()
+	if err := g.Audit.Record(e); err != nil {
+		log.Printf("[audit] %v", err)
+	}
+}
+
+// RecordLogin запоминает время, адрес и устройство успешного входа
+func (g *Gate) RecordLogin(login, ip, device string) {
+	err := g.Store.UpdateUser(login, func(u *models.User) error {
+		now := time.Now().UTC()
+		u.LastLoginAt = now
+		u.LastLoginIP = ip
+		if device != "" {
+			u.SeeDevice(device, now)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[auth] last login %s: %v", login, err)
+	}
+}
+
+// ipBanned — адрес ip попадает под блокировку адресов и подсетей
+func (g *Gate) ipBanned(ip string) (ipfilter.Ban, bool) {
+	if g.IPBans == nil {
+		return ipfilter.Ban{}, false
+	}
+	return g.IPBans.Match(ip)
+}
+
+// hwidBanned — хотя бы одно из устройств ids заблокировано
+func (g *Gate) hwidBanned(ids ...string) (hwid.Ban, bool) {
+	if g.HWIDBans == nil || len(ids) == 0 {
+		return hwid.Ban{}, false
+	}
+	return g.HWIDBans.Match(ids...)
+}
+
+// wait — сколько ждать до следующей попытки (защита от перебора)
+func (g *Gate) wait(login, ip string) time.Duration {
+	if g.Limiter == nil {
+		return 0
+	}
+	return g.Limiter.Check(login, ip)
+}
+
+// fail учитывает неудачную попытку входа
+func (g *Gate) fail(login, ip string) {
+	if g.Limiter == nil {
+		return
+	}
+	if err := g.Limiter.Fail(login, ip); err != nil {
+		log.Printf("[auth] limiter: %v", err)
+	}
+}
+
+// underMaintenance — идут технические работы и пользователь не входит в число разрешённых.
+// Ошибка чтения настроек не закрывает вход.
+func (g *Gate) underMaintenance(u models.User) (string, bool) {
+	if g.Maintenance == nil {
+		return "", false
+	}
+	settings, err := g.Maintenance.Get()
+	if err != nil {
+		log.Printf("[maintenance] %v", err)
+		return "", false
+	}
+	now := time.Now()
+	if !settings.Active(now) || settings.Allows(u, now) {
+		return "", false
+	}
+	return settings.Text(), true
+}
+
+// VerifySecondFactor проверяет TOTP-код или одноразовый код восстановления.
+// Проверка и запись последнего шага идут под блокировкой хранилища,
+// поэтому один и тот же код не пройдёт дважды даже при параллельных запросах.
+func (g *Gate) VerifySecondFactor(login, code string) (bool, error) {
+	ok := false
+	err := g.Store.UpdateUser(login, func(u *models.User) error {
+		ok = totp.VerifyUser(u, code, time.Now())
+		return nil
+	})
+	return ok, err
+}
+
+// rehash переводит пароль в актуальный формат после успешного входа.
+// Хэш меняется, только если в записи всё ещё проверенный verified: смена или сброс
+// пароля между проверкой и перехэшированием не должны вернуть старый пароль.
+// Ошибка не мешает авторизации — попробуем снова при следующем входе.
+func (g *Gate) rehash(login, verified, plain string) {
+	hashed, err := password.Hash(plain)
+	if err != nil {
+		log.Printf("[auth] rehash %s: %v", login, err)
+		return
+	}
+	if err := g.Store.UpdateUser(login, func(u *models.User) error {
+		if u.Password == verified {
+			u.Password = hashed
+		}
+		return nil
+	}); err != nil {
+		log.Printf("[auth] rehash %s: %v", login, err)
+	}
+}
+
+// accountsOnDevice — сколько аккаунтов уже входили с устройства id
+func (g *Gate) accountsOnDevice(id string) (int, error) {
+	users, err := g.Store.ListUsers()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range users {
+		if u.HasDevice(id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// FormatRemaining — оставшийся срок блокировки для сообщения игроку: "2 д 3 ч", "15 мин"
+func FormatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return "меньше минуты"
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%d д", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%d ч", hours))
+	}
+	// минуты важны только для коротких блокировок
+	if minutes > 0 && days == 0 {
+		parts = append(parts, fmt.Sprintf("%d мин", minutes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// banMessage — текст 403: что заблокировано, до какого времени и почему
+func banMessage(subject, reason string, until, now time.Time) string {
+	if until.IsZero() {
+		return fmt.Sprintf("%s. Причина: %s", subject, reason)
+	}
+	return fmt.Sprintf("%s до %s (осталось %s). Причина: %s",
+		subject, until.UTC().Format("2006-01-02 15:04 UTC"), FormatRemaining(until.Sub(now)), reason)
+}
+
+// BlockedMessage — текст 403 для заблокированного пользователя
+func BlockedMessage(u models.User, now time.Time) string {
+	return banMessage("Пользователь заблокирован", u.BlockReason, u.BlockedUntil, now)
+}
+
+// IPBlockedMessage — текст 403 для заблокированного адреса
+func IPBlockedMessage(b ipfilter.Ban, now time.Time) string {
+	return banMessage("Вход с вашего адреса заблокирован", b.Reason, b.ExpiresAt, now)
+}
+
+// HWIDBlockedMessage — текст 403 для заблокированного устройства
+func HWIDBlockedMessage(b hwid.Ban, now time.Time) string {
+	return banMessage("Устройство заблокировано", b.Reason, b.ExpiresAt, now)
+}
@@ -0,0 +1,51 @@
+package signin
+
+import (
+	"gml-auth/models"
+	"gml-auth/password"
+	"gml-auth/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormatRemaining(t *testing.T) {
+	cases := map[time.Duration]string{
+		30 * time.Second:              "меньше минуты",
+		15 * time.Minute:              "15 мин",
+		2*time.Hour + 5*time.Minute:   "2 ч 5 мин",
+		51*time.Hour + 10*time.Minute: "2 д 3 ч",
+		7 * 24 * time.Hour:            "7 д",
+	}
+	for d, want := range cases {
+		if got := FormatRemaining(d); got != want {
+			t.Errorf("%v: expected %q, got %q", d, want, got)
+		}
+	}
+}
+
+func TestRehashKeepsNewerPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	os.WriteFile(path, []byte(`{"users":[]}`), 0644)
+	store := storage.New(path)
+	store.AddUser(models.User{UUID: "uuid-1", Login: "Steve", Password: "old-plain"})
+	g := &Gate{Store: store}
+
+	// пароль сменили между проверкой и перехэшированием
+	changed, _ := password.Hash("new-pass")
+	store.UpdateUser("Steve", func(u *models.User) error {
+		u.Password = changed
+		return nil
+	})
+	g.rehash("Steve", "old-plain", "old-plain")
+	if u, _ := store.FindByLogin("Steve"); u.Password != changed {
+		t.Fatalf("rehash must not restore the old password, got %q", u.Password)
+	}
+
+	g.rehash("Steve", changed, "new-pass")
+	u, _ := store.FindByLogin("Steve")
+	if ok, _ := password.Verify(u.Password, "new-pass"); !ok || u.Password == changed {
+		t.Errorf("expected the verified hash to be replaced, got %q", u.Password)
+	}
+}
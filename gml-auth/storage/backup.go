@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBackupNotFound — резервной копии с таким именем нет
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrBackupsDisabled — резервные копии выключены в config.json
+	ErrBackupsDisabled = errors.New("резервные копии выключены")
+)
+
+// backupTimeFormat — время в имени копии: users-20240131T235959.000Z.json
+const backupTimeFormat = "20060102T150405.000Z"
+
+var backupName = regexp.MustCompile(`^[A-Za-z0-9_-]+-\d{8}T\d{6}\.\d{3}Z\.json$`)
+
+// BackupConfig — резервные копии users.json
+type BackupConfig struct {
+	Dir      string        // каталог копий
+	Interval time.Duration // не чаще одной копии за интервал; 0 — перед каждой записью
+	Keep     int           // сколько последних копий хранить; 0 — без ограничения
+	MaxAge   time.Duration // копии старше удаляются; 0 — без ограничения
+}
+
+// Backup — одна резервная копия
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupStore — хранилище, которое умеет делать и восстанавливать копии
+type BackupStore interface {
+	Backups() ([]Backup, error)
+	CreateBackup() (Backup, error)
+	RestoreBackup(name string) error
+}
+
+// backups — копии одного файла
+type backups struct {
+	cfg    BackupConfig
+	prefix string    // имя файла без расширения: users
+	last   time.Time // время последней копии; нулевое — ещё не проверяли каталог
+	now    func() time.Time
+}
+
+func newBackups(cfg BackupConfig, filePath string) *backups {
+	return &backups{
+		cfg:    cfg,
+		prefix: strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)),
+		now:    time.Now,
+	}
+}
+
+// list — копии от новых к старым
+func (b *backups) list() ([]Backup, error) {
+	entries, err := os.ReadDir(b.cfg.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := []Backup{}
+	for _, e := range entries {
+		at, ok := b.parseName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Backup{Name: e.Name(), Size: info.Size(), CreatedAt: at})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (b *backups) parseName(name string) (time.Time, bool) {
+	ts, ok := strings.CutPrefix(name, b.prefix+"-")
+	if !ok || !backupName.MatchString(name) {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(ts, ".json"))
+	return at, err == nil
+}
+
+// path — путь к копии; имя проверяется, чтобы нельзя было выйти из каталога
+func (b *backups) path(name string) (string, error) {
+	if _, ok := b.parseName(name); !ok {
+		return "", ErrBackupNotFound
+	}
+	p := filepath.Join(b.cfg.Dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return p, nil
+}
+
+// due — пора ли делать очередную копию перед записью
+func (b *backups) due() bool {
+	if b.last.IsZero() {
+		if list, err := b.list(); err == nil && len(list) > 0 {
+			b.last = list[0].CreatedAt
+		}
+	}
+	return b.now().Sub(b.last) >= b.cfg.Interval
+}
+
+// create копирует src в каталог копий и удаляет лишние старые копии.
+// Отсутствующий src (пустая база) не копируется.
+func (b *backups) create(src string) (Backup, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return Backup{}, err
+	}
+	now := b.now().UTC()
+	if !now.After(b.last) {
+		now = b.last.Add(time.Millisecond) // две копии в одну миллисекунду
+	}
+	name := b.prefix + "-" + now.Format(backupTimeFormat) + ".json"
+	if err := writeFileAtomic(filepath.Join(b.cfg.Dir, name), data, 0644); err != nil {
+		return Backup{}, err
+	}
+	b.last = now
+	b.prune()
+	return Backup{Name: name, Size: int64(len(data)), CreatedAt: now}, nil
+}
+
+// prune применяет политику хранения; самая свежая копия не удаляется никогда
+func (b *backups) prune() {
+	list, err := b.list()
+	if err != nil {
+		return
+	}
+	for i, bk := range list {
+		if i == 0 {
+			continue
+		}
+		tooMany := b.cfg.Keep > 0 && i >= b.cfg.Keep
+		tooOld := b.cfg.MaxAge > 0 && b.now().Sub(bk.CreatedAt) > b.cfg.MaxAge
+		if tooMany || tooOld {
+			os.Remove(filepath.Join(b.cfg.Dir, bk.Name))
+		}
+	}
+}
+
+// EnableBackups включает резервные копии перед записью users.json
+func (s *Storage) EnableBackups(cfg BackupConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups = newBackups(cfg, s.filePath)
+}
+
+// Backups — список копий от новых к старым
+func (s *Storage) Backups() ([]Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backups == nil {
+		return nil, ErrBackupsDisabled
+	}
+	return s.backups.list()
+}
+
+// CreateBackup делает копию текущего файла вне расписания
+func (s *Storage) CreateBackup() (Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backups == nil {
+		return Backup{}, ErrBackupsDisabled
+	}
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return Backup{}, err
+	}
+	defer unlock()
+	return s.backups.create(s.filePath)
+}
+
+// RestoreBackup заменяет users.json копией name. Текущий файл перед этим
+// тоже сохраняется в копию, так что восстановление можно откатить.
+func (s *Storage) RestoreBackup(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backups == nil {
+		return ErrBackupsDisabled
+	}
+	src, err := s.backups.path(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := validDatabase(data); err != nil {
+		return fmt.Errorf("копия %s повреждена: %w", name, err)
+	}
+
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(s.filePath); err == nil {
+		if _, err := s.backups.create(s.filePath); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.filePath, data, 0644); err != nil {
+		return err
+	}
+	s.loaded, s.badErr = false, nil
+	return s.refresh()
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"gml-auth/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackupsRotateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	s := New(filepath.Join(dir, "users.json"))
+	s.EnableBackups(BackupConfig{Dir: filepath.Join(dir, "backups"), Keep: 2})
+
+	// первая запись создаёт файл — копировать ещё нечего
+	for i := 1; i <= 4; i++ {
+		if err := s.AddUser(models.User{UUID: fmt.Sprint("uuid-", i), Login: fmt.Sprint("user", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := s.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 backups after rotation, got %d: %+v", len(list), list)
+	}
+	if !list[0].CreatedAt.After(list[1].CreatedAt) {
+		t.Error("expected newest backup first")
+	}
+
+	// самая свежая копия сделана перед добавлением user4
+	if err := s.RestoreBackup(list[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	users, _ := s.ListUsers()
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users after restore, got %d", len(users))
+	}
+	if _, err := s.FindByLogin("user4"); !errors.Is(err, ErrNotFound) {
+		t.Error("user4 must be gone after restore")
+	}
+
+	if err := s.RestoreBackup("../users.json"); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("expected ErrBackupNotFound for bad name, got %v", err)
+	}
+}
+
+func TestBackupInterval(t *testing.T) {
+	dir := t.TempDir()
+	s := New(filepath.Join(dir, "users.json"))
+	s.EnableBackups(BackupConfig{Dir: filepath.Join(dir, "backups"), Interval: time.Hour})
+	for i := 1; i <= 3; i++ {
+		s.AddUser(models.User{UUID: fmt.Sprint("uuid-", i), Login: fmt.Sprint("user", i)})
+	}
+	if list, _ := s.Backups(); len(list) != 1 {
+		t.Errorf("expected one backup per interval, got %d", len(list))
+	}
+}
+
+func TestAtomicWriteLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := New(filepath.Join(dir, "users.json"))
+	s.AddUser(models.User{UUID: "uuid-1", Login: "Steve"})
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.Name() != "users.json" && e.Name() != "users.json.lock" {
+			t.Errorf("unexpected file left: %s", e.Name())
+		}
+	}
+}
+
+// Два экземпляра над одним файлом (как сервер и CLI) не теряют записи друг друга
+func TestConcurrentInstancesDoNotLoseWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	a, b := New(path), New(path)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := a
+			if i%2 == 1 {
+				s = b
+			}
+			if err := s.AddUser(models.User{UUID: fmt.Sprint("uuid-", i), Login: fmt.Sprint("user", i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	users, _ := New(path).ListUsers()
+	if len(users) != 20 {
+		t.Errorf("expected 20 users, got %d", len(users))
+	}
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"gml-auth/models"
+	"time"
+)
+
+// LiftExpiredBans снимает временные блокировки, срок которых истёк к now,
+// и возвращает логины разблокированных пользователей
+func LiftExpiredBans(s UserStore, now time.Time) ([]string, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	var lifted []string
+	for _, u := range users {
+		if !u.BanExpired(now) {
+			continue
+		}
+		changed := false
+		err := s.UpdateUser(u.Login, func(u *models.User) error {
+			// пока шёл обход, блокировку могли продлить или снять вручную
+			if u.BanExpired(now) {
+				u.Unban(models.LiftedByExpiry, u.BlockedUntil)
+				changed = true
+			}
+			return nil
+		})
+		if err != nil {
+			return lifted, err
+		}
+		if changed {
+			lifted = append(lifted, u.Login)
+		}
+	}
+	return lifted, nil
+}
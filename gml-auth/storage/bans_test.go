@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"gml-auth/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLiftExpiredBans(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "users.json"))
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	expired := models.User{UUID: "u1", Login: "Steve"}
+	expired.Ban("спам", "web-panel", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	active := models.User{UUID: "u2", Login: "Alex"}
+	active.Ban("читы", "web-panel", now, now.Add(time.Hour))
+	permanent := models.User{UUID: "u3", Login: "Notch"}
+	permanent.Ban("навсегда", "web-panel", now, time.Time{})
+	for _, u := range []models.User{expired, active, permanent} {
+		if err := s.AddUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lifted, err := LiftExpiredBans(s, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifted) != 1 || lifted[0] != "Steve" {
+		t.Fatalf("expected only Steve lifted, got %v", lifted)
+	}
+	u, _ := s.FindByLogin("Steve")
+	if u.Blocked || len(u.BanHistory) != 1 || u.BanHistory[0].LiftedBy != models.LiftedByExpiry {
+		t.Errorf("unexpected user after sweep: %+v", u)
+	}
+	for _, login := range []string{"Alex", "Notch"} {
+		if u, _ := s.FindByLogin(login); !u.Blocked {
+			t.Errorf("%s must stay blocked", login)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Document — небольшой JSON файл со служебным состоянием (блокировки, сессии и т.п.).
+// Значение живёт в памяти и сохраняется при каждом Update. Если файл изменили
+// снаружи (CLI, ручная правка), он перечитывается при следующем обращении.
+// Отсутствующий файл считается пустым документом.
+type Document[T any] struct {
+	mu       sync.Mutex
+	filePath string
+	loaded   bool
+	modTime  time.Time
+	value    T
+}
+
+func NewDocument[T any](filePath string) *Document[T] {
+	return &Document[T]{filePath: filePath}
+}
+
+func (d *Document[T]) ensureLoaded() error {
+	info, err := os.Stat(d.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if d.loaded && info.ModTime().Equal(d.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(d.filePath)
+	if err != nil {
+		return err
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	d.value = value
+	d.modTime = info.ModTime()
+	d.loaded = true
+	return nil
+}
+
+// Read вызывает fn с текущим значением под блокировкой.
+// fn не должна сохранять ссылки на внутренние map/slice после возврата.
+func (d *Document[T]) Read(fn func(*T)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.ensureLoaded(); err != nil {
+		return err
+	}
+	fn(&d.value)
+	return nil
+}
+
+// Update изменяет значение и сохраняет файл. fn получает копию: если fn вернула ошибку
+// или файл не удалось записать, значение в памяти остаётся прежним.
+func (d *Document[T]) Update(fn func(*T) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.ensureLoaded(); err != nil {
+		return err
+	}
+	next, err := d.clone()
+	if err != nil {
+		return err
+	}
+	if err := fn(&next); err != nil {
+		return err
+	}
+	if err := d.save(next); err != nil {
+		return err
+	}
+	d.value = next
+	return nil
+}
+
+// clone — глубокая копия значения (через JSON, как оно и хранится)
+func (d *Document[T]) clone() (T, error) {
+	var out T
+	data, err := json.Marshal(d.value)
+	if err != nil {
+		return out, err
+	}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+func (d *Document[T]) save(value T) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(d.filePath, data, 0644); err != nil {
+		return err
+	}
+	if info, err := os.Stat(d.filePath); err == nil {
+		d.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testDoc struct {
+	Items map[string]int `json:"items"`
+}
+
+func TestDocumentMissingFileIsEmpty(t *testing.T) {
+	d := NewDocument[testDoc](filepath.Join(t.TempDir(), "state.json"))
+	var n int
+	if err := d.Read(func(v *testDoc) { n = len(v.Items) }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected empty document, got %d items", n)
+	}
+}
+
+func TestDocumentUpdatePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "state.json")
+	d := NewDocument[testDoc](path)
+	err := d.Update(func(v *testDoc) error {
+		v.Items = map[string]int{"a": 1}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewDocument[testDoc](path)
+	var got int
+	reopened.Read(func(v *testDoc) { got = v.Items["a"] })
+	if got != 1 {
+		t.Errorf("expected persisted value 1, got %d", got)
+	}
+}
+
+func TestDocumentUpdateErrorSkipsSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	d := NewDocument[testDoc](path)
+	boom := errors.New("boom")
+	if err := d.Update(func(*testDoc) error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if m, _ := filepath.Glob(path); len(m) != 0 {
+		t.Error("file must not be written when fn fails")
+	}
+}
+
+func TestDocumentFailedUpdateKeepsValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	d := NewDocument[testDoc](path)
+	if err := d.Update(func(v *testDoc) error {
+		v.Items = map[string]int{"a": 1}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// ошибка после изменения не должна оставить его в памяти
+	d.Update(func(v *testDoc) error {
+		v.Items["a"] = 2
+		return errors.New("boom")
+	})
+	// файл нельзя записать: на его месте непустой каталог с тем же временем изменения
+	info, _ := os.Stat(path)
+	os.Remove(path)
+	os.MkdirAll(filepath.Join(path, "x"), 0755)
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+	if err := d.Update(func(v *testDoc) error {
+		v.Items["b"] = 3
+		return nil
+	}); err == nil {
+		t.Fatal("expected save error")
+	}
+	var items map[string]int
+	d.Read(func(v *testDoc) { items = v.Items })
+	if len(items) != 1 || items["a"] != 1 {
+		t.Errorf("memory must match the last saved value, got %v", items)
+	}
+}
+
+func TestDocumentReloadsExternalEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	d := NewDocument[testDoc](path)
+	d.Update(func(v *testDoc) error {
+		v.Items = map[string]int{"a": 1}
+		return nil
+	})
+
+	// другой процесс (например, CLI) переписал файл
+	other := NewDocument[testDoc](path)
+	other.Update(func(v *testDoc) error {
+		v.Items["b"] = 2
+		return nil
+	})
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	var got int
+	d.Read(func(v *testDoc) { got = v.Items["b"] })
+	if got != 2 {
+		t.Errorf("expected external edit to be visible, got %d", got)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// syncDir сбрасывает на диск запись каталога, чтобы переименование пережило сбой питания
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// lockFile берёт эксклюзивную рекомендательную блокировку на файл path
+// (создаётся при необходимости). Ждёт, пока блокировку не отпустит другой процесс.
+func lockFile(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrConflict — логин (без учёта регистра) или UUID уже занят другим пользователем
+	ErrConflict = errors.New("login already taken")
+	// ErrInvalidLogin — логин не подходит под правила Minecraft
+	ErrInvalidLogin = errors.New("логин должен быть от 3 до 16 символов: латинские буквы, цифры и _")
+)
+
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,16}$`)
+
+// NormalizeLogin — ключ для сравнения логинов: Steve и steve — один пользователь
+func NormalizeLogin(login string) string {
+	return strings.ToLower(login)
+}
+
+// ValidateLogin проверяет имя по правилам Minecraft. Хранилище само его не вызывает,
+// чтобы старые записи с нестандартными именами продолжали работать.
+func ValidateLogin(login string) error {
+	if !loginPattern.MatchString(login) {
+		return ErrInvalidLogin
+	}
+	return nil
+}
+
+// Collision — несколько пользователей с одинаковым логином (без учёта регистра) или UUID
+type Collision struct {
+	Kind   string   // login или uuid
+	Key    string   // нормализованный логин или UUID
+	Logins []string // логины всех записей в порядке хранения
+}
+
+// Audit ищет записи, которые конфликтуют друг с другом. Такие данные могли остаться
+// от версий без проверки уникальности или от ручной правки users.json.
+func Audit(s UserStore) ([]Collision, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	byLogin := map[string][]string{}
+	byUUID := map[string][]string{}
+	for _, u := range users {
+		key := NormalizeLogin(u.Login)
+		byLogin[key] = append(byLogin[key], u.Login)
+		byUUID[u.UUID] = append(byUUID[u.UUID], u.Login)
+	}
+	var out []Collision
+	for key, logins := range byLogin {
+		if len(logins) > 1 {
+			out = append(out, Collision{Kind: "login", Key: key, Logins: logins})
+		}
+	}
+	for key, logins := range byUUID {
+		if len(logins) > 1 {
+			out = append(out, Collision{Kind: "uuid", Key: key, Logins: logins})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateLogin(t *testing.T) {
+	for _, login := range []string{"Steve", "abc", "Player_123", "ABCDEFGHIJKLMNOP"} {
+		if err := ValidateLogin(login); err != nil {
+			t.Errorf("%q: unexpected error %v", login, err)
+		}
+	}
+	for _, login := range []string{"", "ab", "ABCDEFGHIJKLMNOPQ", "with space", "Игрок", "a-b-c", "dot.name"} {
+		if err := ValidateLogin(login); err == nil {
+			t.Errorf("%q: expected error", login)
+		}
+	}
+}
+
+func TestAuditReportsCollisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	os.WriteFile(path, []byte(`{"users":[
+		{"uuid":"u1","login":"Steve"},
+		{"uuid":"u2","login":"steve"},
+		{"uuid":"u3","login":"Alex"},
+		{"uuid":"u3","login":"Notch"}
+	]}`), 0644)
+	s := New(path)
+
+	collisions, err := Audit(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collisions) != 2 {
+		t.Fatalf("expected 2 collisions, got %+v", collisions)
+	}
+	if c := collisions[0]; c.Kind != "login" || c.Key != "steve" || len(c.Logins) != 2 {
+		t.Errorf("unexpected login collision: %+v", c)
+	}
+	if c := collisions[1]; c.Kind != "uuid" || c.Key != "u3" {
+		t.Errorf("unexpected uuid collision: %+v", c)
+	}
+	// при совпадении побеждает первая запись
+	if u, _ := s.FindByLogin("STEVE"); u.UUID != "u1" {
+		t.Errorf("expected the first record, got %+v", u)
+	}
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gml-auth/models"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// schema — версии схемы по порядку; номер текущей хранится в PRAGMA user_version.
+// Запись целиком лежит в data (JSON), а поля для поиска продублированы в столбцы.
+var schema = []string{
+	`CREATE TABLE users (
+		uuid    TEXT PRIMARY KEY,
+		login   TEXT NOT NULL,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		data    TEXT NOT NULL
+	);
+	CREATE UNIQUE INDEX users_login ON users(login);
+	CREATE INDEX users_blocked ON users(blocked);`,
+	// логины без учёта регистра. Уникальность проверяется в транзакции, а не индексом,
+	// чтобы база со старыми совпадениями (Steve/steve) открывалась и попадала в Audit.
+	`DROP INDEX users_login;
+	CREATE INDEX users_login_nocase ON users(login COLLATE NOCASE);`,
+}
+
+// SQLiteStore — хранилище пользователей во встроенной базе SQLite (без CGO)
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite открывает (или создаёт) базу и применяет недостающие версии схемы
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	dsn := "file:" + path +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// одно соединение: записи и так идут по очереди, а транзакции не мешают друг другу
+	db.SetMaxOpenConns(1)
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(schema); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(schema[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// PRAGMA не принимает параметры
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryer — общее у *sql.DB и *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func scanUser(q queryer, query string, args ...any) (models.User, int64, error) {
+	var (
+		id   int64
+		data string
+	)
+	err := q.QueryRow(query, args...).Scan(&id, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, 0, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, 0, err
+	}
+	var u models.User
+	return u, id, json.Unmarshal([]byte(data), &u)
+}
+
+// byLogin — запрос первой записи с логином без учёта регистра
+const byLogin = `SELECT rowid, data FROM users WHERE login = ? COLLATE NOCASE ORDER BY rowid LIMIT 1`
+
+func (s *SQLiteStore) FindByLogin(login string) (models.User, error) {
+	u, _, err := scanUser(s.db, byLogin, login)
+	return u, err
+}
+
+func (s *SQLiteStore) FindByUUID(id string) (models.User, error) {
+	u, _, err := scanUser(s.db, `SELECT rowid, data FROM users WHERE uuid = ?`, id)
+	return u, err
+}
+
+func (s *SQLiteStore) ListUsers() ([]models.User, error) {
+	rows, err := s.db.Query(`SELECT data FROM users ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var u models.User
+		if err := json.Unmarshal([]byte(data), &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// taken — логин или UUID уже есть у записи, отличной от self (0 — новая запись)
+func taken(tx *sql.Tx, u models.User, self int64) (bool, error) {
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE (login = ? COLLATE NOCASE OR uuid = ?) AND rowid != ?`,
+		u.Login, u.UUID, self).Scan(&n)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) AddUser(user models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if dup, err := taken(tx, user, 0); err != nil {
+		return err
+	} else if dup {
+		return ErrConflict
+	}
+	if _, err := tx.Exec(`INSERT INTO users (uuid, login, blocked, data) VALUES (?, ?, ?, ?)`,
+		user.UUID, user.Login, user.Blocked, string(data)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) UpdateUser(login string, fn func(*models.User) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	u, id, err := scanUser(tx, byLogin, login)
+	if err != nil {
+		return err
+	}
+	if err := fn(&u); err != nil {
+		return err
+	}
+	if dup, err := taken(tx, u, id); err != nil {
+		return err
+	} else if dup {
+		return ErrConflict
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET uuid = ?, login = ?, blocked = ?, data = ? WHERE rowid = ?`,
+		u.UUID, u.Login, u.Blocked, string(data), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteUser(login string) error {
+	res, err := s.db.Exec(`DELETE FROM users WHERE rowid = (
+		SELECT rowid FROM users WHERE login = ? COLLATE NOCASE ORDER BY rowid LIMIT 1)`, login)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
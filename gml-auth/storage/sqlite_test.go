@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"gml-auth/models"
+	"path/filepath"
+	"testing"
+)
+
+// testUserStore — общие проверки для любой реализации UserStore
+func testUserStore(t *testing.T, s UserStore) {
+	t.Helper()
+	if err := s.AddUser(models.User{UUID: "uuid-1", Login: "Steve", Password: "h1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddUser(models.User{UUID: "uuid-2", Login: "Alex", IsSlim: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.FindByLogin("Alex")
+	if err != nil || u.UUID != "uuid-2" || !u.IsSlim {
+		t.Fatalf("FindByLogin: %+v %v", u, err)
+	}
+	if u, err := s.FindByUUID("uuid-1"); err != nil || u.Login != "Steve" {
+		t.Fatalf("FindByUUID: %+v %v", u, err)
+	}
+	if _, err := s.FindByLogin("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// логины без учёта регистра и уникальны
+	if u, err := s.FindByLogin("aLEX"); err != nil || u.UUID != "uuid-2" {
+		t.Errorf("expected case-insensitive lookup, got %+v %v", u, err)
+	}
+	if err := s.AddUser(models.User{UUID: "uuid-3", Login: "alex"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict for duplicate login, got %v", err)
+	}
+	if err := s.AddUser(models.User{UUID: "uuid-1", Login: "Other"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict for duplicate uuid, got %v", err)
+	}
+	if err := s.UpdateUser("steve", func(u *models.User) error { u.Login = "ALEX"; return nil }); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict on rename, got %v", err)
+	}
+	if err := s.UpdateUser("steve", func(u *models.User) error { u.Login = "STEVE"; return nil }); err != nil {
+		t.Errorf("changing case of own login must be allowed, got %v", err)
+	}
+	s.UpdateUser("steve", func(u *models.User) error { u.Login = "Steve"; return nil })
+
+	if err := s.UpdateUser("Steve", func(u *models.User) error { u.Blocked = true; u.BlockReason = "test"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := s.FindByLogin("Steve"); !u.Blocked || u.BlockReason != "test" {
+		t.Errorf("update not saved: %+v", u)
+	}
+	if err := s.UpdateUser("nobody", func(*models.User) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on update, got %v", err)
+	}
+
+	users, err := s.ListUsers()
+	if err != nil || len(users) != 2 || users[0].Login != "Steve" {
+		t.Fatalf("ListUsers: %+v %v", users, err)
+	}
+
+	if err := s.DeleteUser("Steve"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser("Steve"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
+
+func TestJSONUserStore(t *testing.T) {
+	testUserStore(t, New(filepath.Join(t.TempDir(), "data", "users.json")))
+}
+
+func TestSQLiteUserStore(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testUserStore(t, s)
+}
+
+func TestSQLiteReopenKeepsData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddUser(models.User{UUID: "uuid-1", Login: "Steve"})
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.FindByLogin("Steve"); err != nil {
+		t.Errorf("expected user after reopen, got %v", err)
+	}
+	if err := s.AddUser(models.User{UUID: "uuid-2", Login: "steve"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestCopyBetweenBackends(t *testing.T) {
+	dir := t.TempDir()
+	src := New(filepath.Join(dir, "users.json"))
+	src.AddUser(models.User{UUID: "uuid-1", Login: "Steve", TOTPSecret: "SECRET"})
+	src.AddUser(models.User{UUID: "uuid-2", Login: "Alex"})
+
+	dst, err := OpenSQLite(filepath.Join(dir, "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	dst.AddUser(models.User{UUID: "uuid-2", Login: "Alex"})
+
+	copied, skipped, err := Copy(dst, src)
+	if err != nil || copied != 1 || skipped != 1 {
+		t.Fatalf("Copy: copied=%d skipped=%d err=%v", copied, skipped, err)
+	}
+	if u, _ := dst.FindByLogin("Steve"); u.TOTPSecret != "SECRET" {
+		t.Errorf("expected all fields to be copied, got %+v", u)
+	}
+}
@@ -3,112 +3,265 @@ package storage
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"gml-auth/models"
+	"io/fs"
+	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 var ErrNotFound = errors.New("user not found")
 
+// ErrCorrupted — users.json изменён вручную и не разбирается. Чтение продолжает
+// работать по последней удачной версии, а запись запрещена, чтобы не затереть правку.
+var ErrCorrupted = errors.New("users.json повреждён")
+
+// Storage — пользователи в JSON файле (data/users.json).
+// Файл держится в памяти с индексами по логину и UUID. Перед каждым обращением
+// проверяется время изменения файла: ручная правка подхватывается без перезапуска.
 type Storage struct {
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	filePath string
+
+	// снимок последней удачно прочитанной версии файла
+	loaded  bool
+	modTime time.Time
+	size    int64
+	users   []models.User
+	byLogin map[string]int
+	byUUID  map[string]int
+
+	// badModTime — версия файла, которую не удалось разобрать (ошибка пишется в лог один раз)
+	badModTime time.Time
+	badErr     error
+
+	backups *backups // nil — резервные копии выключены
 }
 
 func New(filePath string) *Storage {
 	return &Storage{filePath: filePath}
 }
 
-func (s *Storage) load() (models.Database, error) {
+// refresh перечитывает файл, если он изменился с прошлого раза.
+// Отсутствующий файл — пустая база (первый запуск, миграция).
+func (s *Storage) refresh() error {
+	info, err := os.Stat(s.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !s.loaded || s.size != 0 || !s.modTime.IsZero() {
+			s.setUsers(nil)
+			s.modTime, s.size, s.loaded = time.Time{}, 0, true
+		}
+		s.badErr = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.loaded && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	if s.badErr != nil && info.ModTime().Equal(s.badModTime) {
+		return s.corrupted()
+	}
+
 	data, err := os.ReadFile(s.filePath)
 	if err != nil {
-		return models.Database{}, err
+		return err
 	}
 	var db models.Database
-	return db, json.Unmarshal(data, &db)
+	if err := json.Unmarshal(data, &db); err != nil {
+		s.badModTime, s.badErr = info.ModTime(), err
+		log.Printf("[storage] %s не разобран: %v. Используется последняя удачная версия, запись запрещена до исправления файла", s.filePath, err)
+		return s.corrupted()
+	}
+	if s.loaded {
+		log.Printf("[storage] %s изменён, перечитан (%d пользователей)", s.filePath, len(db.Users))
+	}
+	s.badErr = nil
+	s.setUsers(db.Users)
+	s.modTime, s.size, s.loaded = info.ModTime(), info.Size(), true
+	return nil
 }
 
-func (s *Storage) save(db models.Database) error {
-	data, err := json.MarshalIndent(db, "", "  ")
-	if err != nil {
+// validDatabase — data разбирается как users.json
+func validDatabase(data []byte) error {
+	var db models.Database
+	return json.Unmarshal(data, &db)
+}
+
+// lockPath — файл блокировки между процессами (сервер, CLI, второй экземпляр)
+func (s *Storage) lockPath() string {
+	return s.filePath + ".lock"
+}
+
+// corrupted — ошибка для записи; для чтения достаточно загруженного раньше снимка
+func (s *Storage) corrupted() error {
+	if !s.loaded {
+		return fmt.Errorf("%w: %v", ErrCorrupted, s.badErr)
+	}
+	return nil
+}
+
+// writable — можно ли сохранять файл (он не испорчен ручной правкой)
+func (s *Storage) writable() error {
+	if err := s.refresh(); err != nil {
 		return err
 	}
-	return os.WriteFile(s.filePath, data, 0644)
+	if s.badErr != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupted, s.badErr)
+	}
+	return nil
 }
 
-func (s *Storage) FindByLogin(login string) (models.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	db, err := s.load()
-	if err != nil {
-		return models.User{}, err
+// setUsers заменяет снимок и перестраивает индексы (логин — без учёта регистра).
+// При повторяющемся логине или UUID (старые данные, см. Audit) находится первая запись.
+func (s *Storage) setUsers(users []models.User) {
+	if users == nil {
+		users = []models.User{}
 	}
-	for _, u := range db.Users {
-		if u.Login == login {
-			return u, nil
-		}
+	s.users = users
+	s.byLogin = make(map[string]int, len(users))
+	s.byUUID = make(map[string]int, len(users))
+	for i := len(users) - 1; i >= 0; i-- {
+		s.byLogin[NormalizeLogin(users[i].Login)] = i
+		s.byUUID[users[i].UUID] = i
 	}
-	return models.User{}, ErrNotFound
 }
 
-func (s *Storage) ListUsers() ([]models.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	db, err := s.load()
-	if err != nil {
-		return nil, err
+// taken — логин или UUID уже принадлежит записи, отличной от self (-1 — новая запись)
+func (s *Storage) taken(u models.User, self int) bool {
+	if i, ok := s.byLogin[NormalizeLogin(u.Login)]; ok && i != self {
+		return true
+	}
+	if i, ok := s.byUUID[u.UUID]; ok && i != self {
+		return true
 	}
-	return db.Users, nil
+	return false
 }
 
-func (s *Storage) AddUser(user models.User) error {
+// modify — общий путь записи: блокировка файла, актуальный снимок, fn, сохранение.
+// fn получает копию списка и возвращает новый.
+func (s *Storage) modify(fn func(users []models.User) ([]models.User, error)) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	db, err := s.load()
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// после блокировки перечитываем файл: его мог изменить другой процесс
+	if err := s.writable(); err != nil {
+		return err
+	}
+	users, err := fn(s.snapshot())
 	if err != nil {
 		return err
 	}
-	db.Users = append(db.Users, user)
-	return s.save(db)
+	return s.save(users)
 }
 
-func (s *Storage) DeleteUser(login string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	db, err := s.load()
+// save записывает users в файл и делает их текущим снимком.
+// Снимок меняется только после удачной записи.
+func (s *Storage) save(users []models.User) error {
+	data, err := json.MarshalIndent(models.Database{Users: users}, "", "  ")
 	if err != nil {
 		return err
 	}
-	filtered := db.Users[:0]
-	for _, u := range db.Users {
-		if u.Login != login {
-			filtered = append(filtered, u)
+	if s.backups != nil && s.backups.due() {
+		if _, err := os.Stat(s.filePath); err == nil {
+			if _, err := s.backups.create(s.filePath); err != nil {
+				log.Printf("[storage] резервная копия не создана: %v", err)
+			}
 		}
 	}
-	if len(filtered) == len(db.Users) {
-		return ErrNotFound
+	if err := writeFileAtomic(s.filePath, data, 0644); err != nil {
+		return err
+	}
+	s.setUsers(users)
+	if info, err := os.Stat(s.filePath); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// snapshot — копия списка, которую можно менять, не трогая текущий снимок.
+// Срезы внутри записей тоже копируются: fn в UpdateUser меняет их на месте,
+// и при неудачной записи снимок должен остаться прежним.
+func (s *Storage) snapshot() []models.User {
+	users := make([]models.User, len(s.users))
+	for i, u := range s.users {
+		users[i] = u.Clone()
 	}
-	db.Users = filtered
-	return s.save(db)
+	return users
 }
 
-func (s *Storage) UpdateUser(login string, fn func(*models.User)) error {
+func (s *Storage) FindByLogin(login string) (models.User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	db, err := s.load()
-	if err != nil {
-		return err
+	if err := s.refresh(); err != nil {
+		return models.User{}, err
 	}
-	found := false
-	for i := range db.Users {
-		if db.Users[i].Login == login {
-			fn(&db.Users[i])
-			found = true
-			break
-		}
+	if i, ok := s.byLogin[NormalizeLogin(login)]; ok {
+		return s.users[i].Clone(), nil
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *Storage) FindByUUID(id string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return models.User{}, err
+	}
+	if i, ok := s.byUUID[id]; ok {
+		return s.users[i].Clone(), nil
 	}
-	if !found {
-		return ErrNotFound
+	return models.User{}, ErrNotFound
+}
+
+func (s *Storage) ListUsers() ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
 	}
-	return s.save(db)
+	return s.snapshot(), nil
+}
+
+func (s *Storage) AddUser(user models.User) error {
+	return s.modify(func(users []models.User) ([]models.User, error) {
+		if s.taken(user, -1) {
+			return nil, ErrConflict
+		}
+		return append(users, user), nil
+	})
+}
+
+func (s *Storage) DeleteUser(login string) error {
+	return s.modify(func(users []models.User) ([]models.User, error) {
+		i, ok := s.byLogin[NormalizeLogin(login)]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return append(users[:i], users[i+1:]...), nil
+	})
+}
+
+func (s *Storage) UpdateUser(login string, fn func(*models.User) error) error {
+	return s.modify(func(users []models.User) ([]models.User, error) {
+		i, ok := s.byLogin[NormalizeLogin(login)]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		if err := fn(&users[i]); err != nil {
+			return nil, err
+		}
+		// переименование или смена UUID не должны совпасть с другим пользователем
+		if s.taken(users[i], i) {
+			return nil, ErrConflict
+		}
+		return users, nil
+	})
 }
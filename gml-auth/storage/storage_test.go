@@ -1,14 +1,17 @@
 package storage
 
 import (
+	"errors"
 	"gml-auth/models"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadSave(t *testing.T) {
 	// временный файл
-	f, err := os.CreateTemp("", "test-*.json")
+	f, err := os.CreateTemp(t.TempDir(), "test-*.json")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,7 +38,7 @@ func TestLoadSave(t *testing.T) {
 }
 
 func TestFindNotFound(t *testing.T) {
-	f, _ := os.CreateTemp("", "test-*.json")
+	f, _ := os.CreateTemp(t.TempDir(), "test-*.json")
 	f.WriteString(`{"users":[]}`)
 	f.Close()
 	defer os.Remove(f.Name())
@@ -46,3 +49,86 @@ func TestFindNotFound(t *testing.T) {
 		t.Error("expected error for missing user")
 	}
 }
+
+// writeExternal имитирует ручную правку файла с заметно другим временем изменения
+func writeExternal(t *testing.T, path, content string, shift time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(shift)
+	os.Chtimes(path, mt, mt)
+}
+
+func TestReloadOnExternalEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	writeExternal(t, path, `{"users":[{"uuid":"uuid-1","login":"Steve"}]}`, -time.Hour)
+	s := New(path)
+	if u, err := s.FindByUUID("uuid-1"); err != nil || u.Login != "Steve" {
+		t.Fatalf("FindByUUID: %+v %v", u, err)
+	}
+
+	writeExternal(t, path, `{"users":[{"uuid":"uuid-1","login":"Steve"},{"uuid":"uuid-2","login":"Alex"}]}`, -time.Minute)
+	if _, err := s.FindByLogin("Alex"); err != nil {
+		t.Fatalf("expected edited file to be reloaded, got %v", err)
+	}
+}
+
+func TestBrokenFileKeepsLastSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	writeExternal(t, path, `{"users":[{"uuid":"uuid-1","login":"Steve"}]}`, -time.Hour)
+	s := New(path)
+	s.FindByLogin("Steve")
+
+	writeExternal(t, path, `{"users":[{"uuid":"uuid-1",`, -time.Minute)
+	if _, err := s.FindByLogin("Steve"); err != nil {
+		t.Fatalf("reads must use the last good snapshot, got %v", err)
+	}
+	if err := s.AddUser(models.User{UUID: "uuid-2", Login: "Alex"}); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted on write, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"users":[{"uuid":"uuid-1",` {
+		t.Fatal("broken file must not be overwritten")
+	}
+
+	writeExternal(t, path, `{"users":[{"uuid":"uuid-3","login":"Fixed"}]}`, 0)
+	if err := s.AddUser(models.User{UUID: "uuid-2", Login: "Alex"}); err != nil {
+		t.Fatalf("expected writes after the file is fixed, got %v", err)
+	}
+	if _, err := s.FindByLogin("Fixed"); err != nil {
+		t.Errorf("expected fixed file to be loaded, got %v", err)
+	}
+}
+
+func TestBrokenFileOnStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	writeExternal(t, path, `not json`, 0)
+	if _, err := New(path).FindByLogin("Steve"); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted without a snapshot, got %v", err)
+	}
+}
+
+func TestUpdateFailureKeepsSnapshot(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "users.json"))
+	s.AddUser(models.User{UUID: "uuid-1", Login: "test", RecoveryCodes: []string{"a", "b", "c"}})
+
+	// колбэк меняет срез на месте и отказывается сохранять
+	errStop := errors.New("stop")
+	err := s.UpdateUser("test", func(u *models.User) error {
+		u.RecoveryCodes = append(u.RecoveryCodes[:0], u.RecoveryCodes[1:]...)
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected errStop, got %v", err)
+	}
+	u, _ := s.FindByLogin("test")
+	if len(u.RecoveryCodes) != 3 || u.RecoveryCodes[0] != "a" || u.RecoveryCodes[2] != "c" {
+		t.Fatalf("snapshot corrupted: %v", u.RecoveryCodes)
+	}
+
+	// копия, отданная читателю, тоже не связана со снимком
+	u.RecoveryCodes[0] = "x"
+	if again, _ := s.FindByLogin("test"); again.RecoveryCodes[0] != "a" {
+		t.Errorf("returned user shares slices with the store: %v", again.RecoveryCodes)
+	}
+}
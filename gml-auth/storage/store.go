@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"gml-auth/models"
+)
+
+// UserStore — хранилище пользователей. Обработчики работают только через него,
+// поэтому JSON файл можно заменить на SQLite без изменений в остальном коде.
+type UserStore interface {
+	FindByLogin(login string) (models.User, error)
+	FindByUUID(id string) (models.User, error)
+	ListUsers() ([]models.User, error)
+	AddUser(user models.User) error
+	// UpdateUser изменяет пользователя атомарно: fn получает актуальную запись.
+	// Если fn вернула ошибку, ничего не сохраняется и ошибка возвращается как есть.
+	UpdateUser(login string, fn func(*models.User) error) error
+	DeleteUser(login string) error
+}
+
+// Поддерживаемые хранилища (поле storage.backend в config.json)
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// DefaultPath — файл хранилища по умолчанию
+func DefaultPath(backend string) string {
+	if backend == BackendSQLite {
+		return "data/users.db"
+	}
+	return "data/users.json"
+}
+
+// Open открывает хранилище пользователей; пустой path — файл по умолчанию
+func Open(backend, path string) (UserStore, error) {
+	if backend == "" {
+		backend = BackendJSON
+	}
+	if path == "" {
+		path = DefaultPath(backend)
+	}
+	switch backend {
+	case BackendJSON:
+		return New(path), nil
+	case BackendSQLite:
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище %q (json или sqlite)", backend)
+	}
+}
+
+// Copy переносит пользователей из src в dst. Логины, которые уже есть в dst,
+// пропускаются, поэтому повторный запуск безопасен.
+func Copy(dst, src UserStore) (copied, skipped int, err error) {
+	users, err := src.ListUsers()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, u := range users {
+		if _, err := dst.FindByLogin(u.Login); err == nil {
+			skipped++
+			continue
+		} else if !errors.Is(err, ErrNotFound) {
+			return copied, skipped, err
+		}
+		if err := dst.AddUser(u); err != nil {
+			return copied, skipped, fmt.Errorf("%s: %w", u.Login, err)
+		}
+		copied++
+	}
+	return copied, skipped, nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gml-auth/models"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Способы выдачи UUID новым аккаунтам (поле storage.uuid_strategy в config.json)
+const (
+	// UUIDRandom — случайный UUID v4
+	UUIDRandom = "random"
+	// UUIDOffline — UUID v3 из "OfflinePlayer:<логин>", как у сервера в offline-mode:
+	// данные игроков сохраняются при переключении режима сервера
+	UUIDOffline = "offline"
+	// UUIDImported — UUID передаётся при создании (перенос из другой системы).
+	// Если его нет (например, при регистрации), выдаётся случайный.
+	UUIDImported = "imported"
+)
+
+var ErrUnknownUUIDStrategy = errors.New("unknown uuid strategy")
+
+// ValidUUIDStrategy — известный способ выдачи UUID; пустая строка — random
+func ValidUUIDStrategy(s string) bool {
+	switch s {
+	case "", UUIDRandom, UUIDOffline, UUIDImported:
+		return true
+	}
+	return false
+}
+
+// OfflineUUID повторяет UUID.nameUUIDFromBytes из Java: MD5 от "OfflinePlayer:<name>"
+// с битами версии 3 и варианта RFC 4122. Имя учитывается с регистром, как на сервере.
+func OfflineUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	return uuid.UUID(sum).String()
+}
+
+// NewUUID выдаёт UUID новому аккаунту по способу strategy.
+// imported — UUID из запроса; он приводится к каноническому виду.
+func NewUUID(strategy, login, imported string) (string, error) {
+	if imported != "" {
+		if strategy != UUIDImported {
+			return "", fmt.Errorf("uuid можно передать только при storage.uuid_strategy = %q", UUIDImported)
+		}
+		id, err := uuid.Parse(imported)
+		if err != nil {
+			return "", fmt.Errorf("неверный uuid: %w", err)
+		}
+		return id.String(), nil
+	}
+	switch strategy {
+	case "", UUIDRandom, UUIDImported:
+		return uuid.New().String(), nil
+	case UUIDOffline:
+		return OfflineUUID(login), nil
+	}
+	return "", ErrUnknownUUIDStrategy
+}
+
+// UUIDChange — смена UUID пользователя при миграции
+type UUIDChange struct {
+	Login string
+	Old   string
+	New   string
+}
+
+// PlanUUIDs сравнивает UUID пользователей с target и возвращает отличающиеся.
+// target возвращает false, если для пользователя нет нового UUID (нет в файле соответствий).
+func PlanUUIDs(s UserStore, target func(models.User) (string, bool)) ([]UUIDChange, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	var changes []UUIDChange
+	for _, u := range users {
+		id, ok := target(u)
+		if ok && id != u.UUID {
+			changes = append(changes, UUIDChange{Login: u.Login, Old: u.UUID, New: id})
+		}
+	}
+	return changes, nil
+}
+
+// ApplyUUIDs записывает новые UUID. Если новый UUID ещё занят другим пользователем,
+// которого тоже нужно перенести, смена повторяется после него. Оставшиеся конфликты
+// (например, два пользователя меняются UUID) попадают в failed, остальное применяется.
+// Данные других хранилищ, привязанные к прежним UUID (сессии, токены Yggdrasil, коды сброса),
+// здесь не трогаются — их отзывает вызывающий (см. uuid migrate в cli.go).
+func ApplyUUIDs(s UserStore, changes []UUIDChange) (applied int, failed map[string]error) {
+	failed = map[string]error{}
+	pending := changes
+	for len(pending) > 0 {
+		var retry []UUIDChange
+		for _, c := range pending {
+			err := s.UpdateUser(c.Login, func(u *models.User) error {
+				if u.UUID != c.Old {
+					return fmt.Errorf("UUID изменился с момента проверки: %s", u.UUID)
+				}
+				u.UUID = c.New
+				return nil
+			})
+			switch {
+			case errors.Is(err, ErrConflict):
+				retry = append(retry, c)
+				failed[c.Login] = fmt.Errorf("UUID %s занят другим пользователем: %w", c.New, err)
+			case err != nil:
+				failed[c.Login] = err
+			default:
+				delete(failed, c.Login)
+				applied++
+			}
+		}
+		if len(retry) == len(pending) {
+			break
+		}
+		pending = retry
+	}
+	return applied, failed
+}
+
+// ReadUUIDMap читает файл соответствий CSV "login,uuid" (заголовок и строки с # пропускаются).
+// Ключи — нормализованные логины.
+func ReadUUIDMap(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	out := map[string]string{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("строка %d: нужно login,uuid", line)
+		}
+		login, raw := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+		if line == 1 && strings.EqualFold(login, "login") {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: неверный uuid %q", line, raw)
+		}
+		out[NormalizeLogin(login)] = id.String()
+	}
+}
+
+// WriteUUIDChanges пишет CSV "login,old_uuid,new_uuid" — по нему переименовываются
+// файлы игроков на сервере (world/playerdata/<uuid>.dat и т.п.)
+func WriteUUIDChanges(w io.Writer, changes []UUIDChange) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"login", "old_uuid", "new_uuid"})
+	for _, c := range changes {
+		cw.Write([]string{c.Login, c.Old, c.New})
+	}
+	cw.Flush()
+	return cw.Error()
+}
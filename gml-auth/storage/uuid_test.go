@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"gml-auth/models"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOfflineUUID(t *testing.T) {
+	// значение совпадает с UUID.nameUUIDFromBytes("OfflinePlayer:Notch") в Java
+	if got := OfflineUUID("Notch"); got != "b50ad385-829d-3141-a216-7e7d7539ba7f" {
+		t.Errorf("unexpected offline uuid %s", got)
+	}
+	if OfflineUUID("notch") == OfflineUUID("Notch") {
+		t.Error("offline uuid must be case-sensitive")
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	if id, _ := NewUUID(UUIDOffline, "Notch", ""); id != OfflineUUID("Notch") {
+		t.Errorf("expected offline uuid, got %s", id)
+	}
+	if id, err := NewUUID(UUIDRandom, "Notch", ""); err != nil || id[14] != '4' {
+		t.Errorf("expected random v4 uuid, got %s %v", id, err)
+	}
+	if id, err := NewUUID(UUIDImported, "Notch", "069A79F444E94726A5BEFCA90E38AAF5"); err != nil || id != "069a79f4-44e9-4726-a5be-fca90e38aaf5" {
+		t.Errorf("expected imported uuid in canonical form, got %s %v", id, err)
+	}
+	if _, err := NewUUID(UUIDRandom, "Notch", "069a79f4-44e9-4726-a5be-fca90e38aaf5"); err == nil {
+		t.Error("uuid must be rejected unless strategy is imported")
+	}
+	if _, err := NewUUID(UUIDImported, "Notch", "not-a-uuid"); err == nil {
+		t.Error("invalid uuid must be rejected")
+	}
+	if _, err := NewUUID("sequential", "Notch", ""); err == nil {
+		t.Error("unknown strategy must be rejected")
+	}
+}
+
+func TestMigrateUUIDs(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "users.json"))
+	// Notch2 занимает offline UUID игрока Notch — его смена должна пройти первой
+	s.AddUser(models.User{UUID: "11111111-1111-4111-8111-111111111111", Login: "Notch"})
+	s.AddUser(models.User{UUID: OfflineUUID("Notch"), Login: "Notch2"})
+	s.AddUser(models.User{UUID: OfflineUUID("Steve"), Login: "Steve"})
+
+	offline := func(u models.User) (string, bool) { return OfflineUUID(u.Login), true }
+	changes, err := PlanUUIDs(s, offline)
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v %v", changes, err)
+	}
+	applied, failed := ApplyUUIDs(s, changes)
+	if applied != 2 || len(failed) != 0 {
+		t.Fatalf("expected all changes applied, got %d %v", applied, failed)
+	}
+	if u, _ := s.FindByLogin("Notch"); u.UUID != OfflineUUID("Notch") {
+		t.Errorf("unexpected uuid %s", u.UUID)
+	}
+	if changes, _ := PlanUUIDs(s, offline); len(changes) != 0 {
+		t.Errorf("expected nothing left to migrate, got %+v", changes)
+	}
+
+	var out bytes.Buffer
+	WriteUUIDChanges(&out, []UUIDChange{{Login: "Notch", Old: "a", New: "b"}})
+	if out.String() != "login,old_uuid,new_uuid\nNotch,a,b\n" {
+		t.Errorf("unexpected csv %q", out.String())
+	}
+}
+
+func TestReadUUIDMap(t *testing.T) {
+	m, err := ReadUUIDMap(strings.NewReader("login,uuid\n# из старой базы\nNotch, 069A79F444E94726A5BEFCA90E38AAF5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["notch"] != "069a79f4-44e9-4726-a5be-fca90e38aaf5" || len(m) != 1 {
+		t.Errorf("unexpected map %v", m)
+	}
+	if _, err := ReadUUIDMap(strings.NewReader("Notch,zzz\n")); err == nil {
+		t.Error("invalid uuid must be rejected")
+	}
+}
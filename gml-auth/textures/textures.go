@@ -0,0 +1,215 @@
+package textures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// MaxSize — предельный размер загружаемого PNG
+const MaxSize = 2 << 20
+
+// maxScale — самый большой HD скин: 1024x1024 (64 * 16)
+const maxScale = 16
+
+var (
+	ErrNotPNG        = errors.New("файл не является PNG")
+	ErrBadDimensions = errors.New("недопустимый размер текстуры")
+	ErrTooLarge      = errors.New("файл слишком большой")
+	ErrNotFound      = errors.New("texture not found")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Store хранит текстуры на диске под именем SHA-256 содержимого,
+// поэтому одинаковые файлы не дублируются, а URL никогда не меняет содержимое.
+type Store struct {
+	dir string
+	// mu упорядочивает запись и удаление: один файл бывает общим у нескольких игроков,
+	// и удаление «ничейного» файла не должно проскочить между записью и сохранением ссылки
+	mu sync.Mutex
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save записывает PNG и возвращает его хэш. Повторная запись того же файла ничего не делает.
+func (s *Store) Save(data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(data)
+}
+
+// Put записывает PNG и, не отпуская блокировку, вызывает assign с его хэшем —
+// там вызывающий сохраняет ссылку на файл. Параллельный Release этот файл не удалит.
+func (s *Store) Put(data []byte, assign func(hash string) error) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, err := s.save(data)
+	if err != nil {
+		return "", err
+	}
+	return hash, assign(hash)
+}
+
+func (s *Store) save(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp.Name(), path)
+}
+
+// Open открывает текстуру по хэшу
+func (s *Store) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Remove удаляет файл текстуры; отсутствующий файл не считается ошибкой
+func (s *Store) Remove(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(hash)
+}
+
+// Release удаляет файл, если inUse сообщает, что на него больше никто не ссылается.
+// Проверка и удаление идут под той же блокировкой, что и Put.
+func (s *Store) Release(hash string, inUse func(hash string) (bool, error)) error {
+	if !ValidHash(hash) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, err := inUse(hash)
+	if err != nil || used {
+		return err
+	}
+	return s.remove(hash)
+}
+
+func (s *Store) remove(hash string) error {
+	if !ValidHash(hash) {
+		return nil
+	}
+	err := os.Remove(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash+".png")
+}
+
+// ValidHash — строка похожа на SHA-256 в hex (защита от обхода путей)
+func ValidHash(hash string) bool {
+	return hashPattern.MatchString(hash)
+}
+
+// ParseSkin проверяет скин и определяет тонкие руки (модель Alex).
+// Допустимы 64x64, старый формат 64x32 и HD кратные им размеры до 1024.
+func ParseSkin(data []byte) (slim bool, err error) {
+	img, err := decode(data)
+	if err != nil {
+		return false, err
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := w / 64
+	if w%64 != 0 || scale < 1 || scale > maxScale {
+		return false, fmt.Errorf("%w: %dx%d", ErrBadDimensions, w, h)
+	}
+	switch h {
+	case w:
+		return isSlim(img, scale), nil
+	case w / 2:
+		return false, nil // у старых скинов 64x32 только классическая модель
+	default:
+		return false, fmt.Errorf("%w: %dx%d", ErrBadDimensions, w, h)
+	}
+}
+
+// isSlim — у тонкой модели правая рука шириной 3 пикселя,
+// поэтому столбцы 54–55 в строках 20–31 полностью прозрачные
+func isSlim(img image.Image, scale int) bool {
+	b := img.Bounds()
+	for y := 20 * scale; y < 32*scale; y++ {
+		for x := 54 * scale; x < 56*scale; x++ {
+			if _, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA(); a != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParseCape проверяет плащ: 64x32, старый 22x17 или HD кратные 64x32
+func ParseCape(data []byte) error {
+	img, err := decode(data)
+	if err != nil {
+		return err
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == 22 && h == 17 {
+		return nil
+	}
+	scale := w / 64
+	if w%64 != 0 || scale < 1 || scale > maxScale || h != w/2 {
+		return fmt.Errorf("%w: %dx%d", ErrBadDimensions, w, h)
+	}
+	return nil
+}
+
+func decode(data []byte) (image.Image, error) {
+	if len(data) > MaxSize {
+		return nil, ErrTooLarge
+	}
+	// размеры проверяем до полного декодирования, чтобы не распаковывать огромные картинки
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotPNG
+	}
+	if cfg.Width > 64*maxScale || cfg.Height > 64*maxScale {
+		return nil, fmt.Errorf("%w: %dx%d", ErrBadDimensions, cfg.Width, cfg.Height)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotPNG
+	}
+	return img, nil
+}
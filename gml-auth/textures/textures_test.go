@@ -0,0 +1,137 @@
+package textures
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// makePNG рисует непрозрачную картинку w×h; slim — очистить столбцы 54–55 руки
+func makePNG(t *testing.T, w, h int, slim bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	if slim {
+		scale := w / 64
+		for y := 20 * scale; y < 32*scale; y++ {
+			for x := 54 * scale; x < 56*scale; x++ {
+				img.Set(x, y, color.NRGBA{})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSkin(t *testing.T) {
+	cases := []struct {
+		name     string
+		w, h     int
+		slim     bool
+		wantSlim bool
+		wantErr  bool
+	}{
+		{"classic 64x64", 64, 64, false, false, false},
+		{"slim 64x64", 64, 64, true, true, false},
+		{"legacy 64x32", 64, 32, false, false, false},
+		{"hd slim 128x128", 128, 128, true, true, false},
+		{"bad 65x65", 65, 65, false, false, true},
+		{"bad 64x48", 64, 48, false, false, true},
+	}
+	for _, c := range cases {
+		slim, err := ParseSkin(makePNG(t, c.w, c.h, c.slim))
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if slim != c.wantSlim {
+			t.Errorf("%s: expected slim=%v, got %v", c.name, c.wantSlim, slim)
+		}
+	}
+	if _, err := ParseSkin([]byte("not a png")); !errors.Is(err, ErrNotPNG) {
+		t.Errorf("expected ErrNotPNG, got %v", err)
+	}
+}
+
+func TestParseCape(t *testing.T) {
+	if err := ParseCape(makePNG(t, 64, 32, false)); err != nil {
+		t.Errorf("64x32: %v", err)
+	}
+	if err := ParseCape(makePNG(t, 22, 17, false)); err != nil {
+		t.Errorf("22x17: %v", err)
+	}
+	if err := ParseCape(makePNG(t, 64, 64, false)); !errors.Is(err, ErrBadDimensions) {
+		t.Errorf("64x64 cape: expected ErrBadDimensions, got %v", err)
+	}
+}
+
+func TestStoreContentAddressed(t *testing.T) {
+	s := New(t.TempDir())
+	data := makePNG(t, 64, 64, false)
+	a, err := s.Save(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := s.Save(data)
+	if a != b || !ValidHash(a) {
+		t.Fatalf("expected the same valid hash, got %s and %s", a, b)
+	}
+	f, err := s.Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(f)
+	f.Close()
+	if !bytes.Equal(got, data) {
+		t.Error("stored content differs")
+	}
+	if err := s.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(a); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after remove, got %v", err)
+	}
+	if _, err := s.Open("../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected path traversal to be rejected, got %v", err)
+	}
+}
+
+func TestStoreRelease(t *testing.T) {
+	s := New(t.TempDir())
+	owner := ""
+	hash, err := s.Put(makePNG(t, 64, 64, false), func(hash string) error {
+		owner = hash
+		return nil
+	})
+	if err != nil || owner != hash {
+		t.Fatalf("put: %v, owner %q", err, owner)
+	}
+	inUse := func(hash string) (bool, error) { return owner == hash, nil }
+	if err := s.Release(hash, inUse); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(hash); err != nil {
+		t.Fatalf("file in use must be kept: %v", err)
+	}
+	if err := s.Release(hash, func(string) (bool, error) { return false, errors.New("list failed") }); err == nil {
+		t.Error("expected inUse error")
+	}
+	owner = ""
+	if err := s.Release(hash, inUse); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(hash); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected unused file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gml-auth/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Параметры RFC 6238, совместимые с Google Authenticator и аналогами
+const (
+	Period = 30
+	Digits = 6
+	// Skew — сколько соседних шагов (±30 с) принимаем из-за расхождения часов
+	Skew = 1
+
+	secretSize       = 20
+	recoveryCodeSize = 5 // байт → 10 hex-символов
+)
+
+var ErrInvalidSecret = errors.New("invalid totp secret")
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret возвращает случайный секрет в base32 без паддинга
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(buf), nil
+}
+
+// URI формирует otpauth:// ссылку для QR-кода приложения-аутентификатора
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(Period))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step возвращает номер 30-секундного шага для момента t
+func Step(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Code вычисляет код для указанного шага (RFC 4226 HOTP от счётчика шага)
+func Code(secret string, step int64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidSecret
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, bin%1_000_000), nil
+}
+
+// Verify проверяет код в окне ±Skew шагов вокруг t.
+// Шаги, не превышающие lastStep, отклоняются — так один код нельзя использовать дважды.
+// Возвращает шаг, которому соответствует код, чтобы его можно было запомнить.
+func Verify(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = Normalize(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// VerifyUser проверяет TOTP-код или код восстановления пользователя и обновляет
+// его состояние (последний шаг, израсходованный код). Вызывать внутри
+// Storage.UpdateUser, чтобы проверка и запись были атомарными.
+func VerifyUser(u *models.User, code string, t time.Time) bool {
+	if step, ok := Verify(u.TOTPSecret, code, t, u.TOTPLastStep); ok {
+		u.TOTPLastStep = step
+		return true
+	}
+	if i := MatchRecoveryCode(u.RecoveryCodes, code); i >= 0 {
+		u.RecoveryCodes = append(u.RecoveryCodes[:i], u.RecoveryCodes[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Normalize убирает пробелы и дефисы, которые пользователи копируют вместе с кодом
+func Normalize(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// GenerateRecoveryCodes создаёт n одноразовых кодов восстановления.
+// Пользователю отдаются plain, в базе хранятся только hashes.
+func GenerateRecoveryCodes(n int) (plain, hashes []string, err error) {
+	for range n {
+		buf := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		plain = append(plain, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return plain, hashes, nil
+}
+
+// HashRecoveryCode — SHA-256 от нормализованного кода.
+// Коды случайные и длинные, поэтому медленный KDF здесь не нужен.
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(Normalize(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchRecoveryCode возвращает индекс подошедшего кода или -1
+func MatchRecoveryCode(hashes []string, code string) int {
+	h := HashRecoveryCode(code)
+	for i, stored := range hashes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(h)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Тестовый вектор из RFC 6238 (SHA1, секрет "12345678901234567890")
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFCVectors(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("t=%d: expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestVerifySkewAndReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	prev, _ := Code(rfcSecret, Step(now)-1)
+
+	step, ok := Verify(rfcSecret, prev, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("expected previous step accepted, got ok=%v step=%d", ok, step)
+	}
+	if _, ok := Verify(rfcSecret, prev, now, step); ok {
+		t.Error("replayed code accepted")
+	}
+
+	old, _ := Code(rfcSecret, Step(now)-3)
+	if _, ok := Verify(rfcSecret, old, now, 0); ok {
+		t.Error("code outside skew window accepted")
+	}
+}
+
+func TestVerifyNormalizesInput(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	if _, ok := Verify(rfcSecret, " 005 924 ", now, 0); !ok {
+		t.Error("expected code with spaces to be accepted")
+	}
+	if _, ok := Verify("not base32!", "005924", now, 0); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	s, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 32 {
+		t.Errorf("expected 32 base32 chars, got %d", len(s))
+	}
+	uri := URI("GML", "Steve", s)
+	if !strings.HasPrefix(uri, "otpauth://totp/GML:Steve?") || !strings.Contains(uri, "secret="+s) {
+		t.Errorf("unexpected uri: %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	plain, hashes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 3 || len(hashes) != 3 {
+		t.Fatalf("expected 3 codes, got %d/%d", len(plain), len(hashes))
+	}
+	if i := MatchRecoveryCode(hashes, strings.ToUpper(plain[1])); i != 1 {
+		t.Errorf("expected match at 1, got %d", i)
+	}
+	if i := MatchRecoveryCode(hashes, "00000-00000"); i != -1 {
+		t.Errorf("unexpected match at %d", i)
+	}
+}
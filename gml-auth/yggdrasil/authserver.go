@@ -0,0 +1,209 @@
+package yggdrasil
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/audit"
+	"gml-auth/ipfilter"
+	"gml-auth/models"
+	"gml-auth/signin"
+	"log"
+	"net/http"
+)
+
+const (
+	msgInvalidCredentials = "Invalid credentials. Invalid username or password."
+	msgInvalidToken       = "Invalid token."
+)
+
+type authenticateRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	ClientToken string `json:"clientToken"`
+	RequestUser bool   `json:"requestUser"`
+}
+
+type tokenRequest struct {
+	AccessToken string `json:"accessToken"`
+	ClientToken string `json:"clientToken"`
+	RequestUser bool   `json:"requestUser"`
+}
+
+type userInfo struct {
+	ID         string     `json:"id"`
+	Properties []property `json:"properties"`
+}
+
+type authResponse struct {
+	AccessToken       string       `json:"accessToken"`
+	ClientToken       string       `json:"clientToken"`
+	AvailableProfiles []profileRef `json:"availableProfiles,omitempty"`
+	SelectedProfile   *profileRef  `json:"selectedProfile,omitempty"`
+	User              *userInfo    `json:"user,omitempty"`
+}
+
+// checkCredentials проверяет вход так же, как /signin (см. signin.Gate): блокировки адресов,
+// устройств и аккаунта, перебор, технические работы и политика входа.
+// Yggdrasil не умеет спрашивать 2FA, поэтому при включённой 2FA
+// код дописывается к паролю через двоеточие: "пароль:123456".
+// Отказ записывается в журнал входов; успешный вход записывает вызывающий.
+func (h *Handler) checkCredentials(r *http.Request, login, plain string) (models.User, error) {
+	user, _, denial := h.gate.Check(signin.Attempt{
+		Login:          login,
+		Password:       plain,
+		IP:             ipfilter.ClientIP(r),
+		TOTPInPassword: true,
+	})
+	if denial != nil {
+		h.gate.Record(r, denial.Entry)
+		return models.User{}, denial
+	}
+	return user, nil
+}
+
+// credentialsError отвечает 403 на отказ во входе.
+// Остальные ошибки (сбой хранилища) оставляет вызывающему.
+func (h *Handler) credentialsError(w http.ResponseWriter, err error) bool {
+	var denial *signin.Denial
+	if !errors.As(err, &denial) {
+		return false
+	}
+	switch denial.Code {
+	case http.StatusInternalServerError:
+		return false
+	case http.StatusUnauthorized, http.StatusNotFound:
+		// не подсказываем, что именно неверно: логин, пароль или код 2FA
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidCredentials)
+	case http.StatusTooManyRequests:
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", "Too many login attempts. Try again later.")
+	default:
+		// блокировки, технические работы, закрытый вход — текст показывается игроку
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", denial.Message)
+	}
+	return true
+}
+
+// authenticate — POST /authserver/authenticate
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) {
+	var req authenticateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid request")
+		return
+	}
+	user, err := h.checkCredentials(r, req.Username, req.Password)
+	if h.credentialsError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("[yggdrasil] authenticate %s: %v", req.Username, err)
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+
+	attempt := audit.Entry{Login: user.Login, UserUUID: user.UUID, Result: audit.ResultSuccess, Code: http.StatusOK}
+	clientToken := req.ClientToken
+	if clientToken == "" {
+		if clientToken, err = randomHex(16); err != nil {
+			attempt.Result, attempt.Code, attempt.Reason = audit.ResultError, http.StatusInternalServerError, err.Error()
+			h.gate.Record(r, attempt)
+			writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+			return
+		}
+	}
+	if err := h.writeTokens(w, user, clientToken, req.RequestUser, true); err != nil {
+		attempt.Result, attempt.Code, attempt.Reason = audit.ResultError, http.StatusInternalServerError, err.Error()
+		h.gate.Record(r, attempt)
+		return
+	}
+	h.gate.RecordLogin(user.Login, ipfilter.ClientIP(r), "")
+	h.gate.Record(r, attempt)
+}
+
+// refresh — POST /authserver/refresh: выдаёт новый accessToken, старый отзывается
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	tok, ok := h.tokens.find(req.AccessToken, req.ClientToken)
+	if !ok {
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidToken)
+		return
+	}
+	user, err := h.store.FindByUUID(tok.UserUUID)
+	if err != nil || h.gate.Admit(user) != nil {
+		h.tokens.revoke(req.AccessToken)
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidToken)
+		return
+	}
+	if err := h.tokens.revoke(req.AccessToken); err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+	h.writeTokens(w, user, tok.ClientToken, req.RequestUser, false)
+}
+
+// writeTokens выдаёт accessToken и отвечает профилем. Ошибку выдачи
+// (ответ 500 уже записан) возвращает для журнала.
+func (h *Handler) writeTokens(w http.ResponseWriter, user models.User, clientToken string, requestUser, withAvailable bool) error {
+	access, err := h.tokens.issue(user.UUID, clientToken)
+	if err != nil {
+		log.Printf("[yggdrasil] issue token %s: %v", user.Login, err)
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return err
+	}
+	profile := ref(user)
+	resp := authResponse{
+		AccessToken:     access,
+		ClientToken:     clientToken,
+		SelectedProfile: &profile,
+	}
+	if withAvailable {
+		resp.AvailableProfiles = []profileRef{profile}
+	}
+	if requestUser {
+		resp.User = &userInfo{ID: unsignedUUID(user.UUID), Properties: []property{}}
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// validate — POST /authserver/validate: 204, если токен действителен
+func (h *Handler) validate(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	tok, ok := h.tokens.find(req.AccessToken, req.ClientToken)
+	if ok {
+		if user, err := h.store.FindByUUID(tok.UserUUID); err == nil && h.gate.Admit(user) == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidToken)
+}
+
+// invalidate — POST /authserver/invalidate: всегда 204
+func (h *Handler) invalidate(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.AccessToken != "" {
+		h.tokens.revoke(req.AccessToken)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// signout — POST /authserver/signout: отзывает все токены по логину и паролю
+func (h *Handler) signout(w http.ResponseWriter, r *http.Request) {
+	var req authenticateRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	user, err := h.checkCredentials(r, req.Username, req.Password)
+	if h.credentialsError(w, err) {
+		return
+	}
+	if err == nil {
+		err = h.tokens.revokeUser(user.UUID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
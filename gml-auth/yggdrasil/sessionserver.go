@@ -0,0 +1,126 @@
+package yggdrasil
+
+import (
+	"encoding/json"
+	"errors"
+	"gml-auth/ipfilter"
+	"gml-auth/storage"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxProfileLookup — лимит имён в одном запросе /api/profiles/minecraft
+const maxProfileLookup = 100
+
+type joinRequest struct {
+	AccessToken     string `json:"accessToken"`
+	SelectedProfile string `json:"selectedProfile"`
+	ServerID        string `json:"serverId"`
+}
+
+// join — POST /sessionserver/session/minecraft/join (вызывает клиент игры)
+func (h *Handler) join(w http.ResponseWriter, r *http.Request) {
+	var req joinRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	tok, ok := h.tokens.find(req.AccessToken, "")
+	if !ok || req.ServerID == "" || unsignedUUID(tok.UserUUID) != req.SelectedProfile {
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidToken)
+		return
+	}
+	user, err := h.store.FindByUUID(tok.UserUUID)
+	if err != nil {
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", msgInvalidToken)
+		return
+	}
+	if denial := h.gate.Admit(user); denial != nil {
+		writeError(w, http.StatusForbidden, "ForbiddenOperationException", denial.Message)
+		return
+	}
+
+	now := h.now()
+	h.joinMu.Lock()
+	for id, j := range h.joins {
+		if now.Sub(j.at) > joinTTL {
+			delete(h.joins, id)
+		}
+	}
+	h.joins[req.ServerID] = join{userUUID: tok.UserUUID, ip: ipfilter.ClientIP(r), at: now}
+	h.joinMu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasJoined — GET /sessionserver/session/minecraft/hasJoined?username=&serverId=[&ip=]
+// (вызывает сервер Minecraft). 204 — игрок не заходил.
+func (h *Handler) hasJoined(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	serverID, username, ip := q.Get("serverId"), q.Get("username"), q.Get("ip")
+
+	h.joinMu.Lock()
+	j, ok := h.joins[serverID]
+	if ok {
+		delete(h.joins, serverID)
+	}
+	h.joinMu.Unlock()
+
+	if !ok || h.now().Sub(j.at) > joinTTL || (ip != "" && ip != j.ip) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	user, err := h.store.FindByUUID(j.userUUID)
+	if err != nil || !strings.EqualFold(user.Login, username) || h.gate.Admit(user) != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	profile, err := h.fullProfile(user, true)
+	if err != nil {
+		log.Printf("[yggdrasil] profile %s: %v", user.Login, err)
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// profile — GET /sessionserver/session/minecraft/profile/{uuid}[?unsigned=false]
+func (h *Handler) profile(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := h.findByProfileID(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+	signed := r.URL.Query().Get("unsigned") == "false"
+	profile, err := h.fullProfile(user, signed)
+	if err != nil {
+		log.Printf("[yggdrasil] profile %s: %v", user.Login, err)
+		writeError(w, http.StatusInternalServerError, "InternalServerError", "Internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// lookupProfiles — POST /api/profiles/minecraft: ["name", ...] → [{id, name}, ...]
+func (h *Handler) lookupProfiles(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid request")
+		return
+	}
+	if len(names) > maxProfileLookup {
+		writeError(w, http.StatusBadRequest, "IllegalArgumentException", "Too many names")
+		return
+	}
+	out := []profileRef{}
+	for _, name := range names {
+		user, err := h.store.FindByLogin(name)
+		if err != nil {
+			continue
+		}
+		out = append(out, ref(user))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,148 @@
+package yggdrasil
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"gml-auth/storage"
+	"sort"
+	"time"
+)
+
+const (
+	// tokenTTL — срок жизни accessToken (как у Mojang)
+	tokenTTL = 15 * 24 * time.Hour
+	// maxTokensPerUser — старые токены вытесняются при превышении
+	maxTokensPerUser = 10
+)
+
+// Token — выданный клиенту accessToken. Сам токен хранится только в виде SHA-256.
+type Token struct {
+	AccessHash  string    `json:"access_hash"`
+	ClientToken string    `json:"client_token"`
+	UserUUID    string    `json:"user_uuid"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+// TokenState — содержимое data/yggdrasil_tokens.json
+type TokenState struct {
+	Tokens []*Token `json:"tokens"`
+}
+
+type tokenStore struct {
+	doc *storage.Document[TokenState]
+	now func() time.Time
+}
+
+func (s *tokenStore) issue(userUUID, clientToken string) (string, error) {
+	access, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	err = s.doc.Update(func(st *TokenState) error {
+		s.prune(st, now)
+		st.Tokens = append(st.Tokens, &Token{
+			AccessHash:  hashToken(access),
+			ClientToken: clientToken,
+			UserUUID:    userUUID,
+			IssuedAt:    now,
+		})
+		// вытесняем самые старые токены пользователя
+		var mine []*Token
+		for _, t := range st.Tokens {
+			if t.UserUUID == userUUID {
+				mine = append(mine, t)
+			}
+		}
+		if len(mine) > maxTokensPerUser {
+			sort.Slice(mine, func(i, j int) bool { return mine[i].IssuedAt.Before(mine[j].IssuedAt) })
+			evict := map[*Token]bool{}
+			for _, t := range mine[:len(mine)-maxTokensPerUser] {
+				evict[t] = true
+			}
+			kept := st.Tokens[:0]
+			for _, t := range st.Tokens {
+				if !evict[t] {
+					kept = append(kept, t)
+				}
+			}
+			st.Tokens = kept
+		}
+		return nil
+	})
+	return access, err
+}
+
+// find возвращает действующий токен. clientToken проверяется, только если передан.
+func (s *tokenStore) find(access, clientToken string) (Token, bool) {
+	h := hashToken(access)
+	now := s.now()
+	var (
+		out   Token
+		found bool
+	)
+	s.doc.Read(func(st *TokenState) {
+		for _, t := range st.Tokens {
+			if t.AccessHash != h {
+				continue
+			}
+			if now.Sub(t.IssuedAt) > tokenTTL || (clientToken != "" && t.ClientToken != clientToken) {
+				return
+			}
+			out, found = *t, true
+			return
+		}
+	})
+	return out, found
+}
+
+func (s *tokenStore) revoke(access string) error {
+	h := hashToken(access)
+	return s.doc.Update(func(st *TokenState) error {
+		kept := st.Tokens[:0]
+		for _, t := range st.Tokens {
+			if t.AccessHash != h {
+				kept = append(kept, t)
+			}
+		}
+		st.Tokens = kept
+		return nil
+	})
+}
+
+func (s *tokenStore) revokeUser(userUUID string) error {
+	return s.doc.Update(func(st *TokenState) error {
+		kept := st.Tokens[:0]
+		for _, t := range st.Tokens {
+			if t.UserUUID != userUUID {
+				kept = append(kept, t)
+			}
+		}
+		st.Tokens = kept
+		return nil
+	})
+}
+
+func (s *tokenStore) prune(st *TokenState, now time.Time) {
+	kept := st.Tokens[:0]
+	for _, t := range st.Tokens {
+		if now.Sub(t.IssuedAt) <= tokenTTL {
+			kept = append(kept, t)
+		}
+	}
+	st.Tokens = kept
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+// Package yggdrasil реализует Yggdrasil API в варианте authlib-injector:
+// authserver, sessionserver и метаданные с ключом подписи текстур.
+// Спецификация: https://github.com/yushijinhun/authlib-injector/wiki
+package yggdrasil
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"gml-auth/models"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config — настройки Yggdrasil сервера
+type Config struct {
+	ServerName  string
+	Homepage    string
+	SkinDomains []string
+}
+
+// Texture — одна текстура профиля (SKIN или CAPE)
+type Texture struct {
+	URL      string            `json:"url"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// TextureSource возвращает текстуры пользователя; nil — текстур нет
+type TextureSource func(u models.User) map[string]Texture
+
+type Handler struct {
+	cfg       Config
+	store     storage.UserStore
+	key       *rsa.PrivateKey
+	publicPEM string
+	tokens    *tokenStore
+	textures  TextureSource
+	gate      *signin.Gate
+
+	joinMu sync.Mutex
+	joins  map[string]join
+
+	now func() time.Time
+}
+
+// join — запись sessionserver/join, которую сервер Minecraft проверяет через hasJoined
+type join struct {
+	userUUID string
+	ip       string
+	at       time.Time
+}
+
+// joinTTL — сколько ждём hasJoined после join
+const joinTTL = 30 * time.Second
+
+func New(cfg Config, store storage.UserStore, key *rsa.PrivateKey, tokens *storage.Document[TokenState]) (*Handler, error) {
+	pub, err := publicKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		cfg:       cfg,
+		store:     store,
+		key:       key,
+		publicPEM: pub,
+		gate:      &signin.Gate{Store: store},
+		joins:     map[string]join{},
+		now:       time.Now,
+	}
+	h.tokens = &tokenStore{doc: tokens, now: func() time.Time { return h.now() }}
+	return h, nil
+}
+
+// SetTextures подключает источник текстур для свойства textures профиля
+func (h *Handler) SetTextures(src TextureSource) {
+	h.textures = src
+}
+
+// SetGate подключает проверки входа, общие с /signin (блокировки, перебор,
+// технические работы, политика входа). Без неё проверяются только пароль и 2FA.
+func (h *Handler) SetGate(g *signin.Gate) {
+	h.gate = g
+}
+
+// RevokeUser отзывает все токены пользователя (блокировка, удаление, смена пароля)
+func (h *Handler) RevokeUser(userUUID string) error {
+	return h.tokens.revokeUser(userUUID)
+}
+
+// RevokeUserTokens отзывает токены пользователя прямо в файле состояния,
+// без запущенного обработчика (uuid migrate при остановленном сервере)
+func RevokeUserTokens(tokens *storage.Document[TokenState], userUUID string) error {
+	return (&tokenStore{doc: tokens, now: time.Now}).revokeUser(userUUID)
+}
+
+// ServeHTTP обслуживает пути относительно корня API (префикс снимается через http.StripPrefix)
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := "/" + strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case path == "/" && r.Method == http.MethodGet:
+		h.metadata(w, r)
+	case path == "/authserver/authenticate" && r.Method == http.MethodPost:
+		h.authenticate(w, r)
+	case path == "/authserver/refresh" && r.Method == http.MethodPost:
+		h.refresh(w, r)
+	case path == "/authserver/validate" && r.Method == http.MethodPost:
+		h.validate(w, r)
+	case path == "/authserver/invalidate" && r.Method == http.MethodPost:
+		h.invalidate(w, r)
+	case path == "/authserver/signout" && r.Method == http.MethodPost:
+		h.signout(w, r)
+	case path == "/sessionserver/session/minecraft/join" && r.Method == http.MethodPost:
+		h.join(w, r)
+	case path == "/sessionserver/session/minecraft/hasJoined" && r.Method == http.MethodGet:
+		h.hasJoined(w, r)
+	case strings.HasPrefix(path, "/sessionserver/session/minecraft/profile/") && r.Method == http.MethodGet:
+		h.profile(w, r, strings.TrimPrefix(path, "/sessionserver/session/minecraft/profile/"))
+	case path == "/api/profiles/minecraft" && r.Method == http.MethodPost:
+		h.lookupProfiles(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NotFoundException", "Not found")
+	}
+}
+
+func (h *Handler) metadata(w http.ResponseWriter, _ *http.Request) {
+	meta := map[string]any{
+		"serverName":              h.cfg.ServerName,
+		"implementationName":      "gml-auth",
+		"implementationVersion":   "1.0",
+		"feature.non_email_login": true,
+	}
+	if h.cfg.Homepage != "" {
+		meta["links"] = map[string]string{"homepage": h.cfg.Homepage}
+	}
+	skinDomains := h.cfg.SkinDomains
+	if skinDomains == nil {
+		skinDomains = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"meta":               meta,
+		"skinDomains":        skinDomains,
+		"signaturePublickey": h.publicPEM,
+	})
+}
+
+// profileRef — {id, name} в ответах authserver
+type profileRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type property struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type profileResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Properties []property `json:"properties"`
+}
+
+func ref(u models.User) profileRef {
+	return profileRef{ID: unsignedUUID(u.UUID), Name: u.Login}
+}
+
+// fullProfile собирает профиль со свойством textures; signed — добавить подпись
+func (h *Handler) fullProfile(u models.User, signed bool) (profileResponse, error) {
+	textures := map[string]Texture{}
+	if h.textures != nil {
+		if t := h.textures(u); t != nil {
+			textures = t
+		}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"timestamp":   h.now().UnixMilli(),
+		"profileId":   unsignedUUID(u.UUID),
+		"profileName": u.Login,
+		"textures":    textures,
+	})
+	if err != nil {
+		return profileResponse{}, err
+	}
+	prop := property{Name: "textures", Value: base64.StdEncoding.EncodeToString(payload)}
+	if signed {
+		if prop.Signature, err = sign(h.key, prop.Value); err != nil {
+			return profileResponse{}, err
+		}
+	}
+	return profileResponse{
+		ID:         unsignedUUID(u.UUID),
+		Name:       u.Login,
+		Properties: []property{prop},
+	}, nil
+}
+
+// findByProfileID ищет пользователя по UUID без дефисов
+func (h *Handler) findByProfileID(id string) (models.User, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return models.User{}, storage.ErrNotFound
+	}
+	return h.store.FindByUUID(parsed.String())
+}
+
+// unsignedUUID — UUID без дефисов, как его ожидает Minecraft
+func unsignedUUID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
+
+// yggError — формат ошибок Yggdrasil
+type yggError struct {
+	Error        string `json:"error"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+func writeError(w http.ResponseWriter, code int, kind, msg string) {
+	writeJSON(w, code, yggError{Error: kind, ErrorMessage: msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
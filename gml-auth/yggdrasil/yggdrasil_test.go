@@ -0,0 +1,314 @@
+package yggdrasil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"gml-auth/audit"
+	"gml-auth/groups"
+	"gml-auth/hwid"
+	"gml-auth/ipfilter"
+	"gml-auth/maintenance"
+	"gml-auth/models"
+	"gml-auth/signin"
+	"gml-auth/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const steveUUID = "c07a9841-2275-4ba0-8f1c-2e1599a1f22f"
+
+func setupHandler(t *testing.T) (*Handler, *storage.Storage) {
+	dir := t.TempDir()
+	usersPath := filepath.Join(dir, "users.json")
+	os.WriteFile(usersPath, []byte(`{"users":[]}`), 0644)
+	store := storage.New(usersPath)
+	store.AddUser(models.User{UUID: steveUUID, Login: "Steve", Password: "pass123"})
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(Config{ServerName: "Test"}, store, key,
+		storage.NewDocument[TokenState](filepath.Join(dir, "tokens.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetTextures(func(u models.User) map[string]Texture {
+		return map[string]Texture{"SKIN": {URL: "http://localhost/textures/abc"}}
+	})
+	return h, store
+}
+
+func call(h *Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.RemoteAddr = "10.0.0.1:12345"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func authenticate(t *testing.T, h *Handler) authResponse {
+	w := call(h, http.MethodPost, "/authserver/authenticate",
+		`{"username":"Steve","password":"pass123","clientToken":"ct","requestUser":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("authenticate: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp authResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp
+}
+
+func TestMetadata(t *testing.T) {
+	h, _ := setupHandler(t)
+	w := call(h, http.MethodGet, "/", "")
+	var meta struct {
+		Meta               map[string]any `json:"meta"`
+		SignaturePublickey string         `json:"signaturePublickey"`
+	}
+	json.NewDecoder(w.Body).Decode(&meta)
+	if meta.Meta["serverName"] != "Test" {
+		t.Errorf("unexpected meta: %v", meta.Meta)
+	}
+	if !strings.HasPrefix(meta.SignaturePublickey, "-----BEGIN PUBLIC KEY-----") {
+		t.Errorf("unexpected public key: %q", meta.SignaturePublickey)
+	}
+}
+
+func TestAuthenticateValidateRefresh(t *testing.T) {
+	h, _ := setupHandler(t)
+	resp := authenticate(t, h)
+	if resp.ClientToken != "ct" || resp.SelectedProfile == nil ||
+		resp.SelectedProfile.ID != strings.ReplaceAll(steveUUID, "-", "") || resp.User == nil {
+		t.Fatalf("unexpected authenticate response: %+v", resp)
+	}
+
+	if w := call(h, http.MethodPost, "/authserver/validate", `{"accessToken":"`+resp.AccessToken+`"}`); w.Code != http.StatusNoContent {
+		t.Errorf("validate: expected 204, got %d", w.Code)
+	}
+	if w := call(h, http.MethodPost, "/authserver/validate", `{"accessToken":"`+resp.AccessToken+`","clientToken":"other"}`); w.Code != http.StatusForbidden {
+		t.Errorf("validate with wrong clientToken: expected 403, got %d", w.Code)
+	}
+
+	w := call(h, http.MethodPost, "/authserver/refresh", `{"accessToken":"`+resp.AccessToken+`","clientToken":"ct"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: expected 200, got %d", w.Code)
+	}
+	var refreshed authResponse
+	json.NewDecoder(w.Body).Decode(&refreshed)
+	if refreshed.AccessToken == resp.AccessToken {
+		t.Error("refresh must issue a new token")
+	}
+	if w := call(h, http.MethodPost, "/authserver/validate", `{"accessToken":"`+resp.AccessToken+`"}`); w.Code != http.StatusForbidden {
+		t.Errorf("old token must be invalid after refresh, got %d", w.Code)
+	}
+
+	call(h, http.MethodPost, "/authserver/invalidate", `{"accessToken":"`+refreshed.AccessToken+`","clientToken":"ct"}`)
+	if w := call(h, http.MethodPost, "/authserver/validate", `{"accessToken":"`+refreshed.AccessToken+`"}`); w.Code != http.StatusForbidden {
+		t.Errorf("token must be invalid after invalidate, got %d", w.Code)
+	}
+}
+
+func TestAuthenticateWrongPassword(t *testing.T) {
+	h, _ := setupHandler(t)
+	w := call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"nope"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ForbiddenOperationException") {
+		t.Errorf("expected 403 ForbiddenOperationException, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthenticateIPBan(t *testing.T) {
+	h, store := setupHandler(t)
+	bans := ipfilter.NewBans(storage.NewDocument[ipfilter.State](filepath.Join(t.TempDir(), "ip_bans.json")))
+	bans.Add("10.0.0.0/24", "боты", "admin", time.Time{})
+	h.SetGate(&signin.Gate{Store: store, IPBans: bans})
+
+	w := call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"pass123"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "боты") {
+		t.Errorf("expected 403 with ban reason, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHWIDBanBlocksGame(t *testing.T) {
+	h, store := setupHandler(t)
+	bans := hwid.NewBans(storage.NewDocument[hwid.State](filepath.Join(t.TempDir(), "hwid_bans.json")))
+	h.SetGate(&signin.Gate{Store: store, HWIDBans: bans})
+	resp := authenticate(t, h)
+
+	// игрок входил через лаунчер с устройства, которое потом заблокировали
+	store.UpdateUser("Steve", func(u *models.User) error { u.SeeDevice("aabbcc", time.Now()); return nil })
+	bans.Add("aabbcc", "читы", "admin", time.Time{})
+
+	body := `{"accessToken":"` + resp.AccessToken + `","selectedProfile":"` + resp.SelectedProfile.ID + `","serverId":"srv1"}`
+	if w := call(h, http.MethodPost, "/sessionserver/session/minecraft/join", body); w.Code != http.StatusForbidden {
+		t.Errorf("join: expected 403, got %d", w.Code)
+	}
+	w := call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"pass123"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "читы") {
+		t.Errorf("authenticate: expected 403 with ban reason, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignInPolicy(t *testing.T) {
+	h, store := setupHandler(t)
+	resp := authenticate(t, h)
+	h.SetGate(&signin.Gate{Store: store, Policy: groups.Policy{Groups: []string{"tester"}}})
+
+	w := call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"pass123"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "закрыт") {
+		t.Errorf("authenticate: expected 403 for non-tester, got %d: %s", w.Code, w.Body.String())
+	}
+	body := `{"accessToken":"` + resp.AccessToken + `","selectedProfile":"` + resp.SelectedProfile.ID + `","serverId":"srv1"}`
+	if w := call(h, http.MethodPost, "/sessionserver/session/minecraft/join", body); w.Code != http.StatusForbidden {
+		t.Errorf("join with a token issued before the policy: expected 403, got %d", w.Code)
+	}
+
+	store.UpdateUser("Steve", func(u *models.User) error {
+		u.Groups = []models.UserGroup{{Name: "tester"}}
+		return nil
+	})
+	authenticate(t, h)
+}
+
+func TestMaintenanceClosesGame(t *testing.T) {
+	h, store := setupHandler(t)
+	resp := authenticate(t, h)
+	works := maintenance.New(storage.NewDocument[maintenance.State](filepath.Join(t.TempDir(), "maintenance.json")),
+		maintenance.Settings{Enabled: true, Message: "Вайп до 18:00", AllowLogins: []string{"Admin"}})
+	h.SetGate(&signin.Gate{Store: store, Maintenance: works})
+
+	w := call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"pass123"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Вайп до 18:00") {
+		t.Errorf("authenticate: expected 403 with maintenance message, got %d: %s", w.Code, w.Body.String())
+	}
+	body := `{"accessToken":"` + resp.AccessToken + `","selectedProfile":"` + resp.SelectedProfile.ID + `","serverId":"srv1"}`
+	if w := call(h, http.MethodPost, "/sessionserver/session/minecraft/join", body); w.Code != http.StatusForbidden {
+		t.Errorf("join during maintenance: expected 403, got %d", w.Code)
+	}
+}
+
+func TestAuthenticateWritesAudit(t *testing.T) {
+	h, store := setupHandler(t)
+	attempts := audit.New(audit.Config{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	defer attempts.Close()
+	h.SetGate(&signin.Gate{Store: store, Audit: attempts})
+
+	call(h, http.MethodPost, "/authserver/authenticate", `{"username":"Steve","password":"nope"}`)
+	authenticate(t, h)
+	entries, err := attempts.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Result != audit.ResultSuccess || entries[1].Result != audit.ResultBadPassword ||
+		entries[0].UserUUID != steveUUID || entries[0].IP != "10.0.0.1" {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	if u, _ := store.FindByLogin("Steve"); u.LastLoginAt.IsZero() {
+		t.Error("expected last login to be recorded")
+	}
+}
+
+func TestBlockedUserTokenInvalid(t *testing.T) {
+	h, store := setupHandler(t)
+	resp := authenticate(t, h)
+	store.UpdateUser("Steve", func(u *models.User) error { u.Blocked = true; return nil })
+	if w := call(h, http.MethodPost, "/authserver/validate", `{"accessToken":"`+resp.AccessToken+`"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for blocked user, got %d", w.Code)
+	}
+}
+
+func TestJoinHasJoinedSignedTextures(t *testing.T) {
+	h, _ := setupHandler(t)
+	resp := authenticate(t, h)
+
+	body := `{"accessToken":"` + resp.AccessToken + `","selectedProfile":"` + resp.SelectedProfile.ID + `","serverId":"srv1"}`
+	if w := call(h, http.MethodPost, "/sessionserver/session/minecraft/join", body); w.Code != http.StatusNoContent {
+		t.Fatalf("join: expected 204, got %d", w.Code)
+	}
+
+	w := call(h, http.MethodGet, "/sessionserver/session/minecraft/hasJoined?username=Steve&serverId=srv1&ip=10.0.0.1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("hasJoined: expected 200, got %d", w.Code)
+	}
+	var profile profileResponse
+	json.NewDecoder(w.Body).Decode(&profile)
+	if profile.Name != "Steve" || len(profile.Properties) != 1 {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+	prop := profile.Properties[0]
+
+	// подпись должна проверяться открытым ключом из метаданных
+	block, _ := pem.Decode([]byte(h.publicPEM))
+	pub, _ := x509.ParsePKIXPublicKey(block.Bytes)
+	sig, _ := base64.StdEncoding.DecodeString(prop.Signature)
+	sum := sha1.Sum([]byte(prop.Value))
+	if err := rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA1, sum[:], sig); err != nil {
+		t.Errorf("invalid textures signature: %v", err)
+	}
+	payload, _ := base64.StdEncoding.DecodeString(prop.Value)
+	if !strings.Contains(string(payload), "http://localhost/textures/abc") {
+		t.Errorf("expected skin url in textures: %s", payload)
+	}
+
+	// join одноразовый
+	w = call(h, http.MethodGet, "/sessionserver/session/minecraft/hasJoined?username=Steve&serverId=srv1", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for reused serverId, got %d", w.Code)
+	}
+}
+
+func TestProfileAndLookup(t *testing.T) {
+	h, _ := setupHandler(t)
+	id := strings.ReplaceAll(steveUUID, "-", "")
+
+	w := call(h, http.MethodGet, "/sessionserver/session/minecraft/profile/"+id, "")
+	var profile profileResponse
+	json.NewDecoder(w.Body).Decode(&profile)
+	if w.Code != http.StatusOK || profile.Properties[0].Signature != "" {
+		t.Errorf("expected unsigned profile, got %d %+v", w.Code, profile)
+	}
+	w = call(h, http.MethodGet, "/sessionserver/session/minecraft/profile/"+id+"?unsigned=false", "")
+	json.NewDecoder(w.Body).Decode(&profile)
+	if profile.Properties[0].Signature == "" {
+		t.Error("expected signed profile")
+	}
+	if w := call(h, http.MethodGet, "/sessionserver/session/minecraft/profile/00000000000000000000000000000000", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for unknown profile, got %d", w.Code)
+	}
+
+	w = call(h, http.MethodPost, "/api/profiles/minecraft", `["Steve","Nobody"]`)
+	var refs []profileRef
+	json.NewDecoder(w.Body).Decode(&refs)
+	if len(refs) != 1 || refs[0].ID != id {
+		t.Errorf("unexpected lookup result: %+v", refs)
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("генерация 4096-битного ключа")
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	a, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Equal(b) {
+		t.Error("expected the same key after reload")
+	}
+}